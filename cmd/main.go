@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"SongLibrary/internal/enrich"
 	"SongLibrary/internal/handlers"
+	"SongLibrary/internal/jobs"
 	"SongLibrary/internal/models"
+	"SongLibrary/internal/search"
 	"SongLibrary/pkg/logger"
 
 	_ "SongLibrary/docs"
@@ -37,25 +48,196 @@ func main() {
 	}
 	logger.Log.Info("Database connected")
 
-	if err = db.AutoMigrate(&models.Song{}); err != nil {
+	if err = models.Migrate(db); err != nil {
 		logger.Log.WithError(err).Fatal("Failed to migrate database")
 	}
 	logger.Log.Info("Database migrated")
 
+	if err = models.SetupSearch(db); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to set up full-text search")
+	}
+
+	switch backend := os.Getenv("SEARCH_BACKEND"); backend {
+	case "", "database":
+		logger.Log.Info("Text filter uses the database")
+	case "memory":
+		models.SetSearchIndex(search.NewMemoryIndex())
+		if err = models.RebuildSearchIndex(db); err != nil {
+			logger.Log.WithError(err).Fatal("Failed to build in-memory search index")
+		}
+		logger.Log.Info("Text filter uses the in-memory search index")
+	default:
+		logger.Log.Fatalf("Unknown SEARCH_BACKEND %q, expected database or memory", backend)
+	}
+
+	enrichConfig, err := enrich.ConfigFromEnv()
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Invalid enrichment settings")
+	}
+	enricher, err := enrich.New(enrichConfig, db)
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to set up enrichment")
+	}
+
+	if threshold := os.Getenv("FUZZY_THRESHOLD"); threshold != "" {
+		models.FuzzyThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil || models.FuzzyThreshold < 0 || models.FuzzyThreshold > 1 {
+			logger.Log.Fatalf("Invalid FUZZY_THRESHOLD %q, expected a number between 0 and 1", threshold)
+		}
+	}
+
+	if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+		models.TrashRetention, err = time.ParseDuration(retention)
+		if err != nil || models.TrashRetention < 0 {
+			logger.Log.Fatalf("Invalid TRASH_RETENTION %q, expected a duration such as 720h", retention)
+		}
+	}
+
+	if fields := os.Getenv("PROTECT_MANUAL_EDITS"); fields != "" {
+		models.ProtectManualEdits, err = models.ParseFieldList(strings.Split(fields, ","))
+		if err != nil {
+			logger.Log.WithError(err).Fatalf("Invalid PROTECT_MANUAL_EDITS %q", fields)
+		}
+	}
+
+	if workers := os.Getenv("BULK_WORKERS"); workers != "" {
+		handlers.BulkWorkers, err = strconv.Atoi(workers)
+		if err != nil || handlers.BulkWorkers < 1 {
+			logger.Log.Fatalf("Invalid BULK_WORKERS %q, expected a positive number", workers)
+		}
+	}
+
+	// The workers, the refresh scheduler and the server stop on SIGINT or
+	// SIGTERM; an enrichment job cut short runs again on the next start.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pool := jobs.NewPool(db, enricher)
+	if workers := os.Getenv("ENRICHMENT_WORKERS"); workers != "" {
+		pool.Workers, err = strconv.Atoi(workers)
+		if err != nil || pool.Workers < 0 {
+			logger.Log.Fatalf("Invalid ENRICHMENT_WORKERS %q, expected a number", workers)
+		}
+	}
+	if attempts := os.Getenv("ENRICHMENT_JOB_ATTEMPTS"); attempts != "" {
+		models.EnrichmentJobAttempts, err = strconv.Atoi(attempts)
+		if err != nil || models.EnrichmentJobAttempts < 1 {
+			logger.Log.Fatalf("Invalid ENRICHMENT_JOB_ATTEMPTS %q, expected a positive number", attempts)
+		}
+	}
+	if backoff := os.Getenv("ENRICHMENT_JOB_BACKOFF"); backoff != "" {
+		pool.Backoff, err = time.ParseDuration(backoff)
+		if err != nil || pool.Backoff < 0 {
+			logger.Log.Fatalf("Invalid ENRICHMENT_JOB_BACKOFF %q, expected a duration such as 30s", backoff)
+		}
+	}
+	poolDone := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(poolDone)
+	}()
+
+	if maxAge := os.Getenv("REFRESH_MAX_AGE"); maxAge != "" {
+		scheduler := jobs.NewScheduler(db, enricher, 0)
+		scheduler.MaxAge, err = time.ParseDuration(maxAge)
+		if err != nil || scheduler.MaxAge <= 0 {
+			logger.Log.Fatalf("Invalid REFRESH_MAX_AGE %q, expected a duration such as 720h", maxAge)
+		}
+		if interval := os.Getenv("REFRESH_INTERVAL"); interval != "" {
+			scheduler.Interval, err = time.ParseDuration(interval)
+			if err != nil || scheduler.Interval <= 0 {
+				logger.Log.Fatalf("Invalid REFRESH_INTERVAL %q, expected a duration such as 1h", interval)
+			}
+		}
+		if batch := os.Getenv("REFRESH_BATCH_SIZE"); batch != "" {
+			scheduler.Batch, err = strconv.Atoi(batch)
+			if err != nil || scheduler.Batch < 1 {
+				logger.Log.Fatalf("Invalid REFRESH_BATCH_SIZE %q, expected a positive number", batch)
+			}
+		}
+		go scheduler.Run(ctx)
+	}
+
 	router := gin.New()
 	router.Use(gin.LoggerWithWriter(logger.Log.Writer()), gin.Recovery())
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	router.GET("/songs", handlers.GetSongsHandler(db))
+	router.GET("/songs/search", handlers.SearchSongsHandler(db))
+	router.GET("/songs/suggest", handlers.SuggestSongsHandler(db))
+	router.GET("/songs/export", handlers.ExportSongsHandler(db))
+	router.GET("/songs/trash", handlers.GetTrashHandler(db))
+	router.GET("/songs/:id", handlers.GetSongHandler(db))
 	router.GET("/songs/:id/verses", handlers.GetSongVersesHandler(db))
-	router.POST("/songs", handlers.CreateSongHandler(db))
+	router.POST("/songs", handlers.CreateSongHandler(db, enricher))
+	router.POST("/songs/bulk", handlers.BulkSongsHandler(db, enricher))
+	router.POST("/songs/refresh", handlers.RefreshSongsHandler(db, enricher))
 	router.PUT("/songs/:id", handlers.UpdateSongHandler(db))
+	router.PATCH("/songs/:id", handlers.PatchSongHandler(db))
 	router.DELETE("/songs/:id", handlers.DeleteSongHandler(db))
+	router.POST("/songs/:id/restore", handlers.RestoreSongHandler(db))
+	router.POST("/songs/:id/refresh", handlers.RefreshSongHandler(db, enricher))
+	router.PUT("/songs/:id/protected_fields", handlers.SetProtectedFieldsHandler(db))
+	router.GET("/songs/:id/revisions", handlers.GetSongRevisionsHandler(db))
+	router.GET("/songs/:id/revisions/:rev/diff", handlers.GetSongRevisionDiffHandler(db))
+	router.POST("/songs/:id/revisions/:rev/revert", handlers.RevertSongRevisionHandler(db))
+
+	router.GET("/groups", handlers.GetGroupsHandler(db))
+	router.GET("/groups/:id", handlers.GetGroupHandler(db))
+	router.POST("/groups", handlers.CreateGroupHandler(db))
+	router.PUT("/groups/:id", handlers.UpdateGroupHandler(db))
+	router.DELETE("/groups/:id", handlers.DeleteGroupHandler(db))
+
+	router.GET("/albums", handlers.GetAlbumsHandler(db))
+	router.GET("/albums/:id", handlers.GetAlbumHandler(db))
+	router.POST("/albums", handlers.CreateAlbumHandler(db))
+	router.PUT("/albums/:id", handlers.UpdateAlbumHandler(db))
+	router.DELETE("/albums/:id", handlers.DeleteAlbumHandler(db))
+	router.POST("/albums/:id/tracks", handlers.AddAlbumTrackHandler(db))
+	router.DELETE("/albums/:id/tracks/:songId", handlers.RemoveAlbumTrackHandler(db))
+
+	router.GET("/playlists", handlers.GetPlaylistsHandler(db))
+	router.GET("/playlists/:id", handlers.GetPlaylistHandler(db))
+	router.POST("/playlists", handlers.CreatePlaylistHandler(db))
+	router.PUT("/playlists/:id", handlers.RenamePlaylistHandler(db))
+	router.DELETE("/playlists/:id", handlers.DeletePlaylistHandler(db))
+	router.POST("/playlists/:id/entries", handlers.AddPlaylistEntryHandler(db))
+	router.DELETE("/playlists/:id/entries/:entryId", handlers.RemovePlaylistEntryHandler(db))
+	router.PUT("/playlists/:id/order", handlers.ReorderPlaylistHandler(db))
+
+	router.GET("/autocomplete", handlers.AutocompleteHandler(db))
+
+	router.GET("/jobs/:id", handlers.GetJobHandler(db))
+
+	router.POST("/import", handlers.ImportSongsHandler(db, enricher))
+
+	router.POST("/admin/trash/purge", handlers.PurgeTrashHandler(db))
+	router.POST("/admin/search/reload", handlers.ReloadSearchHandler(db))
+	router.GET("/admin/enrichment", handlers.EnrichmentStatusHandler(enricher))
+	router.DELETE("/admin/enrichment/cache", handlers.InvalidateEnrichmentCacheHandler(enricher))
+
+	router.GET("/tags", handlers.GetTagsHandler(db))
+	router.POST("/songs/:id/tags", handlers.AttachSongTagsHandler(db))
+	router.DELETE("/songs/:id/tags/:tag", handlers.DetachSongTagHandler(db))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.WithError(err).Fatal("Server failed")
+		}
+	}()
 	logger.Log.Infof("Server running on port %s", port)
-	router.Run(":" + port)
+
+	<-ctx.Done()
+	logger.Log.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Log.WithError(err).Error("Failed to shut the server down gracefully")
+	}
+	<-poolDone
 }
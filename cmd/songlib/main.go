@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/importer"
+	"SongLibrary/internal/models"
+	"SongLibrary/pkg/logger"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: songlib <command> [flags]
+
+Commands:
+  import  Add songs from a CSV, JSON Lines or JSON file
+
+Run "songlib <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "import":
+		os.Exit(runImport(os.Args[2:]))
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "songlib: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runImport imports a file and prints the report, returning the exit code:
+// 1 when the file could not be read to the end or rows failed. It writes the
+// database directly, so a running server with the in-memory search index only
+// sees the new songs in search and autocomplete after POST
+// /admin/search/reload or a restart.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), "Usage: songlib import [flags] FILE\n\nFILE is - for standard input. A running server picks up the\nimported songs in search and autocomplete after POST /admin/search/reload.\n\n")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "", "csv, jsonl or json (default from the file extension)")
+	columns := flags.String("columns", "", "column mapping, e.g. artist=group_name,title=song_name")
+	onDuplicate := flags.String("on-duplicate", importer.OnDuplicateSkip, "skip, overwrite or merge songs that exist")
+	enrichFlag := flags.Bool("enrich", false, "fill in missing fields from the external API")
+	actor := flags.String("actor", "import", "who the revisions are recorded for")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	opts := importer.Options{Format: *format, OnDuplicate: *onDuplicate}
+	if opts.Format == "" {
+		opts.Format = importer.DetectFormat(path)
+	}
+	var err error
+	if opts.Columns, err = importer.ParseColumns(*columns); err != nil {
+		fmt.Fprintln(os.Stderr, "songlib:", err)
+		return 2
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "songlib:", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	db := connect()
+	if *enrichFlag {
+		cfg, err := enrich.ConfigFromEnv()
+		if err == nil {
+			opts.Enricher, err = enrich.New(cfg, db)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "songlib:", err)
+			return 1
+		}
+	}
+
+	report, err := importer.Import(db.WithContext(models.WithActor(context.Background(), *actor)), input, opts)
+	fmt.Printf("rows: %d, created: %d, updated: %d, skipped: %d, failed: %d\n",
+		report.Rows, report.Created, report.Updated, report.Skipped, report.Failed)
+	for _, rowErr := range report.Errors {
+		if rowErr.Field != "" {
+			fmt.Printf("row %d: %s: %s\n", rowErr.Row, rowErr.Field, rowErr.Error)
+		} else {
+			fmt.Printf("row %d: %s\n", rowErr.Row, rowErr.Error)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "songlib:", err)
+		return 1
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// connect opens the database of DATABASE_DSN, from the environment or .env,
+// and brings its schema up to date like the server does on startup.
+func connect() *gorm.DB {
+	_ = godotenv.Load()
+
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		logger.Log.Fatal("DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		logger.Log.WithError(err).Fatal("Failed to connect to database")
+	}
+	if err = models.Migrate(db); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to migrate database")
+	}
+	if err = models.SetupSearch(db); err != nil {
+		logger.Log.WithError(err).Fatal("Failed to set up full-text search")
+	}
+	return db
+}
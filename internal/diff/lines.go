@@ -0,0 +1,105 @@
+package diff
+
+import "strings"
+
+// Kinds of Line.
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// Line is one line of a line-level diff: kept, added in the new text or
+// removed from the old one.
+type Line struct {
+	Op   string `json:"op" enums:"equal,insert,delete"`
+	Text string `json:"text"`
+}
+
+// Lines returns a shortest line-level diff turning a into b, built from a
+// longest common subsequence of their lines. Deleted lines come before the
+// lines inserted in their place.
+func Lines(a, b string) []Line {
+	old, cur := splitLines(a), splitLines(b)
+
+	// Lines shared at both ends need no table; lyrics edits are usually a
+	// few lines in the middle.
+	prefix := 0
+	for prefix < len(old) && prefix < len(cur) && old[prefix] == cur[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(cur)-prefix &&
+		old[len(old)-1-suffix] == cur[len(cur)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(old)+len(cur))
+	for _, text := range old[:prefix] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	lines = append(lines, middle(old[prefix:len(old)-suffix], cur[prefix:len(cur)-suffix])...)
+	for _, text := range old[len(old)-suffix:] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	return lines
+}
+
+// Changed reports whether a diff has any inserted or deleted line.
+func Changed(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+func middle(old, cur []string) []Line {
+	// common[i][j] is the length of the longest common subsequence of
+	// old[i:] and cur[j:].
+	common := make([][]int, len(old)+1)
+	for i := range common {
+		common[i] = make([]int, len(cur)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(cur) - 1; j >= 0; j-- {
+			if old[i] == cur[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []Line
+	i, j := 0, 0
+	for i < len(old) && j < len(cur) {
+		switch {
+		case old[i] == cur[j]:
+			lines = append(lines, Line{Op: Equal, Text: old[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, Line{Op: Delete, Text: old[i]})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: cur[j]})
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		lines = append(lines, Line{Op: Delete, Text: old[i]})
+	}
+	for ; j < len(cur); j++ {
+		lines = append(lines, Line{Op: Insert, Text: cur[j]})
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
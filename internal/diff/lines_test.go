@@ -0,0 +1,28 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	old := "Verse one\nVerse two\n\nChorus\nOutro"
+	cur := "Verse one\nVerse 2\n\nChorus\nChorus\nOutro"
+
+	assert.Equal(t, []Line{
+		{Op: Equal, Text: "Verse one"},
+		{Op: Delete, Text: "Verse two"},
+		{Op: Insert, Text: "Verse 2"},
+		{Op: Equal, Text: ""},
+		{Op: Insert, Text: "Chorus"},
+		{Op: Equal, Text: "Chorus"},
+		{Op: Equal, Text: "Outro"},
+	}, Lines(old, cur))
+
+	assert.True(t, Changed(Lines(old, cur)))
+	assert.False(t, Changed(Lines(old, old)))
+	assert.Equal(t, []Line{{Op: Insert, Text: "New"}}, Lines("", "New"))
+	assert.Equal(t, []Line{{Op: Delete, Text: "Gone"}}, Lines("Gone", ""))
+	assert.Equal(t, Lines("a\nb", "a\nc"), Lines("a\r\nb", "a\nc"))
+}
@@ -0,0 +1,124 @@
+package enrich
+
+import (
+	"sync"
+	"time"
+)
+
+// States of a Breaker.
+const (
+	BreakerClosed   = "closed"    // requests go through
+	BreakerOpen     = "open"      // requests fail fast until the cooldown is over
+	BreakerHalfOpen = "half_open" // one trial request decides whether to close again
+)
+
+// Breaker is a circuit breaker: after Threshold failures in a row it opens
+// and turns requests away for Cooldown, then lets a single trial request
+// through, closing on its success and opening again on its failure.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// BreakerStatus is a snapshot of a Breaker.
+type BreakerStatus struct {
+	Name     string     `json:"name" example:"http"`
+	State    string     `json:"state" enums:"closed,open,half_open" example:"open"`
+	Failures int        `json:"consecutive_failures" example:"5"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Name: name, Threshold: threshold, Cooldown: cooldown, state: BreakerClosed, now: time.Now}
+}
+
+// Allow reports whether a request may go ahead. Once the cooldown is over,
+// the first caller gets the trial request and the others are still turned
+// away until it is done.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+// Success records an allowed request that the upstream answered.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state, b.failures = BreakerClosed, 0
+}
+
+// Failure records an allowed request that found the upstream unavailable.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.state, b.openedAt = BreakerOpen, b.now()
+	}
+}
+
+// Abandon records an allowed request that ended without an answer either
+// way, e.g. because the client went away. A trial request is retried after
+// another cooldown.
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state, b.openedAt = BreakerOpen, b.now()
+	}
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{Name: b.Name, State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.Cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+	return status
+}
+
+// Breakers returns the status of the circuit breakers of e and the providers
+// it is made of.
+func Breakers(e Enricher) []BreakerStatus {
+	statuses := []BreakerStatus{}
+	switch e := e.(type) {
+	case Chain:
+		for _, provider := range e {
+			statuses = append(statuses, Breakers(provider)...)
+		}
+	case *Cache:
+		statuses = append(statuses, Breakers(e.Next)...)
+	case *HTTPEnricher:
+		if e.Breaker != nil {
+			statuses = append(statuses, e.Breaker.Status())
+		}
+	}
+	return statuses
+}
+
+// Status is the state of the enrichment providers.
+type Status struct {
+	Breakers []BreakerStatus `json:"breakers"`
+	Cache    *CacheStats     `json:"cache,omitempty"`
+}
@@ -0,0 +1,244 @@
+package enrich
+
+import (
+	"SongLibrary/internal/models"
+	"SongLibrary/pkg/logger"
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Cache modes of Config.
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheTable  = "table"
+)
+
+const (
+	defaultCacheSize     = 10000
+	defaultCacheTTL      = 24 * time.Hour
+	defaultCacheNegative = time.Hour
+)
+
+// CacheEntry is a cached answer for a song: its details, or that it is not
+// known (NotFound).
+type CacheEntry struct {
+	Details   Details
+	NotFound  bool
+	ExpiresAt time.Time
+}
+
+// CacheStore keeps cache entries by key. Get reports false for keys it does
+// not have; expiry is up to the Cache.
+type CacheStore interface {
+	Get(ctx context.Context, key string) (CacheEntry, bool, error)
+	Put(ctx context.Context, key string, entry CacheEntry) error
+	// Delete removes the entry of key, reporting whether there was one.
+	Delete(ctx context.Context, key string) (bool, error)
+	// Clear removes every entry, returning how many there were.
+	Clear(ctx context.Context) (int, error)
+}
+
+// Cache answers from Store what Next answered before, for TTL, and
+// remembers songs Next does not know for NegativeTTL (0 turns that off).
+// Other errors are not cached.
+type Cache struct {
+	Next        Enricher
+	Store       CacheStore
+	Mode        string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	hits, misses atomic.Int64
+	now          func() time.Time
+}
+
+// CacheStats counts the lookups of a Cache.
+type CacheStats struct {
+	Mode   string `json:"mode" enums:"memory,table" example:"memory"`
+	Hits   int64  `json:"hits" example:"120"`
+	Misses int64  `json:"misses" example:"14"`
+}
+
+func NewCache(next Enricher, store CacheStore, mode string, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{Next: next, Store: store, Mode: mode, TTL: ttl, NegativeTTL: negativeTTL, now: time.Now}
+}
+
+func (c *Cache) Enrich(ctx context.Context, group, song string) (Details, error) {
+	key := Key(group, song)
+	entry, ok, err := c.Store.Get(ctx, key)
+	if err != nil {
+		logger.Log.WithError(err).Warn("Failed to read enrichment cache")
+	}
+	if ok && c.now().Before(entry.ExpiresAt) {
+		c.hits.Add(1)
+		logger.Log.Debugf("Enrichment cache hit for %q by %q", song, group)
+		if entry.NotFound {
+			return Details{}, ErrNotFound
+		}
+		return entry.Details, nil
+	}
+	c.misses.Add(1)
+
+	details, err := c.Next.Enrich(ctx, group, song)
+	switch {
+	case err == nil:
+		c.put(ctx, key, CacheEntry{Details: details, ExpiresAt: c.now().Add(c.TTL)})
+	case errors.Is(err, ErrNotFound) && c.NegativeTTL > 0:
+		c.put(ctx, key, CacheEntry{NotFound: true, ExpiresAt: c.now().Add(c.NegativeTTL)})
+	}
+	return details, err
+}
+
+func (c *Cache) put(ctx context.Context, key string, entry CacheEntry) {
+	if err := c.Store.Put(ctx, key, entry); err != nil {
+		logger.Log.WithError(err).Warn("Failed to write enrichment cache")
+	}
+}
+
+// Invalidate drops the entry of a song, reporting whether there was one.
+func (c *Cache) Invalidate(ctx context.Context, group, song string) (bool, error) {
+	return c.Store.Delete(ctx, Key(group, song))
+}
+
+// Clear drops every entry, returning how many there were.
+func (c *Cache) Clear(ctx context.Context) (int, error) {
+	return c.Store.Clear(ctx)
+}
+
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{Mode: c.Mode, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// CacheOf returns the cache in front of e, or nil without one.
+func CacheOf(e Enricher) *Cache {
+	cache, _ := e.(*Cache)
+	return cache
+}
+
+// LRU is an in-memory CacheStore of at most Size entries, dropping the least
+// recently used one to make room.
+type LRU struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List // of *lruItem, most recently used first
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+	l.order.MoveToFront(element)
+	return element.Value.(*lruItem).entry, true, nil
+}
+
+func (l *LRU) Put(ctx context.Context, key string, entry CacheEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if element, ok := l.entries[key]; ok {
+		element.Value.(*lruItem).entry = entry
+		l.order.MoveToFront(element)
+		return nil
+	}
+	l.entries[key] = l.order.PushFront(&lruItem{key, entry})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruItem).key)
+	}
+	return nil
+}
+
+func (l *LRU) Delete(ctx context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.entries[key]
+	if ok {
+		l.order.Remove(element)
+		delete(l.entries, key)
+	}
+	return ok, nil
+}
+
+func (l *LRU) Clear(ctx context.Context) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.order.Len()
+	l.order.Init()
+	l.entries = make(map[string]*list.Element)
+	return n, nil
+}
+
+// TableStore is a CacheStore in the enrichment_cache table, which outlives
+// restarts and is shared by every instance of the service.
+type TableStore struct {
+	DB *gorm.DB
+}
+
+func (s TableStore) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	var row models.EnrichmentCacheEntry
+	err := s.DB.WithContext(ctx).Where("cache_key = ?", key).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	entry := CacheEntry{
+		Details:   Details{Text: row.Text, Link: row.Link},
+		NotFound:  row.NotFound,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.ReleaseDate != nil {
+		entry.Details.ReleaseDate = *row.ReleaseDate
+	}
+	return entry, true, nil
+}
+
+func (s TableStore) Put(ctx context.Context, key string, entry CacheEntry) error {
+	row := models.EnrichmentCacheEntry{
+		CacheKey:  key,
+		Text:      entry.Details.Text,
+		Link:      entry.Details.Link,
+		NotFound:  entry.NotFound,
+		ExpiresAt: entry.ExpiresAt,
+	}
+	if !entry.Details.ReleaseDate.IsZero() {
+		releaseDate := entry.Details.ReleaseDate
+		row.ReleaseDate = &releaseDate
+	}
+	return s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"release_date", "text", "link", "not_found", "expires_at", "created_at"}),
+	}).Create(&row).Error
+}
+
+func (s TableStore) Delete(ctx context.Context, key string) (bool, error) {
+	result := s.DB.WithContext(ctx).Where("cache_key = ?", key).Delete(&models.EnrichmentCacheEntry{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s TableStore) Clear(ctx context.Context) (int, error) {
+	result := s.DB.WithContext(ctx).Where("1 = 1").Delete(&models.EnrichmentCacheEntry{})
+	return int(result.RowsAffected), result.Error
+}
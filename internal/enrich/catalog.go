@@ -0,0 +1,55 @@
+package enrich
+
+import (
+	"SongLibrary/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Catalog answers from a fixed list of songs, e.g. a curated file used
+// before or instead of the external API.
+type Catalog map[string]Details
+
+// catalogEntry is a song of a catalog file.
+type catalogEntry struct {
+	Group       string `json:"group"`
+	Song        string `json:"song"`
+	ReleaseDate string `json:"release_date"`
+	Text        string `json:"text"`
+	Link        string `json:"link"`
+}
+
+// LoadCatalog reads a catalog from a JSON array of
+// {"group", "song", "release_date", "text", "link"} objects.
+func LoadCatalog(path string) (Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []catalogEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+
+	catalog := make(Catalog, len(entries))
+	for i, entry := range entries {
+		details := Details{Text: entry.Text, Link: entry.Link}
+		if entry.ReleaseDate != "" {
+			if details.ReleaseDate, err = models.ParseDate(entry.ReleaseDate); err != nil {
+				return nil, fmt.Errorf("invalid catalog %s, song %d: %w", path, i+1, err)
+			}
+		}
+		catalog[Key(entry.Group, entry.Song)] = details
+	}
+	return catalog, nil
+}
+
+func (c Catalog) Enrich(ctx context.Context, group, song string) (Details, error) {
+	details, ok := c[Key(group, song)]
+	if !ok {
+		return Details{}, ErrNotFound
+	}
+	return details, nil
+}
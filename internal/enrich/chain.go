@@ -0,0 +1,41 @@
+package enrich
+
+import (
+	"SongLibrary/pkg/logger"
+	"context"
+	"errors"
+)
+
+// Chain asks its providers in order until the details are complete, each
+// one filling in what the ones before did not know. A provider that fails is
+// skipped. Chain fails only if no provider knows anything: with ErrNotFound
+// when none knows the song, otherwise with the first other error.
+type Chain []Enricher
+
+func (c Chain) Enrich(ctx context.Context, group, song string) (Details, error) {
+	var details Details
+	var firstErr error
+	found := false
+	for _, provider := range c {
+		next, err := provider.Enrich(ctx, group, song)
+		if err != nil {
+			logger.Log.WithError(err).Debugf("Enrichment provider %T failed", provider)
+			if firstErr == nil && !errors.Is(err, ErrNotFound) {
+				firstErr = err
+			}
+			continue
+		}
+		details, found = details.merge(next), true
+		if details.Complete() {
+			break
+		}
+	}
+
+	switch {
+	case found:
+		return details, nil
+	case firstErr != nil:
+		return Details{}, firstErr
+	}
+	return Details{}, ErrNotFound
+}
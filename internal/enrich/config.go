@@ -0,0 +1,162 @@
+package enrich
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config selects and sets up the enrichment providers.
+type Config struct {
+	// Providers is a comma-separated list of "http" (the external API) and
+	// "catalog" (a catalog file). Several names make a Chain in that order.
+	Providers string
+
+	// Settings of http; see HTTPEnricher. A zero Timeout means none, a zero
+	// BreakerThreshold no circuit breaker.
+	ExternalAPIURL   string
+	Timeout          time.Duration
+	Retries          int
+	Backoff          time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	CatalogFile string // catalog
+
+	// Cache is "none", "memory" (an LRU of CacheSize entries) or "table"
+	// (the enrichment_cache table). See Cache for the TTLs.
+	Cache         string
+	CacheSize     int
+	CacheTTL      time.Duration
+	CacheNegative time.Duration
+}
+
+// ConfigFromEnv reads ENRICHMENT_PROVIDERS (default http), EXTERNAL_API_URL
+// (default http://localhost:8081), EXTERNAL_API_TIMEOUT, EXTERNAL_API_RETRIES,
+// EXTERNAL_API_BACKOFF, BREAKER_THRESHOLD, BREAKER_COOLDOWN,
+// ENRICHMENT_CATALOG, ENRICHMENT_CACHE (default memory),
+// ENRICHMENT_CACHE_SIZE (default 10000), ENRICHMENT_CACHE_TTL (default 24h)
+// and ENRICHMENT_CACHE_NEGATIVE_TTL (default 1h), using the defaults of
+// NewHTTP for unset ones.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Providers:        os.Getenv("ENRICHMENT_PROVIDERS"),
+		ExternalAPIURL:   os.Getenv("EXTERNAL_API_URL"),
+		Timeout:          defaultTimeout,
+		Retries:          defaultRetries,
+		Backoff:          defaultBackoff,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+		CatalogFile:      os.Getenv("ENRICHMENT_CATALOG"),
+		Cache:            os.Getenv("ENRICHMENT_CACHE"),
+		CacheSize:        defaultCacheSize,
+		CacheTTL:         defaultCacheTTL,
+		CacheNegative:    defaultCacheNegative,
+	}
+	if cfg.Providers == "" {
+		cfg.Providers = "http"
+	}
+	if cfg.ExternalAPIURL == "" {
+		cfg.ExternalAPIURL = "http://localhost:8081"
+	}
+	if cfg.Cache == "" {
+		cfg.Cache = CacheMemory
+	}
+
+	for _, env := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"EXTERNAL_API_TIMEOUT", &cfg.Timeout},
+		{"EXTERNAL_API_BACKOFF", &cfg.Backoff},
+		{"BREAKER_COOLDOWN", &cfg.BreakerCooldown},
+		{"ENRICHMENT_CACHE_TTL", &cfg.CacheTTL},
+		{"ENRICHMENT_CACHE_NEGATIVE_TTL", &cfg.CacheNegative},
+	} {
+		if value := os.Getenv(env.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return Config{}, fmt.Errorf("invalid %s %q, expected a duration such as 5s", env.name, value)
+			}
+			*env.dst = d
+		}
+	}
+	for _, env := range []struct {
+		name string
+		dst  *int
+	}{
+		{"EXTERNAL_API_RETRIES", &cfg.Retries},
+		{"BREAKER_THRESHOLD", &cfg.BreakerThreshold},
+		{"ENRICHMENT_CACHE_SIZE", &cfg.CacheSize},
+	} {
+		if value := os.Getenv(env.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return Config{}, fmt.Errorf("invalid %s %q, expected a number", env.name, value)
+			}
+			*env.dst = n
+		}
+	}
+	return cfg, nil
+}
+
+// New builds the providers of cfg, behind a cache unless it is "none". db
+// is only used by the table cache.
+func New(cfg Config, db *gorm.DB) (Enricher, error) {
+	var chain Chain
+	for _, name := range strings.Split(cfg.Providers, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+			continue
+		case "http":
+			if cfg.ExternalAPIURL == "" {
+				return nil, fmt.Errorf("enrichment provider http needs an external API URL")
+			}
+			provider := NewHTTP(cfg.ExternalAPIURL)
+			provider.Timeout, provider.Retries, provider.Backoff = cfg.Timeout, cfg.Retries, cfg.Backoff
+			provider.Breaker = nil
+			if cfg.BreakerThreshold > 0 {
+				provider.Breaker = NewBreaker(name, cfg.BreakerThreshold, cfg.BreakerCooldown)
+			}
+			chain = append(chain, provider)
+		case "catalog":
+			if cfg.CatalogFile == "" {
+				return nil, fmt.Errorf("enrichment provider catalog needs a catalog file")
+			}
+			catalog, err := LoadCatalog(cfg.CatalogFile)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, catalog)
+		default:
+			return nil, fmt.Errorf("unknown enrichment provider %q, expected http or catalog", name)
+		}
+	}
+
+	var enricher Enricher = chain
+	switch len(chain) {
+	case 0:
+		return nil, fmt.Errorf("no enrichment provider given")
+	case 1:
+		enricher = chain[0]
+	}
+
+	switch cfg.Cache {
+	case "", CacheNone:
+		return enricher, nil
+	case CacheMemory:
+		if cfg.CacheSize <= 0 {
+			return nil, fmt.Errorf("enrichment cache memory needs a size")
+		}
+		return NewCache(enricher, NewLRU(cfg.CacheSize), cfg.Cache, cfg.CacheTTL, cfg.CacheNegative), nil
+	case CacheTable:
+		if db == nil {
+			return nil, fmt.Errorf("enrichment cache table needs a database")
+		}
+		return NewCache(enricher, TableStore{DB: db}, cfg.Cache, cfg.CacheTTL, cfg.CacheNegative), nil
+	}
+	return nil, fmt.Errorf("unknown enrichment cache %q, expected none, memory or table", cfg.Cache)
+}
@@ -0,0 +1,84 @@
+package enrich
+
+import (
+	"SongLibrary/internal/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound means the provider does not know the song.
+	ErrNotFound = errors.New("song is unknown to the enrichment provider")
+	// ErrUnavailable means the provider could not be asked.
+	ErrUnavailable = errors.New("enrichment provider is unavailable")
+	// ErrInvalidResponse means the provider answered with something that
+	// cannot be read.
+	ErrInvalidResponse = errors.New("invalid response from enrichment provider")
+)
+
+// Details is what a provider knows about a song. Zero fields are unknown.
+type Details struct {
+	ReleaseDate time.Time `json:"release_date"`
+	Text        string    `json:"text"`
+	Link        string    `json:"link"`
+}
+
+// Complete reports whether every field is known.
+func (d Details) Complete() bool {
+	return !d.ReleaseDate.IsZero() && d.Text != "" && d.Link != ""
+}
+
+// merge fills the unknown fields of d from other.
+func (d Details) merge(other Details) Details {
+	if d.ReleaseDate.IsZero() {
+		d.ReleaseDate = other.ReleaseDate
+	}
+	if d.Text == "" {
+		d.Text = other.Text
+	}
+	if d.Link == "" {
+		d.Link = other.Link
+	}
+	return d
+}
+
+// Enricher looks up the release date, lyrics and link of a song.
+type Enricher interface {
+	Enrich(ctx context.Context, group, song string) (Details, error)
+}
+
+// Func adapts a function to Enricher.
+type Func func(ctx context.Context, group, song string) (Details, error)
+
+func (f Func) Enrich(ctx context.Context, group, song string) (Details, error) {
+	return f(ctx, group, song)
+}
+
+// Key identifies a song across spellings: the group name as groups are
+// matched, the song name trimmed and in lower case.
+func Key(group, song string) string {
+	return models.NormalizeGroupName(group) + "\x1f" + strings.ToLower(strings.TrimSpace(song))
+}
+
+// FillSong sets the fields of song that are empty from e, and when it was
+// enriched.
+func FillSong(ctx context.Context, e Enricher, song *models.Song) error {
+	details, err := e.Enrich(ctx, song.GroupName, song.SongName)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	song.EnrichedAt = &now
+	if song.ReleaseDate.IsZero() {
+		song.ReleaseDate = details.ReleaseDate
+	}
+	if song.Text == "" {
+		song.Text = details.Text
+	}
+	if song.Link == "" {
+		song.Link = details.Link
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPEnricher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("song") {
+		case "Known":
+			assert.Equal(t, "/info", r.URL.Path)
+			assert.Equal(t, "AC/DC", r.URL.Query().Get("group"))
+			json.NewEncoder(w).Encode(map[string]string{"releaseDate": "16.07.2006", "text": "la", "link": "l"})
+		case "Dotted":
+			json.NewEncoder(w).Encode(map[string]string{"releaseDate": "2006.07.16"})
+		case "Broken":
+			w.Write([]byte("{"))
+		case "Down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	enricher := NewHTTP(server.URL)
+	enricher.Backoff = time.Millisecond
+	ctx := context.Background()
+
+	_, err := enricher.Enrich(ctx, "AC/DC", "Known")
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	details, err := enricher.Enrich(ctx, "Muse", "Dotted")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC), details.ReleaseDate)
+	assert.False(t, details.Complete())
+
+	_, err = enricher.Enrich(ctx, "Muse", "Broken")
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	_, err = enricher.Enrich(ctx, "Muse", "Down")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	_, err = enricher.Enrich(ctx, "Muse", "Other")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = (&HTTPEnricher{BaseURL: "http://127.0.0.1:0", Client: http.DefaultClient}).Enrich(ctx, "Muse", "Other")
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestHTTPEnricherRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch r.URL.Query().Get("song") {
+		case "Flaky":
+			if n < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "Limited":
+			if n == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "Later":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "Slow":
+			time.Sleep(50 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"releaseDate": "2006-07-16"})
+	}))
+	defer server.Close()
+	enricher := NewHTTP(server.URL)
+	enricher.Backoff = time.Millisecond
+	ctx := context.Background()
+
+	_, err := enricher.Enrich(ctx, "g", "Flaky")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, calls.Swap(0))
+
+	_, err = enricher.Enrich(ctx, "g", "Limited")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, calls.Swap(0))
+
+	// Waiting longer than MaxBackoff is not worth it.
+	_, err = enricher.Enrich(ctx, "g", "Later")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.EqualValues(t, 1, calls.Swap(0))
+
+	enricher.Timeout, enricher.Retries = 10*time.Millisecond, 1
+	_, err = enricher.Enrich(ctx, "g", "Slow")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.EqualValues(t, 2, calls.Swap(0))
+
+	assert.Equal(t, 90*time.Second, parseRetryAfter("90"))
+	assert.InDelta(t, time.Hour, parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), float64(time.Second))
+	assert.Zero(t, parseRetryAfter("soon"))
+	for attempt := 0; attempt < 40; attempt++ {
+		wait := enricher.backoff(attempt)
+		assert.True(t, wait > 0 && wait <= enricher.MaxBackoff, wait)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewBreaker("http", 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, BreakerOpen, breaker.Status().State)
+	assert.Equal(t, now.Add(time.Minute), *breaker.Status().RetryAt)
+	assert.False(t, breaker.Allow())
+
+	// One trial once the cooldown is over; its failure opens again.
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+	breaker.Failure()
+	assert.False(t, breaker.Allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.Abandon()
+	assert.False(t, breaker.Allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.Success()
+	assert.Equal(t, BreakerStatus{Name: "http", State: BreakerClosed}, breaker.Status())
+	assert.True(t, breaker.Allow())
+
+	statuses := Breakers(Chain{Catalog{}, &HTTPEnricher{Breaker: breaker}, &HTTPEnricher{}})
+	assert.Len(t, statuses, 1)
+}
+
+func TestChain(t *testing.T) {
+	date := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixed := func(details Details, err error) Func {
+		return func(ctx context.Context, group, song string) (Details, error) { return details, err }
+	}
+	ctx := context.Background()
+
+	details, err := Chain{
+		fixed(Details{}, ErrUnavailable),
+		fixed(Details{Text: "first"}, nil),
+		fixed(Details{}, ErrNotFound),
+		fixed(Details{ReleaseDate: date, Text: "second", Link: "link"}, nil),
+		fixed(Details{}, nil),
+	}.Enrich(ctx, "g", "s")
+	require.NoError(t, err)
+	assert.Equal(t, Details{ReleaseDate: date, Text: "first", Link: "link"}, details)
+
+	_, err = Chain{fixed(Details{}, ErrNotFound), fixed(Details{}, ErrUnavailable)}.Enrich(ctx, "g", "s")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	_, err = Chain{fixed(Details{}, ErrNotFound)}.Enrich(ctx, "g", "s")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"group": "The  Beatles", "song": "Yesterday ", "release_date": "1965-08-06", "text": "All my troubles"}
+	]`), 0o644))
+
+	enricher, err := New(Config{Providers: "catalog", CatalogFile: path}, nil)
+	require.NoError(t, err)
+	details, err := enricher.Enrich(context.Background(), "the beatles", "YESTERDAY")
+	require.NoError(t, err)
+	assert.Equal(t, "All my troubles", details.Text)
+	_, err = enricher.Enrich(context.Background(), "The Beatles", "Help!")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	enricher, err = New(Config{Providers: "catalog, http", CatalogFile: path, ExternalAPIURL: "http://api"}, nil)
+	require.NoError(t, err)
+	assert.Len(t, enricher, 2)
+
+	enricher, err = New(Config{Providers: "catalog", CatalogFile: path, Cache: CacheMemory, CacheSize: 10}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, CacheOf(enricher))
+	assert.Equal(t, CacheMemory, CacheOf(enricher).Stats().Mode)
+
+	for _, cfg := range []Config{
+		{Providers: ""},
+		{Providers: "http"},
+		{Providers: "catalog"},
+		{Providers: "musicbrainz"},
+		{Providers: "catalog", CatalogFile: filepath.Join(t.TempDir(), "missing.json")},
+		{Providers: "catalog", CatalogFile: path, Cache: CacheTable},
+		{Providers: "catalog", CatalogFile: path, Cache: "redis"},
+	} {
+		_, err = New(cfg, nil)
+		assert.Error(t, err, cfg.Providers)
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	next := Func(func(ctx context.Context, group, song string) (Details, error) {
+		calls.Add(1)
+		switch song {
+		case "Missing":
+			return Details{}, ErrNotFound
+		case "Down":
+			return Details{}, ErrUnavailable
+		}
+		return Details{Text: song + " lyrics"}, nil
+	})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewCache(next, NewLRU(2), CacheMemory, time.Hour, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	// Lookups are by normalized names.
+	details, err := cache.Enrich(ctx, "The  Beatles", "Yesterday")
+	require.NoError(t, err)
+	assert.Equal(t, "Yesterday lyrics", details.Text)
+	details, err = cache.Enrich(ctx, "the beatles", " yesterday")
+	require.NoError(t, err)
+	assert.Equal(t, "Yesterday lyrics", details.Text)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Songs the provider does not know are remembered for the negative TTL,
+	// failures not at all.
+	_, err = cache.Enrich(ctx, "The Beatles", "Missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = cache.Enrich(ctx, "The Beatles", "Missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, int32(2), calls.Load())
+	_, err = cache.Enrich(ctx, "The Beatles", "Down")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	_, err = cache.Enrich(ctx, "The Beatles", "Down")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, int32(4), calls.Load())
+
+	now = now.Add(2 * time.Minute)
+	_, err = cache.Enrich(ctx, "The Beatles", "Missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, int32(5), calls.Load())
+
+	// The LRU holds two entries, so Help! drops Yesterday, used least
+	// recently.
+	_, err = cache.Enrich(ctx, "The Beatles", "Help!")
+	require.NoError(t, err)
+	_, err = cache.Enrich(ctx, "The Beatles", "Yesterday")
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), calls.Load())
+
+	now = now.Add(2 * time.Hour)
+	_, err = cache.Enrich(ctx, "The Beatles", "Yesterday")
+	require.NoError(t, err)
+	assert.Equal(t, int32(8), calls.Load())
+
+	removed, err := cache.Invalidate(ctx, "the beatles", "yesterday")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = cache.Invalidate(ctx, "the beatles", "yesterday")
+	require.NoError(t, err)
+	assert.False(t, removed)
+	n, err := cache.Clear(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.Equal(t, CacheStats{Mode: CacheMemory, Hits: 2, Misses: 8}, cache.Stats())
+}
@@ -0,0 +1,211 @@
+package enrich
+
+import (
+	"SongLibrary/internal/models"
+	"SongLibrary/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrCircuitOpen is returned without asking the upstream while its circuit
+// breaker is open.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", ErrUnavailable)
+
+// HTTPEnricher asks an external API at BaseURL+"/info?group=&song=", which
+// answers {"releaseDate", "text", "link"}.
+//
+// Each attempt has Timeout to complete. Network errors, 5xx and 429 answers
+// are retried up to Retries times, waiting Backoff, then twice as long each
+// time up to MaxBackoff, with jitter; a Retry-After header replaces the wait,
+// and one longer than MaxBackoff ends the retries. Breaker, if set, stops
+// asking an upstream that keeps failing.
+type HTTPEnricher struct {
+	BaseURL    string
+	Client     *http.Client
+	Timeout    time.Duration
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Breaker    *Breaker
+}
+
+// Defaults of NewHTTP and ConfigFromEnv.
+const (
+	defaultTimeout          = 5 * time.Second
+	defaultRetries          = 2
+	defaultBackoff          = 200 * time.Millisecond
+	defaultMaxBackoff       = 5 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+func NewHTTP(baseURL string) *HTTPEnricher {
+	return &HTTPEnricher{
+		BaseURL:    baseURL,
+		Client:     &http.Client{},
+		Timeout:    defaultTimeout,
+		Retries:    defaultRetries,
+		Backoff:    defaultBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		Breaker:    NewBreaker("http", defaultBreakerThreshold, defaultBreakerCooldown),
+	}
+}
+
+// statusError is an answer of the upstream that is worth retrying.
+type statusError struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%v: status %d", ErrUnavailable, e.status)
+}
+
+func (e *statusError) Unwrap() error {
+	return ErrUnavailable
+}
+
+func (e *HTTPEnricher) Enrich(ctx context.Context, group, song string) (Details, error) {
+	apiURL := fmt.Sprintf("%s/info?group=%s&song=%s",
+		e.BaseURL, url.QueryEscape(group), url.QueryEscape(song))
+
+	if e.Breaker != nil && !e.Breaker.Allow() {
+		logger.Log.Warn("External API circuit breaker is open, not requesting")
+		return Details{}, ErrCircuitOpen
+	}
+
+	details, err := e.fetchWithRetries(ctx, apiURL)
+
+	if e.Breaker != nil {
+		switch {
+		case ctx.Err() != nil:
+			e.Breaker.Abandon()
+		case errors.Is(err, ErrUnavailable):
+			e.Breaker.Failure()
+		default:
+			e.Breaker.Success()
+		}
+	}
+	return details, err
+}
+
+func (e *HTTPEnricher) fetchWithRetries(ctx context.Context, apiURL string) (Details, error) {
+	for attempt := 0; ; attempt++ {
+		details, err := e.fetch(ctx, apiURL)
+		if err == nil || !errors.Is(err, ErrUnavailable) || attempt >= e.Retries || ctx.Err() != nil {
+			return details, err
+		}
+
+		wait := e.backoff(attempt)
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.retryAfter > 0 {
+			if statusErr.retryAfter > e.MaxBackoff {
+				logger.Log.Warnf("External API asks to retry after %s, giving up", statusErr.retryAfter)
+				return details, err
+			}
+			wait = statusErr.retryAfter
+		}
+
+		logger.Log.WithError(err).Debugf("Retrying external API in %s (attempt %d of %d)", wait, attempt+2, e.Retries+1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Details{}, fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is the wait before retry attempt+1: half of Backoff*2^attempt,
+// capped at MaxBackoff, plus a random part up to the other half.
+func (e *HTTPEnricher) backoff(attempt int) time.Duration {
+	wait := e.Backoff << attempt
+	if wait > e.MaxBackoff || wait <= 0 {
+		wait = e.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+func (e *HTTPEnricher) fetch(ctx context.Context, apiURL string) (Details, error) {
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	logger.Log.Debugf("Requesting external API: %s", apiURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return Details{}, err
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to contact external API")
+		return Details{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	logger.Log.Debugf("External API response status: %d", resp.StatusCode)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return Details{}, ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		logger.Log.Warnf("External API returned status %d", resp.StatusCode)
+		return Details{}, &statusError{resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After"))}
+	case resp.StatusCode != http.StatusOK:
+		logger.Log.Warnf("External API returned non-200 status: %d", resp.StatusCode)
+		return Details{}, fmt.Errorf("%w: status %d", ErrInvalidResponse, resp.StatusCode)
+	}
+
+	var externalData struct {
+		ReleaseDate string `json:"releaseDate"`
+		Text        string `json:"text"`
+		Link        string `json:"link"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&externalData); err != nil {
+		logger.Log.WithError(err).Error("Failed to parse external API response")
+		if ctx.Err() != nil {
+			return Details{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return Details{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	logger.Log.Debugf("External API data: %+v", externalData)
+
+	details := Details{Text: externalData.Text, Link: externalData.Link}
+	if externalData.ReleaseDate != "" {
+		if details.ReleaseDate, err = models.ParseDate(externalData.ReleaseDate); err != nil {
+			logger.Log.WithError(err).Error("Invalid date format from external API")
+			return Details{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+	}
+	return details, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning 0 when there is none.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAlbumsHandler godoc
+// @Summary      Get albums
+// @Description  Get list of albums with filtering and pagination
+// @Tags         albums
+// @Param        group  query     string false  "Group name"
+// @Param        title  query     string false  "Album title"
+// @Param        page   query     int    false  "Page number"
+// @Param        limit  query     int    false  "Items per page"
+// @Success      200  {array}   models.Album
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /albums [get]
+func GetAlbumsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /albums request")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid page parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		filter := models.AlbumFilter{
+			GroupName: c.Query("group"),
+			Title:     c.Query("title"),
+			Page:      page,
+			Limit:     limit,
+		}
+
+		albums, err := models.GetAlbums(db, filter)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch albums from database")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Log.Infof("Found %d albums matching filter", len(albums))
+		c.JSON(http.StatusOK, albums)
+	}
+}
+
+// GetAlbumHandler godoc
+// @Summary      Get album
+// @Description  Get an album by its ID together with its track list in disc and track order
+// @Tags         albums
+// @Produce      json
+// @Param        id   path      int  true  "Album ID"
+// @Success      200  {object}  models.Album
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Router       /albums/{id} [get]
+func GetAlbumHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /albums/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		album, err := models.GetAlbum(db, uint(id))
+		if err != nil {
+			logger.Log.WithError(err).Infof("Album with ID %d not found", id)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Album not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, album)
+	}
+}
+
+// CreateAlbumHandler godoc
+// @Summary      Add album
+// @Description  Add a new album for a group
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        album  body      models.AlbumInput  true  "Album"
+// @Success      201    {object}  models.Album
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /albums [post]
+func CreateAlbumHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /albums request")
+
+		var input models.AlbumInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		releaseDate, err := parseOptionalDate(input.ReleaseDate)
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid date format")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+			return
+		}
+
+		album := models.Album{
+			GroupName:   input.Group,
+			Title:       input.Title,
+			ReleaseDate: releaseDate,
+		}
+
+		if err = models.CreateAlbum(db, &album); err != nil {
+			writeAlbumError(c, err)
+			return
+		}
+
+		logger.Log.Infof("Album successfully created: Group=%s, Title=%s", album.GroupName, album.Title)
+		c.JSON(http.StatusCreated, album)
+	}
+}
+
+// UpdateAlbumHandler godoc
+// @Summary      Update album
+// @Description  Update an existing album by its ID
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                true  "Album ID"
+// @Param        album  body      models.AlbumInput  true  "Updated album"
+// @Success      200    {object}  models.Album
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /albums/{id} [put]
+func UpdateAlbumHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling PUT /albums/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.AlbumInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		releaseDate, err := parseOptionalDate(input.ReleaseDate)
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid date format")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+			return
+		}
+
+		album := models.Album{
+			ID:          uint(id),
+			GroupName:   input.Group,
+			Title:       input.Title,
+			ReleaseDate: releaseDate,
+		}
+
+		if err = models.UpdateAlbum(db, &album); err != nil {
+			writeAlbumError(c, err)
+			return
+		}
+
+		logger.Log.Infof("Album updated successfully: ID %d", id)
+		c.JSON(http.StatusOK, album)
+	}
+}
+
+// DeleteAlbumHandler godoc
+// @Summary      Delete album
+// @Description  Delete an album and its track list by its ID; the songs are kept
+// @Tags         albums
+// @Produce      json
+// @Param        id   path      int  true  "Album ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /albums/{id} [delete]
+func DeleteAlbumHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /albums/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		if err = models.DeleteAlbum(db, uint(id)); err != nil {
+			writeAlbumError(c, err)
+			return
+		}
+
+		logger.Log.Infof("Album deleted successfully: ID %d", id)
+		c.JSON(http.StatusOK, gin.H{"message": "Album deleted"})
+	}
+}
+
+// AddAlbumTrackHandler godoc
+// @Summary      Add track to album
+// @Description  Place a song on an album at a disc and track number, or move it if it is already there. Without a track number the song is appended to the disc.
+// @Tags         albums
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                     true  "Album ID"
+// @Param        track  body      models.AlbumTrackInput  true  "Song and position"
+// @Success      200    {object}  models.AlbumTrack
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      409    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /albums/{id}/tracks [post]
+func AddAlbumTrackHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /albums/:id/tracks request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.AlbumTrackInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		track := models.AlbumTrack{
+			AlbumID:     uint(id),
+			SongID:      input.SongID,
+			DiscNumber:  input.DiscNumber,
+			TrackNumber: input.TrackNumber,
+		}
+
+		if err = models.AddAlbumTrack(db, &track); err != nil {
+			writeAlbumError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, track)
+	}
+}
+
+// RemoveAlbumTrackHandler godoc
+// @Summary      Remove track from album
+// @Description  Take a song off an album; the song itself is kept
+// @Tags         albums
+// @Produce      json
+// @Param        id      path      int  true  "Album ID"
+// @Param        songId  path      int  true  "Song ID"
+// @Success      200     {object}  map[string]interface{}
+// @Failure      400     {object}  map[string]interface{}
+// @Failure      404     {object}  map[string]interface{}
+// @Failure      500     {object}  map[string]interface{}
+// @Router       /albums/{id}/tracks/{songId} [delete]
+func RemoveAlbumTrackHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /albums/:id/tracks/:songId request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		songID, err := strconv.Atoi(c.Param("songId"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid song ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song ID"})
+			return
+		}
+
+		if err = models.RemoveAlbumTrack(db, uint(id), uint(songID)); err != nil {
+			writeAlbumError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Track removed"})
+	}
+}
+
+func writeAlbumError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidGroupName),
+		errors.Is(err, models.ErrInvalidAlbumTitle),
+		errors.Is(err, models.ErrInvalidTrackNumber):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Album or song not found"})
+	case errors.Is(err, models.ErrTrackPositionTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Log.WithError(err).Error("Album operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// parseOptionalDate is parseDateFlexible for fields that may be left empty.
+func parseOptionalDate(dateStr string) (*time.Time, error) {
+	if dateStr == "" {
+		return nil, nil
+	}
+	t, err := parseDateFlexible(dateStr)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAlbumTracksAndReleaseDateDefault(t *testing.T) {
+	db := setupTestDB(t)
+
+	mockExternalAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"text": "Lyrics", "link": "https://example.com"})
+	}))
+	defer mockExternalAPI.Close()
+
+	router := gin.Default()
+	router.POST("/albums", CreateAlbumHandler(db))
+	router.GET("/albums/:id", GetAlbumHandler(db))
+	router.POST("/songs", CreateSongHandler(db, enrich.NewHTTP(mockExternalAPI.URL)))
+
+	req, _ := http.NewRequest("POST", "/albums",
+		strings.NewReader(`{"group": "Portishead", "title": "Dummy", "release_date": "1994-08-22"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var album models.Album
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &album))
+
+	for _, title := range []string{"Mysterons", "Sour Times"} {
+		body := fmt.Sprintf(`{"group": "Portishead", "song": %q, "album_id": %d}`, title, album.ID)
+		req, _ = http.NewRequest("POST", "/songs", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var song models.Song
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &song))
+		assert.Equal(t, "1994-08-22", song.ReleaseDate.Format("2006-01-02"))
+	}
+
+	req, _ = http.NewRequest("GET", "/albums/"+strconv.Itoa(int(album.ID)), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var loaded models.Album
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &loaded))
+	require.Len(t, loaded.Tracks, 2)
+	assert.Equal(t, 1, loaded.Tracks[0].TrackNumber)
+	assert.Equal(t, "Mysterons", loaded.Tracks[0].Song.SongName)
+	assert.Equal(t, 2, loaded.Tracks[1].TrackNumber)
+
+	songs, err := models.GetSongs(db, models.SongFilter{Album: "dummy"})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+}
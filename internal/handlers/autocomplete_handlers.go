@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// AutocompleteHandler godoc
+// @Summary      Autocomplete
+// @Description  Distinct group or song names starting with a prefix, for type-ahead. Case and extra spaces are ignored; names used by more songs come first. Answers are cached for a couple of seconds.
+// @Tags         songs
+// @Param        field   query     string  true   "Field to complete"  Enums(group, song)
+// @Param        prefix  query     string  true   "Typed prefix"
+// @Param        limit   query     int     false  "Maximum number of completions (default and maximum 25)"
+// @Success      200     {array}   search.Completion
+// @Failure      400     {object}  map[string]interface{}
+// @Failure      500     {object}  map[string]interface{}
+// @Router       /autocomplete [get]
+func AutocompleteHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /autocomplete request")
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(models.AutocompleteMaxLimit)))
+		if err != nil || limit < 1 || limit > models.AutocompleteMaxLimit {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		completions, err := models.Autocomplete(db, c.Query("field"), c.Query("prefix"), limit)
+		if errors.Is(err, models.ErrInvalidAutocompleteField) || errors.Is(err, models.ErrInvalidAutocompletePrefix) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to autocomplete")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, completions)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"SongLibrary/internal/search"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAutocompleteHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, s := range []struct{ group, name string }{
+		{"Autocomplete Muse", "Autocomplete Uprising"},
+		{"autocomplete  muse", "Autocomplete Unintended"},
+		{"Autocomplete Muzak", "Autocomplete Unintended"},
+	} {
+		song := models.Song{GroupName: s.group, SongName: s.name, ReleaseDate: time.Now(),
+			Text: "Lyrics", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/autocomplete", AutocompleteHandler(db))
+
+	complete := func(query string) []search.Completion {
+		req, _ := http.NewRequest("GET", "/autocomplete?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var completions []search.Completion
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &completions))
+		return completions
+	}
+
+	assert.Equal(t, []search.Completion{
+		{Value: "Autocomplete Muse", Count: 2},
+		{Value: "Autocomplete Muzak", Count: 1},
+	}, complete("field=group&prefix=AUTOCOMPLETE++MU"))
+
+	assert.Equal(t, []search.Completion{
+		{Value: "Autocomplete Unintended", Count: 2},
+	}, complete("field=song&prefix=autocomplete+u&limit=1"))
+
+	// Writes refresh the completions right away, despite the answer cache.
+	song := models.Song{GroupName: "Autocomplete Mudhoney", SongName: "Autocomplete Touch Me",
+		ReleaseDate: time.Now(), Text: "Lyrics", Link: "https://link"}
+	require.NoError(t, models.CreateSong(db, &song))
+	assert.Len(t, complete("field=group&prefix=autocomplete+mu"), 3)
+
+	require.NoError(t, models.DeleteSong(db, song.ID, 0))
+	assert.Len(t, complete("field=group&prefix=autocomplete+mu"), 2)
+
+	assert.Empty(t, complete("field=song&prefix=no+such+song"))
+
+	for _, query := range []string{"field=album&prefix=a", "field=group", "field=group&prefix=a&limit=100"} {
+		req, _ := http.NewRequest("GET", "/autocomplete?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"sync"
+
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// BulkWorkers is how many songs of a bulk request are enriched from the
+// external API at the same time.
+var BulkWorkers = 8
+
+// bulkItem is an operation of a bulk request on its way to the database.
+type bulkItem struct {
+	op     models.BulkOperation
+	create models.CreateSongInput
+	song   models.Song // song to create or the new content of the updated one
+	album  *models.Album
+}
+
+// BulkSongsHandler godoc
+// @Summary      Bulk write songs
+// @Description  Create, update and delete many songs in one request. In atomic mode all operations run in one transaction and nothing is written if one fails; the others then report 424. In best_effort mode each operation succeeds or fails on its own. Every operation gets a result with the status it would have had as a single request. Creates are enriched from the external API concurrently. Updates and deletes need the song's ETag in if_match.
+// @Tags         songs
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.BulkRequest  true   "Mode and operations"
+// @Param        X-Actor  header    string              false  "Who makes the change"
+// @Success      200      {object}  models.BulkResponse
+// @Success      207      {object}  models.BulkResponse  "Some operations failed (best_effort)"
+// @Failure      400      {object}  models.BulkResponse  "Invalid request, or an operation failed (atomic)"
+// @Failure      500      {object}  map[string]interface{}
+// @Router       /songs/bulk [post]
+func BulkSongsHandler(db *gorm.DB, enricher enrich.Enricher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /songs/bulk request")
+
+		var request models.BulkRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		items := make([]bulkItem, len(request.Operations))
+		results := make([]models.BulkResult, len(request.Operations))
+		for i, op := range request.Operations {
+			items[i].op = op
+			results[i] = models.BulkResult{Index: i, Op: op.Op, ID: op.ID}
+			if err := decodeBulkOperation(&items[i]); err != nil {
+				results[i].Status, results[i].Error = requestErrorStatus(err), err.Error()
+			}
+		}
+
+		atomic := request.Mode == models.BulkAtomic
+		if !atomic || countFailed(results) == 0 {
+			enrichBulkItems(c.Request.Context(), db, enricher, items, results)
+		}
+
+		write := func(tx *gorm.DB, i int) error {
+			err := applyBulkItem(tx, &items[i], &results[i])
+			if err != nil {
+				results[i].Status, results[i].Error = writeErrorStatus(err), err.Error()
+			}
+			return err
+		}
+
+		base := withActor(c, db)
+		if atomic {
+			failed := countFailed(results) > 0
+			if !failed {
+				err := models.Transaction(base, func(tx *gorm.DB) error {
+					for i := range items {
+						if err := write(tx, i); err != nil {
+							logger.Log.WithError(err).Debugf("Bulk operation %d failed, rolling back", i)
+							return err
+						}
+					}
+					return nil
+				})
+				failed = err != nil
+			}
+			if failed {
+				rollBackBulkResults(items, results)
+			}
+		} else {
+			for i := range items {
+				if results[i].Status != 0 {
+					continue
+				}
+				if err := models.Transaction(base, func(tx *gorm.DB) error { return write(tx, i) }); err != nil {
+					logger.Log.WithError(err).Debugf("Bulk operation %d failed", i)
+				}
+			}
+		}
+
+		response := models.BulkResponse{Mode: request.Mode, Results: results}
+		response.Failed = countFailed(results)
+		response.Succeeded = len(results) - response.Failed
+
+		status := http.StatusOK
+		switch {
+		case response.Failed == 0:
+		case atomic:
+			status = firstFailure(results)
+		default:
+			status = http.StatusMultiStatus
+		}
+		logger.Log.Infof("Bulk request done: %d succeeded, %d failed", response.Succeeded, response.Failed)
+		c.JSON(status, response)
+	}
+}
+
+// decodeBulkOperation checks an operation and decodes its song.
+func decodeBulkOperation(item *bulkItem) error {
+	op := item.op
+	switch op.Op {
+	case models.BulkCreate:
+		return decodeBulkSong(op.Song, &item.create)
+	case models.BulkUpdate, models.BulkDelete:
+	default:
+		return &requestError{http.StatusBadRequest, "Unknown op, expected create, update or delete"}
+	}
+
+	if op.ID == 0 {
+		return &requestError{http.StatusBadRequest, "id is required"}
+	}
+	if op.IfMatch == "" {
+		return &requestError{http.StatusPreconditionRequired, "if_match is required"}
+	}
+	if op.Op == models.BulkDelete {
+		return nil
+	}
+
+	var input models.UpdateSongInput
+	if err := decodeBulkSong(op.Song, &input); err != nil {
+		return err
+	}
+	releaseDate, err := parseDateFlexible(input.ReleaseDate)
+	if err != nil {
+		return &requestError{http.StatusBadRequest, "Invalid date format"}
+	}
+	item.song = models.Song{
+		ID:          op.ID,
+		GroupName:   input.GroupName,
+		SongName:    input.SongName,
+		ReleaseDate: releaseDate,
+		Text:        *input.Text,
+		Link:        *input.Link,
+	}
+	return nil
+}
+
+// decodeBulkSong decodes and validates the song of an operation like
+// ShouldBindJSON does for a single request.
+func decodeBulkSong(raw json.RawMessage, input interface{}) error {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return &requestError{http.StatusBadRequest, "song is required"}
+	}
+	if err := json.Unmarshal(raw, input); err != nil {
+		return &requestError{http.StatusBadRequest, err.Error()}
+	}
+	if err := binding.Validator.ValidateStruct(input); err != nil {
+		return &requestError{http.StatusBadRequest, err.Error()}
+	}
+	return nil
+}
+
+// enrichBulkItems prepares the songs to create on BulkWorkers goroutines.
+func enrichBulkItems(ctx context.Context, db *gorm.DB, enricher enrich.Enricher, items []bulkItem, results []models.BulkResult) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(BulkWorkers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				song, album, err := prepareSong(ctx, db, enricher, items[i].create)
+				if err != nil {
+					results[i].Status, results[i].Error = requestErrorStatus(err), err.Error()
+					continue
+				}
+				items[i].song, items[i].album = song, album
+			}
+		}()
+	}
+	for i := range items {
+		if items[i].op.Op == models.BulkCreate && results[i].Status == 0 {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// applyBulkItem writes one operation and fills in its successful result.
+func applyBulkItem(tx *gorm.DB, item *bulkItem, result *models.BulkResult) error {
+	if item.op.Op == models.BulkCreate {
+		song := item.song
+		if err := saveNewSong(tx, &song, item.album, item.create); err != nil {
+			return err
+		}
+		result.Status, result.ID, result.ETag, result.Song = http.StatusCreated, song.ID, songETag(song), &song
+		return nil
+	}
+
+	existing, err := models.GetSong(tx, item.op.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &requestError{http.StatusNotFound, "Song not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if !etagMatches(item.op.IfMatch, songETag(existing), false) {
+		return models.ErrVersionMismatch
+	}
+
+	if item.op.Op == models.BulkDelete {
+		if err = models.DeleteSong(tx, existing.ID, existing.Version); err != nil {
+			return err
+		}
+		result.Status = http.StatusOK
+		return nil
+	}
+
+	song := item.song
+	song.Version = existing.Version
+	if err = models.EditSong(tx, &song); err != nil {
+		return err
+	}
+	result.Status, result.ETag, result.Song = http.StatusOK, songETag(song), &song
+	return nil
+}
+
+// rollBackBulkResults reports the operations of a failed atomic request that
+// did not fail themselves as rolled back.
+func rollBackBulkResults(items []bulkItem, results []models.BulkResult) {
+	for i := range results {
+		if results[i].Status >= http.StatusBadRequest {
+			continue
+		}
+		if items[i].op.Op == models.BulkCreate {
+			results[i].ID = 0
+		}
+		results[i].Status, results[i].Error = http.StatusFailedDependency, "Rolled back because another operation failed"
+		results[i].ETag, results[i].Song = "", nil
+	}
+}
+
+func countFailed(results []models.BulkResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Status >= http.StatusBadRequest {
+			failed++
+		}
+	}
+	return failed
+}
+
+// firstFailure is the status of the first operation that failed on its own.
+func firstFailure(results []models.BulkResult) int {
+	for _, result := range results {
+		if result.Status >= http.StatusBadRequest && result.Status != http.StatusFailedDependency {
+			return result.Status
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBulkSongsHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	enricher := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		if song == "Unknown Bulk Song" {
+			return enrich.Details{}, enrich.ErrNotFound
+		}
+		return enrich.Details{
+			ReleaseDate: time.Date(2012, 3, 4, 0, 0, 0, 0, time.UTC),
+			Text:        "Bulk lyrics",
+			Link:        "https://example.com/bulk",
+		}, nil
+	})
+
+	existing := models.Song{
+		GroupName:   "Bulk Test Group",
+		SongName:    "Bulk Existing",
+		ReleaseDate: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Text:        "Old lyrics",
+		Link:        "https://link",
+	}
+	require.NoError(t, models.CreateSong(db, &existing))
+
+	router := gin.Default()
+	router.POST("/songs/bulk", BulkSongsHandler(db, enricher))
+
+	serve := func(body interface{}) (int, models.BulkResponse) {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/songs/bulk", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response models.BulkResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+	update := map[string]string{
+		"group_name":   "Bulk Test Group",
+		"song_name":    "Bulk Existing",
+		"release_date": "2000-01-01",
+		"text":         "New lyrics",
+		"link":         "https://link",
+	}
+
+	// A failing create rolls back the whole atomic batch.
+	code, response := serve(gin.H{"mode": "atomic", "operations": []gin.H{
+		{"op": "create", "song": gin.H{"group": "Bulk Test Group", "song": "Bulk Atomic"}},
+		{"op": "update", "id": existing.ID, "if_match": `"1"`, "song": update},
+		{"op": "create", "song": gin.H{"group": "Bulk Test Group", "song": "Unknown Bulk Song"}},
+	}})
+	assert.Equal(t, http.StatusBadGateway, code)
+	assert.Equal(t, 0, response.Succeeded)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[1].Status)
+	assert.Equal(t, http.StatusBadGateway, response.Results[2].Status)
+
+	// So does a failing write.
+	code, response = serve(gin.H{"mode": "atomic", "operations": []gin.H{
+		{"op": "create", "song": gin.H{"group": "Bulk Test Group", "song": "Bulk Atomic"}},
+		{"op": "update", "id": existing.ID, "if_match": `"7"`, "song": update},
+	}})
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Zero(t, response.Results[0].ID)
+	var count int64
+	db.Model(&models.Song{}).Where("song_name = ?", "Bulk Atomic").Count(&count)
+	assert.Zero(t, count)
+	song, err := models.GetSong(db, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Old lyrics", song.Text)
+
+	// Best effort keeps what succeeds.
+	code, response = serve(gin.H{"mode": "best_effort", "operations": []gin.H{
+		{"op": "create", "song": gin.H{"group": "Bulk Test Group", "song": "Bulk Best Effort"}},
+		{"op": "update", "id": existing.ID, "if_match": `"1"`, "song": update},
+		{"op": "create", "song": gin.H{"group": "Bulk Test Group"}},
+		{"op": "delete", "id": existing.ID},
+		{"op": "delete", "id": 999999, "if_match": "*"},
+		{"op": "rename"},
+	}})
+	assert.Equal(t, http.StatusMultiStatus, code)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, 4, response.Failed)
+	statuses := make([]int, len(response.Results))
+	for i, result := range response.Results {
+		statuses[i] = result.Status
+	}
+	assert.Equal(t, []int{201, 200, 400, 428, 404, 400}, statuses)
+	assert.Equal(t, "Bulk lyrics", response.Results[0].Song.Text)
+	assert.Equal(t, `"2"`, response.Results[1].ETag)
+	// An update is an edit by hand, which protects what it changes from
+	// refreshes.
+	require.NotNil(t, response.Results[1].Song)
+	assert.Equal(t, models.FieldList{models.FieldText}, response.Results[1].Song.ProtectedFields)
+
+	code, response = serve(gin.H{"mode": "best_effort", "operations": []gin.H{
+		{"op": "delete", "id": existing.ID, "if_match": `"2"`},
+	}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, response.Succeeded)
+	_, err = models.GetSong(db, existing.ID)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"net/http"
+
+	"SongLibrary/internal/enrich"
+	"github.com/gin-gonic/gin"
+)
+
+// EnrichmentStatusHandler godoc
+// @Summary      Enrichment status
+// @Description  State of the circuit breakers in front of the enrichment providers, and the hits and misses of the cache in front of them. While a breaker is open, songs that need the provider fail with 503 until retry_at.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  enrich.Status
+// @Router       /admin/enrichment [get]
+func EnrichmentStatusHandler(enricher enrich.Enricher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /admin/enrichment request")
+		status := enrich.Status{Breakers: enrich.Breakers(enricher)}
+		if cache := enrich.CacheOf(enricher); cache != nil {
+			stats := cache.Stats()
+			status.Cache = &stats
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// InvalidateEnrichmentCacheHandler godoc
+// @Summary      Invalidate the enrichment cache
+// @Description  Drop the cached enrichment of a song, named by group and song as in POST /songs, so the next lookup asks the providers again. Without group and song the whole cache is dropped.
+// @Tags         admin
+// @Produce      json
+// @Param        group  query     string  false  "Group name"
+// @Param        song   query     string  false  "Song name"
+// @Success      200    {object}  map[string]interface{}
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /admin/enrichment/cache [delete]
+func InvalidateEnrichmentCacheHandler(enricher enrich.Enricher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /admin/enrichment/cache request")
+
+		cache := enrich.CacheOf(enricher)
+		if cache == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Enrichment cache is disabled"})
+			return
+		}
+
+		group, song := c.Query("group"), c.Query("song")
+		if (group == "") != (song == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Give both group and song, or neither"})
+			return
+		}
+
+		var invalidated int
+		var err error
+		if group == "" {
+			invalidated, err = cache.Clear(c.Request.Context())
+		} else {
+			var removed bool
+			removed, err = cache.Invalidate(c.Request.Context(), group, song)
+			if removed {
+				invalidated = 1
+			}
+		}
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to invalidate enrichment cache")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invalidate enrichment cache"})
+			return
+		}
+
+		logger.Log.Infof("Invalidated %d enrichment cache entries", invalidated)
+		c.JSON(http.StatusOK, gin.H{"invalidated": invalidated})
+	}
+}
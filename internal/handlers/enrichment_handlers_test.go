@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"SongLibrary/internal/enrich"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnrichmentCircuitBreaker(t *testing.T) {
+	db := setupTestDB(t)
+
+	mockExternalAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockExternalAPI.Close()
+
+	enricher := enrich.NewHTTP(mockExternalAPI.URL)
+	enricher.Retries = 0
+	enricher.Breaker = enrich.NewBreaker("http", 1, time.Hour)
+
+	router := gin.Default()
+	router.POST("/songs", CreateSongHandler(db, enricher))
+	router.GET("/admin/enrichment", EnrichmentStatusHandler(enricher))
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"group": "Breaker Test Group", "song": "Breaker Song"}`
+	assert.Equal(t, http.StatusBadGateway, serve("POST", "/songs", body).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve("POST", "/songs", body).Code)
+
+	w := serve("GET", "/admin/enrichment", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status enrich.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Len(t, status.Breakers, 1)
+	assert.Equal(t, enrich.BreakerOpen, status.Breakers[0].State)
+	assert.Equal(t, 1, status.Breakers[0].Failures)
+}
+
+func TestEnrichmentCache(t *testing.T) {
+	db := setupTestDB(t)
+
+	var calls int
+	next := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		calls++
+		if song == "Cache Unknown" {
+			return enrich.Details{}, enrich.ErrNotFound
+		}
+		return enrich.Details{
+			ReleaseDate: time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC),
+			Text:        "Cached lyrics",
+			Link:        "https://example.com/cached",
+		}, nil
+	})
+	enricher := enrich.NewCache(next, enrich.TableStore{DB: db}, enrich.CacheTable, time.Hour, time.Hour)
+
+	router := gin.Default()
+	router.POST("/songs", CreateSongHandler(db, enricher))
+	router.GET("/admin/enrichment", EnrichmentStatusHandler(enricher))
+	router.DELETE("/admin/enrichment/cache", InvalidateEnrichmentCacheHandler(enricher))
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The second song is the first one's lookup, under other spellings.
+	assert.Equal(t, http.StatusCreated, serve("POST", "/songs", `{"group": "Cache Test Group", "song": "Cache Song"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve("POST", "/songs", `{"group": "cache test  group", "song": "CACHE SONG"}`).Code)
+	assert.Equal(t, http.StatusBadGateway, serve("POST", "/songs", `{"group": "Cache Test Group", "song": "Cache Unknown"}`).Code)
+	assert.Equal(t, http.StatusBadGateway, serve("POST", "/songs", `{"group": "Cache Test Group", "song": "Cache Unknown"}`).Code)
+	assert.Equal(t, 2, calls)
+
+	var status enrich.Status
+	w := serve("GET", "/admin/enrichment", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotNil(t, status.Cache)
+	assert.Equal(t, enrich.CacheStats{Mode: enrich.CacheTable, Hits: 2, Misses: 2}, *status.Cache)
+
+	w = serve("DELETE", "/admin/enrichment/cache?group=Cache+Test+Group", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve("DELETE", "/admin/enrichment/cache?group=cache+test+group&song=cache+unknown", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"invalidated": 1}`, w.Body.String())
+	assert.Equal(t, http.StatusBadGateway, serve("POST", "/songs", `{"group": "Cache Test Group", "song": "Cache Unknown"}`).Code)
+	assert.Equal(t, 3, calls)
+
+	w = serve("DELETE", "/admin/enrichment/cache", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"invalidated": 2}`, w.Body.String())
+
+	router = gin.Default()
+	router.DELETE("/admin/enrichment/cache", InvalidateEnrichmentCacheHandler(next))
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/admin/enrichment/cache", "").Code)
+}
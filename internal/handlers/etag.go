@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// songETag is the strong entity tag of a song's current version.
+func songETag(song models.Song) string {
+	return `"` + strconv.FormatUint(uint64(song.Version), 10) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// lists the tag. Weak tags only match when weak is set (If-None-Match uses
+// weak comparison, If-Match strong).
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition of a write to song: the
+// header is required (428) and must name the song's current ETag or "*"
+// (412). It reports whether the write may go ahead; otherwise the response
+// has been written.
+func checkIfMatch(c *gin.Context, song models.Song) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return false
+	}
+	if !etagMatches(header, songETag(song), false) {
+		c.Header("ETag", songETag(song))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": models.ErrVersionMismatch.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormat is a file format songs can be exported in.
+type exportFormat struct {
+	contentType string
+	newWriter   func(w io.Writer) songWriter
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":   {"text/csv; charset=utf-8", func(w io.Writer) songWriter { return newCSVSongWriter(w, ',') }},
+	"tsv":   {"text/tab-separated-values; charset=utf-8", func(w io.Writer) songWriter { return newCSVSongWriter(w, '\t') }},
+	"jsonl": {"application/x-ndjson", func(w io.Writer) songWriter { return newJSONLSongWriter(w) }},
+	"json":  {"application/json; charset=utf-8", func(w io.Writer) songWriter { return &jsonSongWriter{w: w} }},
+}
+
+// ExportSongsHandler godoc
+// @Summary      Export songs
+// @Description  Download every song matching the filters of GET /songs as a file, streamed as it is read from the database. CSV and TSV have a header row and quote fields that need it (lyrics span lines); JSON Lines has one object per line, JSON an array. The columns are those POST /import reads. The response is gzip-compressed when the client accepts it.
+// @Tags         songs
+// @Produce      text/csv,text/tab-separated-values,application/x-ndjson,json
+// @Param        format           query  string  false  "File format (default csv)" Enums(csv, tsv, jsonl, json)
+// @Param        id               query  int     false  "Song ID"
+// @Param        group            query  string  false  "Group name"
+// @Param        song             query  string  false  "Song name"
+// @Param        album            query  string  false  "Album title"
+// @Param        releaseDate      query  string  false  "Release date" format(date)
+// @Param        releaseDateFrom  query  string  false  "Released on or after this date" format(date)
+// @Param        releaseDateTo    query  string  false  "Released on or before this date" format(date)
+// @Param        year             query  int     false  "Release year"
+// @Param        decade           query  string  false  "Release decade, e.g. 1990s"
+// @Param        createdAfter     query  string  false  "Created after this date or time" format(date-time)
+// @Param        updatedAfter     query  string  false  "Updated after this date or time" format(date-time)
+// @Param        text             query  string  false  "Text fragment"
+// @Param        tag              query  string  false  "Tag name"
+// @Param        tags_any         query  string  false  "Comma-separated tags, song has at least one"
+// @Param        tags_all         query  string  false  "Comma-separated tags, song has all of them"
+// @Param        status           query  string  false  "Enrichment status" Enums(ready, pending, failed)
+// @Param        filter           query  string  false  "Boolean filter expression"
+// @Param        sort             query  string  false  "Comma-separated fields to sort by, '-' for descending"
+// @Param        includeDeleted   query  bool    false  "Also export songs in the trash"
+// @Param        Accept-Encoding  header string  false  "gzip to compress the file"
+// @Success      200  {file}    file
+// @Header       200  {string}  Content-Disposition  "attachment; filename=\"songs-20060102.csv\""
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /songs/export [get]
+func ExportSongsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/export request")
+
+		name := c.DefaultQuery("format", "csv")
+		format, ok := exportFormats[name]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv, tsv, jsonl or json"})
+			return
+		}
+
+		filter, ok := songFilterFromQuery(c)
+		if !ok {
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", format.contentType)
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="songs-%s.%s"`, time.Now().Format("20060102"), name))
+		header.Set("Vary", "Accept-Encoding")
+		var out io.Writer = c.Writer
+		var gz *gzip.Writer
+		if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			header.Set("Content-Encoding", "gzip")
+			gz = gzip.NewWriter(c.Writer)
+			out = gz
+		}
+		c.Status(http.StatusOK)
+
+		writer := format.newWriter(out)
+		count := 0
+		err := models.ExportSongs(db.WithContext(c.Request.Context()), filter, func(song models.ExportedSong) error {
+			count++
+			return writer.Write(song)
+		})
+		if err == nil {
+			err = writer.Close()
+		}
+		if err == nil && gz != nil {
+			err = gz.Close()
+		}
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to export songs after %d rows", count)
+			// Once the file has started the status cannot change; the
+			// response just ends early.
+			if !c.Writer.Written() {
+				header.Del("Content-Encoding")
+				header.Del("Content-Disposition")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		logger.Log.Infof("Exported %d songs as %s", count, name)
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// songWriter writes exported songs in a file format. Close finishes the file
+// but does not close the underlying writer.
+type songWriter interface {
+	Write(song models.ExportedSong) error
+	Close() error
+}
+
+// exportColumns are the header of CSV and TSV exports, in the order of
+// exportRecord.
+var exportColumns = []string{"id", "group_name", "song_name", "release_date", "text", "link", "created_at", "updated_at"}
+
+func exportRecord(song models.ExportedSong) []string {
+	return []string{
+		strconv.FormatUint(uint64(song.ID), 10),
+		song.GroupName,
+		song.SongName,
+		song.ReleaseDate.Format("2006-01-02"),
+		song.Text,
+		song.Link,
+		song.CreatedAt.UTC().Format(time.RFC3339),
+		song.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+type csvSongWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVSongWriter(w io.Writer, comma rune) *csvSongWriter {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	return &csvSongWriter{w: writer}
+}
+
+func (w *csvSongWriter) Write(song models.ExportedSong) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.w.Write(exportRecord(song))
+}
+
+func (w *csvSongWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.w.Write(exportColumns)
+}
+
+func (w *csvSongWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonlSongWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLSongWriter(w io.Writer) *jsonlSongWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlSongWriter{enc: enc}
+}
+
+func (w *jsonlSongWriter) Write(song models.ExportedSong) error {
+	return w.enc.Encode(song)
+}
+
+func (w *jsonlSongWriter) Close() error {
+	return nil
+}
+
+// jsonSongWriter writes a JSON array one element at a time.
+type jsonSongWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonSongWriter) Write(song models.ExportedSong) error {
+	data, err := json.Marshal(song)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if w.count == 0 {
+		separator = "[\n"
+	}
+	w.count++
+	if _, err = io.WriteString(w.w, separator); err != nil {
+		return err
+	}
+	_, err = w.w.Write(data)
+	return err
+}
+
+func (w *jsonSongWriter) Close() error {
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSongsHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	for i, name := range []string{"Export B", "Export A"} {
+		song := models.Song{
+			GroupName:   "Export Test Group",
+			SongName:    name,
+			ReleaseDate: time.Date(2003+i, 3, 4, 0, 0, 0, 0, time.UTC),
+			Text:        "Line one\nLine \"two\"",
+			Link:        "https://link",
+		}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs/export", ExportSongsHandler(db))
+
+	serve := func(query string, gzipped bool) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/songs/export?group=export+test+group"+query, nil)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "br;q=1.0, gzip;q=0.5")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("&sort=song_name", false)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="songs-\d{8}\.csv"$`, w.Header().Get("Content-Disposition"))
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, exportColumns, records[0])
+	assert.Equal(t, []string{"Export Test Group", "Export A", "2004-03-04", "Line one\nLine \"two\"", "https://link"}, records[1][1:6])
+	assert.Equal(t, "Export B", records[2][2])
+
+	w = serve("&format=tsv", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	reader := csv.NewReader(gz)
+	reader.Comma = '\t'
+	records, err = reader.ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+
+	w = serve("&format=json", false)
+	require.Equal(t, http.StatusOK, w.Code)
+	var songs []models.ExportedSong
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &songs))
+	require.Len(t, songs, 2)
+	assert.Equal(t, "Export B", songs[0].SongName)
+
+	w = serve("&format=json&year=1900", false)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]\n", w.Body.String())
+
+	w = serve("&format=jsonl", false)
+	require.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	var song models.ExportedSong
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &song))
+	assert.Equal(t, "Export A", song.SongName)
+
+	w = serve("&format=xlsx", false)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve("&year=abc", true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	assert.True(t, acceptsGzip("gzip"))
+	assert.True(t, acceptsGzip("deflate, GZIP;q=0.8"))
+	assert.False(t, acceptsGzip("gzip;q=0"))
+	assert.False(t, acceptsGzip("br"))
+	assert.False(t, acceptsGzip(""))
+}
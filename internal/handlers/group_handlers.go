@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetGroupsHandler godoc
+// @Summary      Get groups
+// @Description  Get list of groups with name filtering and pagination
+// @Tags         groups
+// @Param        name   query     string false  "Group name fragment"
+// @Param        page   query     int    false  "Page number"
+// @Param        limit  query     int    false  "Items per page"
+// @Success      200  {array}   models.Group
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /groups [get]
+func GetGroupsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /groups request")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid page parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		filter := models.GroupFilter{
+			Name:  c.Query("name"),
+			Page:  page,
+			Limit: limit,
+		}
+
+		groups, err := models.GetGroups(db, filter)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch groups from database")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Log.Infof("Found %d groups matching filter", len(groups))
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// GetGroupHandler godoc
+// @Summary      Get group
+// @Description  Get a group by its ID
+// @Tags         groups
+// @Produce      json
+// @Param        id   path      int  true  "Group ID"
+// @Success      200  {object}  models.Group
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Router       /groups/{id} [get]
+func GetGroupHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /groups/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		group, err := models.GetGroup(db, uint(id))
+		if err != nil {
+			logger.Log.WithError(err).Infof("Group with ID %d not found", id)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// CreateGroupHandler godoc
+// @Summary      Add group
+// @Description  Add a new group; names differing only in case or spacing are rejected as duplicates
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        group  body      models.GroupInput  true  "Group name"
+// @Success      201    {object}  models.Group
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      409    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /groups [post]
+func CreateGroupHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /groups request")
+
+		var input models.GroupInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		group := models.Group{Name: input.Name}
+		if err := models.CreateGroup(db, &group); err != nil {
+			writeGroupError(c, err)
+			return
+		}
+
+		logger.Log.Infof("Group successfully created: %s", group.Name)
+		c.JSON(http.StatusCreated, group)
+	}
+}
+
+// UpdateGroupHandler godoc
+// @Summary      Update group
+// @Description  Rename a group by its ID
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                true  "Group ID"
+// @Param        group  body      models.GroupInput  true  "New group name"
+// @Success      200    {object}  models.Group
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      409    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /groups/{id} [put]
+func UpdateGroupHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling PUT /groups/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.GroupInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		group, err := models.UpdateGroup(db, uint(id), input.Name)
+		if err != nil {
+			writeGroupError(c, err)
+			return
+		}
+
+		logger.Log.Infof("Group updated successfully: ID %d", id)
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// DeleteGroupHandler godoc
+// @Summary      Delete group
+// @Description  Delete a group by its ID; groups that still have songs cannot be deleted
+// @Tags         groups
+// @Produce      json
+// @Param        id   path      int  true  "Group ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /groups/{id} [delete]
+func DeleteGroupHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /groups/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		if err = models.DeleteGroup(db, uint(id)); err != nil {
+			writeGroupError(c, err)
+			return
+		}
+
+		logger.Log.Infof("Group deleted successfully: ID %d", id)
+		c.JSON(http.StatusOK, gin.H{"message": "Group deleted"})
+	}
+}
+
+func writeGroupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidGroupName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, models.ErrGroupExists), errors.Is(err, models.ErrGroupHasSongs):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Log.WithError(err).Error("Group operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateGroupHandlerRejectsNearDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+
+	router := gin.Default()
+	router.POST("/groups", CreateGroupHandler(db))
+
+	req, _ := http.NewRequest("POST", "/groups", strings.NewReader(`{"name": "Radiohead"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Group
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Radiohead", created.Name)
+
+	req, _ = http.NewRequest("POST", "/groups", strings.NewReader(`{"name": "  RADIOHEAD "}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestSongsShareNormalizedGroup(t *testing.T) {
+	db := setupTestDB(t)
+
+	first := models.Song{GroupName: "Placebo", SongName: "Every You Every Me", ReleaseDate: time.Now(), Text: "t", Link: "l"}
+	second := models.Song{GroupName: "placebo ", SongName: "Pure Morning", ReleaseDate: time.Now(), Text: "t", Link: "l"}
+	require.NoError(t, models.CreateSong(db, &first))
+	require.NoError(t, models.CreateSong(db, &second))
+
+	assert.Equal(t, first.GroupID, second.GroupID)
+	assert.Equal(t, "Placebo", second.GroupName)
+
+	songs, err := models.GetSongs(db, models.SongFilter{GroupName: "PLACEBO"})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+	assert.Equal(t, "Placebo", songs[0].GroupName)
+
+	router := gin.Default()
+	router.DELETE("/groups/:id", DeleteGroupHandler(db))
+
+	req, _ := http.NewRequest("DELETE", "/groups/"+strconv.Itoa(int(first.GroupID)), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
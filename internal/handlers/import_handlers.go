@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"gorm.io/gorm"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/importer"
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// importFormats maps the content types an import body can have to formats.
+var importFormats = map[string]string{
+	"text/csv":             importer.FormatCSV,
+	"application/x-ndjson": importer.FormatJSONL,
+	"application/jsonl":    importer.FormatJSONL,
+	"application/json":     importer.FormatJSON,
+}
+
+// ImportSongsHandler godoc
+// @Summary      Import songs
+// @Description  Add songs from a CSV file with a header row, JSON Lines or a JSON array of objects, sent as the request body. Columns named group_name (or group), song_name (or song), release_date, text and link are read as those fields; columns maps other names. Rows are validated and written one by one, and every row that fails is reported. A row naming a song that exists by group and song name is skipped, overwrites the song or is merged into it, as on_duplicate says. With enrich, fields a row lacks are filled in from the external API.
+// @Tags         songs
+// @Accept       plain
+// @Produce      json
+// @Param        file          body      string  true   "CSV, JSON Lines or JSON array"
+// @Param        format        query     string  false  "Format of the body, by default from Content-Type (text/csv, application/x-ndjson, application/json)" Enums(csv, jsonl, json)
+// @Param        columns       query     string  false  "Column mapping, e.g. artist=group_name,title=song_name"
+// @Param        on_duplicate  query     string  false  "What to do with songs that exist (default skip)" Enums(skip, overwrite, merge)
+// @Param        enrich        query     bool    false  "Fill in missing fields from the external API"
+// @Param        X-Actor       header    string  false  "Who makes the change"
+// @Success      200           {object}  importer.Report
+// @Failure      400           {object}  map[string]interface{}
+// @Router       /import [post]
+func ImportSongsHandler(db *gorm.DB, enricher enrich.Enricher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /import request")
+
+		format := c.Query("format")
+		if format == "" {
+			mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+			format = importFormats[mediaType]
+		}
+
+		columns, err := importer.ParseColumns(c.Query("columns"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		opts := importer.Options{
+			Format:      format,
+			Columns:     columns,
+			OnDuplicate: c.Query("on_duplicate"),
+		}
+		if enrichStr := c.Query("enrich"); enrichStr != "" {
+			on, err := strconv.ParseBool(enrichStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid enrich"})
+				return
+			}
+			if on {
+				opts.Enricher = enricher
+			}
+		}
+
+		report, err := importer.Import(withActor(c, db), c.Request.Body, opts)
+		models.InvalidateAutocomplete()
+		if err != nil {
+			logger.Log.WithError(err).Debug("Import stopped")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/importer"
+	"SongLibrary/internal/models"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportSongsHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	enricher := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		return enrich.Details{
+			ReleaseDate: time.Date(1999, 9, 9, 0, 0, 0, 0, time.UTC),
+			Text:        "Enriched lyrics",
+			Link:        "https://example.com/enriched",
+		}, nil
+	})
+
+	router := gin.Default()
+	router.POST("/import", ImportSongsHandler(db, enricher))
+
+	serve := func(query, contentType, body string) (int, importer.Report) {
+		req, _ := http.NewRequest("POST", "/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var report importer.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	csv := "Artist,Title,release_date,text,link,ignored\n" +
+		"Import Test Group,Import One,2010-01-01,\"First line\nSecond line\",https://one,x\n" +
+		"Import Test Group,Import Two,someday,,,\n" +
+		",Import Three,2010-01-01,,,\n" +
+		"Import Test Group,Import Four,,,,\n" +
+		"Import Test Group,Import One,2011-01-01,,,\n"
+	code, report := serve("?columns=Artist=group_name,Title=song_name", "text/csv", csv)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []importer.RowError{
+		{Row: 2, Field: "release_date", Error: "unsupported date format: someday"},
+		{Row: 3, Field: "group_name", Error: "is required"},
+		{Row: 4, Field: "release_date", Error: "is required"},
+	}, report.Errors)
+
+	song, err := models.FindSongByName(db, "import test group", "import one")
+	require.NoError(t, err)
+	assert.Equal(t, "First line\nSecond line", song.Text)
+
+	// Merge keeps what the row leaves out; enrichment fills in new songs.
+	jsonl := `{"group":"Import Test Group","song":"Import One","link":"https://merged"}
+{"group":"Import Test Group","song":"Import Four"}`
+	code, report = serve("?on_duplicate=merge&enrich=true", "application/x-ndjson", jsonl)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Created)
+
+	song, err = models.FindSongByName(db, "Import Test Group", "Import One")
+	require.NoError(t, err)
+	assert.Equal(t, "First line\nSecond line", song.Text)
+	assert.Equal(t, "https://merged", song.Link)
+	song, err = models.FindSongByName(db, "Import Test Group", "Import Four")
+	require.NoError(t, err)
+	assert.Equal(t, "Enriched lyrics", song.Text)
+
+	// Overwrite replaces every field.
+	code, report = serve("?format=json&on_duplicate=overwrite", "application/octet-stream",
+		`[{"group_name":"Import Test Group","song_name":"Import One","release_date":"2012-12-12"}]`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, report.Updated)
+	song, err = models.FindSongByName(db, "Import Test Group", "Import One")
+	require.NoError(t, err)
+	assert.Empty(t, song.Text)
+	assert.Equal(t, 2012, song.ReleaseDate.Year())
+	assert.Empty(t, song.ProtectedFields)
+
+	code, _ = serve("?on_duplicate=replace", "text/csv", csv)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = serve("", "text/plain", csv)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
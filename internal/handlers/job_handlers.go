@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobHandler godoc
+// @Summary      Get enrichment job
+// @Description  Get a job enriching a song added with POST /songs?async=true. A queued job waits for run_at, after a failed attempt with last_error; a dead job has given up, and its song has status failed.
+// @Tags         jobs
+// @Produce      json
+// @Param        id   path      int  true  "Job ID"
+// @Success      200  {object}  models.EnrichmentJob
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Router       /jobs/{id} [get]
+func GetJobHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /jobs/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		job, err := models.GetEnrichmentJob(db, uint(id))
+		if err != nil {
+			logger.Log.WithError(err).Infof("Job with ID %d not found", id)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/jobs"
+	"SongLibrary/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateSongAsync(t *testing.T) {
+	db := setupTestDB(t)
+
+	enricher := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		return enrich.Details{}, enrich.ErrUnavailable
+	})
+
+	router := gin.Default()
+	router.POST("/songs", CreateSongHandler(db, enricher))
+	router.GET("/songs", GetSongsHandler(db))
+	router.GET("/jobs/:id", GetJobHandler(db))
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The upstream is down, yet the song is stored.
+	body := `{"group": "Async Test Group", "song": "Async Song"}`
+	assert.Equal(t, http.StatusBadGateway, serve("POST", "/songs", body).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/songs?async=maybe", body).Code)
+	w := serve("POST", "/songs?async=true", body)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var pending models.PendingSong
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+	assert.Equal(t, models.SongPending, pending.Song.Status)
+	assert.Equal(t, "Async Test Group", pending.Song.GroupName)
+	assert.Equal(t, models.JobQueued, pending.Job.State)
+	assert.Equal(t, fmt.Sprintf("/jobs/%d", pending.Job.ID), w.Header().Get("Location"))
+
+	w = serve("GET", "/songs?status=pending&group=Async+Test+Group", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list models.SongList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, pending.Song.ID, list.Items[0].ID)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/songs?status=done", "").Code)
+
+	// Once the upstream is back, a worker enriches it.
+	pool := jobs.NewPool(db, enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		return enrich.Details{
+			ReleaseDate: time.Date(2005, 5, 5, 0, 0, 0, 0, time.UTC),
+			Text:        "Async lyrics",
+			Link:        "https://example.com/async",
+		}, nil
+	}))
+	ran, err := pool.RunOne(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	w = serve("GET", fmt.Sprintf("/jobs/%d", pending.Job.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var job models.EnrichmentJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, models.JobDone, job.State)
+	assert.Equal(t, 1, job.Attempts)
+
+	song, err := models.GetSong(db, pending.Song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SongReady, song.Status)
+	assert.Equal(t, "Async lyrics", song.Text)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/jobs/999999", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/jobs/abc", "").Code)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setLinkHeader sets an RFC 8288 Link header with the first, prev, next and
+// last pages of a paginated response. The links repeat the request with only
+// the page parameter changed.
+func setLinkHeader(c *gin.Context, info models.PageInfo) {
+	link := func(page int, rel string) string {
+		u := *c.Request.URL
+		query := u.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(info.Limit))
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+
+	last := info.Pages
+	if last < 1 {
+		last = 1
+	}
+
+	links := []string{link(1, "first")}
+	if info.Page > 1 {
+		prev := info.Page - 1
+		if prev > last {
+			prev = last
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if info.Page < last {
+		links = append(links, link(info.Page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+
+	c.Header("Link", strings.Join(links, ", "))
+}
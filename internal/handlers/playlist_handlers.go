@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetPlaylistsHandler godoc
+// @Summary      Get playlists
+// @Description  Get list of playlists with pagination
+// @Tags         playlists
+// @Param        page   query     int  false  "Page number"
+// @Param        limit  query     int  false  "Items per page"
+// @Success      200  {array}   models.Playlist
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /playlists [get]
+func GetPlaylistsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /playlists request")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid page parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		playlists, err := models.GetPlaylists(db, page, limit)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch playlists from database")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, playlists)
+	}
+}
+
+// GetPlaylistHandler godoc
+// @Summary      Get playlist
+// @Description  Get a playlist by its ID with its entries in play order
+// @Tags         playlists
+// @Produce      json
+// @Param        id   path      int  true  "Playlist ID"
+// @Success      200  {object}  models.Playlist
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Router       /playlists/{id} [get]
+func GetPlaylistHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /playlists/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		playlist, err := models.GetPlaylist(db, uint(id))
+		if err != nil {
+			logger.Log.WithError(err).Infof("Playlist with ID %d not found", id)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, playlist)
+	}
+}
+
+// CreatePlaylistHandler godoc
+// @Summary      Add playlist
+// @Description  Create an empty playlist
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        playlist  body      models.PlaylistInput  true  "Playlist name"
+// @Success      201       {object}  models.Playlist
+// @Failure      400       {object}  map[string]interface{}
+// @Failure      500       {object}  map[string]interface{}
+// @Router       /playlists [post]
+func CreatePlaylistHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /playlists request")
+
+		var input models.PlaylistInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		playlist := models.Playlist{Name: input.Name}
+		if err := models.CreatePlaylist(db, &playlist); err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, playlist)
+	}
+}
+
+// RenamePlaylistHandler godoc
+// @Summary      Rename playlist
+// @Description  Rename a playlist by its ID
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int                   true  "Playlist ID"
+// @Param        playlist  body      models.PlaylistInput  true  "New name"
+// @Success      200       {object}  models.Playlist
+// @Failure      400       {object}  map[string]interface{}
+// @Failure      404       {object}  map[string]interface{}
+// @Failure      500       {object}  map[string]interface{}
+// @Router       /playlists/{id} [put]
+func RenamePlaylistHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling PUT /playlists/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.PlaylistInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		playlist, err := models.RenamePlaylist(db, uint(id), input.Name)
+		if err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, playlist)
+	}
+}
+
+// DeletePlaylistHandler godoc
+// @Summary      Delete playlist
+// @Description  Delete a playlist by its ID; the songs are kept
+// @Tags         playlists
+// @Produce      json
+// @Param        id   path      int  true  "Playlist ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /playlists/{id} [delete]
+func DeletePlaylistHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /playlists/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		if err = models.DeletePlaylist(db, uint(id)); err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Playlist deleted"})
+	}
+}
+
+// AddPlaylistEntryHandler godoc
+// @Summary      Add song to playlist
+// @Description  Insert a song at a position (1-based), shifting later entries down. Without a position the song is appended.
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                        true  "Playlist ID"
+// @Param        entry  body      models.PlaylistEntryInput  true  "Song and position"
+// @Success      201    {object}  models.PlaylistEntry
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /playlists/{id}/entries [post]
+func AddPlaylistEntryHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /playlists/:id/entries request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.PlaylistEntryInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry := models.PlaylistEntry{SongID: input.SongID, Position: input.Position}
+		if err = models.AddPlaylistEntry(db, uint(id), &entry); err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// RemovePlaylistEntryHandler godoc
+// @Summary      Remove entry from playlist
+// @Description  Remove one entry from a playlist; later entries move up
+// @Tags         playlists
+// @Produce      json
+// @Param        id       path      int  true  "Playlist ID"
+// @Param        entryId  path      int  true  "Entry ID"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      400      {object}  map[string]interface{}
+// @Failure      404      {object}  map[string]interface{}
+// @Failure      500      {object}  map[string]interface{}
+// @Router       /playlists/{id}/entries/{entryId} [delete]
+func RemovePlaylistEntryHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /playlists/:id/entries/:entryId request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		entryID, err := strconv.Atoi(c.Param("entryId"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid entry ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+			return
+		}
+
+		if err = models.RemovePlaylistEntry(db, uint(id), uint(entryID)); err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Entry removed"})
+	}
+}
+
+// ReorderPlaylistHandler godoc
+// @Summary      Reorder playlist
+// @Description  Set the play order by listing every entry ID of the playlist exactly once. A list that does not match the current entries (for example after a concurrent edit) returns 409.
+// @Tags         playlists
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                        true  "Playlist ID"
+// @Param        order  body      models.PlaylistOrderInput  true  "Entry IDs in the new order"
+// @Success      200    {object}  models.Playlist
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      409    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /playlists/{id}/order [put]
+func ReorderPlaylistHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling PUT /playlists/:id/order request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.PlaylistOrderInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err = models.ReorderPlaylist(db, uint(id), input.EntryIDs); err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		playlist, err := models.GetPlaylist(db, uint(id))
+		if err != nil {
+			writePlaylistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, playlist)
+	}
+}
+
+func writePlaylistError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidPlaylistName), errors.Is(err, models.ErrInvalidPosition):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist, entry or song not found"})
+	case errors.Is(err, models.ErrOrderMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Log.WithError(err).Error("Playlist operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
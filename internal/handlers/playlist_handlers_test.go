@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaylistOrderingAndSongDeletion(t *testing.T) {
+	db := setupTestDB(t)
+
+	var songs []models.Song
+	for _, name := range []string{"Intro", "Middle", "Outro"} {
+		song := models.Song{GroupName: "Setlist Band", SongName: name, ReleaseDate: time.Now(), Text: "t", Link: "l"}
+		require.NoError(t, models.CreateSong(db, &song))
+		songs = append(songs, song)
+	}
+
+	router := gin.Default()
+	router.POST("/playlists", CreatePlaylistHandler(db))
+	router.GET("/playlists/:id", GetPlaylistHandler(db))
+	router.POST("/playlists/:id/entries", AddPlaylistEntryHandler(db))
+	router.PUT("/playlists/:id/order", ReorderPlaylistHandler(db))
+	router.DELETE("/songs/:id", DeleteSongHandler(db))
+
+	req, _ := http.NewRequest("POST", "/playlists", strings.NewReader(`{"name": "Friday"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var playlist models.Playlist
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &playlist))
+	base := "/playlists/" + strconv.Itoa(int(playlist.ID))
+
+	// Outro is appended, then Intro and Middle are inserted in front of it.
+	for _, body := range []string{
+		fmt.Sprintf(`{"song_id": %d}`, songs[2].ID),
+		fmt.Sprintf(`{"song_id": %d, "position": 1}`, songs[0].ID),
+		fmt.Sprintf(`{"song_id": %d, "position": 2}`, songs[1].ID),
+	} {
+		req, _ = http.NewRequest("POST", base+"/entries", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	loaded, err := models.GetPlaylist(db, playlist.ID)
+	require.NoError(t, err)
+	require.Len(t, loaded.Entries, 3)
+	assert.Equal(t, "Intro", loaded.Entries[0].Song.SongName)
+	assert.Equal(t, "Middle", loaded.Entries[1].Song.SongName)
+	assert.Equal(t, "Outro", loaded.Entries[2].Song.SongName)
+
+	// A stale order that misses an entry is rejected.
+	body := fmt.Sprintf(`{"entry_ids": [%d, %d]}`, loaded.Entries[2].ID, loaded.Entries[0].ID)
+	req, _ = http.NewRequest("PUT", base+"/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	body = fmt.Sprintf(`{"entry_ids": [%d, %d, %d]}`, loaded.Entries[2].ID, loaded.Entries[0].ID, loaded.Entries[1].ID)
+	req, _ = http.NewRequest("PUT", base+"/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/songs/"+strconv.Itoa(int(songs[2].ID)), nil)
+	req.Header.Set("If-Match", "*")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", base, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var remaining models.Playlist
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &remaining))
+	require.Len(t, remaining.Entries, 2)
+	assert.Equal(t, 1, remaining.Entries[0].Position)
+	assert.Equal(t, "Intro", remaining.Entries[0].Song.SongName)
+	assert.Equal(t, 2, remaining.Entries[1].Position)
+	assert.Equal(t, "Middle", remaining.Entries[1].Song.SongName)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/jobs"
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxRefreshSongs bounds the number of songs one POST /songs/refresh
+// refreshes.
+const MaxRefreshSongs = 1000
+
+// RefreshSongHandler godoc
+// @Summary      Refresh song
+// @Description  Fetch a song from the enrichment providers again, bypassing the cache, and overwrite its release date, lyrics and link with the answer. Protected fields (see PUT /songs/{id}/protected_fields) are kept, as are fields the providers no longer know. A pending or failed song that gets a release date becomes ready.
+// @Tags         songs
+// @Produce      json
+// @Param        id       path      int     true   "Song ID"
+// @Param        X-Actor  header    string  false  "Who makes the change"
+// @Success      200      {object}  models.Song
+// @Header       200      {string}  ETag  "Tag of the new version"
+// @Failure      400      {object}  map[string]interface{}
+// @Failure      404      {object}  map[string]interface{}
+// @Failure      502      {object}  map[string]interface{}
+// @Failure      503      {object}  map[string]interface{}
+// @Failure      500      {object}  map[string]interface{}
+// @Router       /songs/{id}/refresh [post]
+func RefreshSongHandler(db *gorm.DB, enricher enrich.Enricher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /songs/:id/refresh request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		song, _, err := jobs.RefreshSong(c.Request.Context(), withActor(c, db), enricher, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to refresh song ID %d", id)
+			err = enrichError(err)
+			c.JSON(requestErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("ETag", songETag(song))
+		c.JSON(http.StatusOK, song)
+	}
+}
+
+// RefreshSongsHandler godoc
+// @Summary      Refresh songs
+// @Description  Refresh every song matching the filters of GET /songs as POST /songs/{id}/refresh does, several at a time, and report how it went. At most 1000 songs can be refreshed at once.
+// @Tags         songs
+// @Produce      json
+// @Param        id               query  int     false  "Song ID"
+// @Param        group            query  string  false  "Group name"
+// @Param        song             query  string  false  "Song name"
+// @Param        album            query  string  false  "Album title"
+// @Param        releaseDate      query  string  false  "Release date" format(date)
+// @Param        releaseDateFrom  query  string  false  "Released on or after this date" format(date)
+// @Param        releaseDateTo    query  string  false  "Released on or before this date" format(date)
+// @Param        year             query  int     false  "Release year"
+// @Param        decade           query  string  false  "Release decade, e.g. 1990s"
+// @Param        createdAfter     query  string  false  "Created after this date or time" format(date-time)
+// @Param        updatedAfter     query  string  false  "Updated after this date or time" format(date-time)
+// @Param        text             query  string  false  "Text fragment"
+// @Param        tag              query  string  false  "Tag name"
+// @Param        tags_any         query  string  false  "Comma-separated tags, song has at least one"
+// @Param        tags_all         query  string  false  "Comma-separated tags, song has all of them"
+// @Param        status           query  string  false  "Enrichment status" Enums(ready, pending, failed)
+// @Param        filter           query  string  false  "Boolean filter expression"
+// @Param        X-Actor          header string  false  "Who makes the change"
+// @Success      200  {object}  jobs.RefreshReport
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /songs/refresh [post]
+func RefreshSongsHandler(db *gorm.DB, enricher enrich.Enricher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /songs/refresh request")
+
+		filter, ok := songFilterFromQuery(c)
+		if !ok {
+			return
+		}
+
+		ids, err := models.SongIDs(db, filter, MaxRefreshSongs+1)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch songs to refresh")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(ids) > MaxRefreshSongs {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("More than %d songs match, narrow the filter", MaxRefreshSongs),
+			})
+			return
+		}
+
+		report := jobs.RefreshSongs(c.Request.Context(), withActor(c, db), enricher, ids, BulkWorkers)
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// SetProtectedFieldsHandler godoc
+// @Summary      Protect song fields
+// @Description  Set which of release_date, text and link refreshes leave alone; an empty list lets them overwrite every field. Manual edits protect the fields they change, as PROTECT_MANUAL_EDITS says.
+// @Tags         songs
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int                          true   "Song ID"
+// @Param        fields    body      models.ProtectedFieldsInput  true   "Protected fields"
+// @Param        If-Match  header    string                       true   "ETag of the song as last read, or *"
+// @Success      200       {object}  models.Song
+// @Header       200       {string}  ETag  "Tag of the new version"
+// @Failure      400       {object}  map[string]interface{}
+// @Failure      404       {object}  map[string]interface{}
+// @Failure      412       {object}  map[string]interface{}
+// @Failure      428       {object}  map[string]interface{}
+// @Failure      500       {object}  map[string]interface{}
+// @Router       /songs/{id}/protected_fields [put]
+func SetProtectedFieldsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling PUT /songs/:id/protected_fields request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		existing, err := models.GetSong(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !checkIfMatch(c, existing) {
+			return
+		}
+
+		var input models.ProtectedFieldsInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fields, err := models.ParseFieldList(input.Fields)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		song, err := models.SetProtectedFields(db, existing.ID, existing.Version, fields)
+		if err != nil {
+			c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("ETag", songETag(song))
+		c.JSON(http.StatusOK, song)
+	}
+}
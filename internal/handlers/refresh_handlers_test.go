@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/jobs"
+	"SongLibrary/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshSongs(t *testing.T) {
+	db := setupTestDB(t)
+
+	version := "v1"
+	enricher := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		if song == "Refresh Gone" && version != "v1" {
+			return enrich.Details{}, enrich.ErrNotFound
+		}
+		return enrich.Details{
+			ReleaseDate: time.Date(2010, 10, 10, 0, 0, 0, 0, time.UTC),
+			Text:        "Lyrics " + version,
+			Link:        "https://example.com/" + version,
+		}, nil
+	})
+
+	router := gin.Default()
+	router.POST("/songs", CreateSongHandler(db, enricher))
+	router.PUT("/songs/:id", UpdateSongHandler(db))
+	router.POST("/songs/refresh", RefreshSongsHandler(db, enricher))
+	router.POST("/songs/:id/refresh", RefreshSongHandler(db, enricher))
+	router.PUT("/songs/:id/protected_fields", SetProtectedFieldsHandler(db))
+
+	serve := func(method, url, body string, header ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) models.Song {
+		var song models.Song
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &song))
+		return song
+	}
+
+	w := serve("POST", "/songs", `{"group": "Refresh Test Group", "song": "Refresh Song"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	song := decode(w)
+	require.NotNil(t, song.EnrichedAt)
+	assert.Empty(t, song.ProtectedFields)
+	require.Equal(t, http.StatusCreated, serve("POST", "/songs", `{"group": "Refresh Test Group", "song": "Refresh Gone"}`).Code)
+
+	// A manual edit protects the lyrics it changes from the refresh.
+	w = serve("PUT", fmt.Sprintf("/songs/%d", song.ID), `{
+		"group_name": "Refresh Test Group", "song_name": "Refresh Song", "release_date": "2010-10-10",
+		"text": "My own lyrics", "link": "https://example.com/v1"
+	}`, "If-Match", songETag(song))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.FieldList{models.FieldText}, decode(w).ProtectedFields)
+
+	version = "v2"
+	w = serve("POST", fmt.Sprintf("/songs/%d/refresh", song.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	song = decode(w)
+	assert.Equal(t, "My own lyrics", song.Text)
+	assert.Equal(t, "https://example.com/v2", song.Link)
+	assert.Equal(t, songETag(song), w.Header().Get("ETag"))
+	revisions, err := models.ListRevisions(db, song.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.RevisionEnrich, revisions.Items[0].Action)
+
+	// Until the protection is lifted.
+	assert.Equal(t, http.StatusPreconditionRequired, serve("PUT", fmt.Sprintf("/songs/%d/protected_fields", song.ID), `{"fields": []}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", fmt.Sprintf("/songs/%d/protected_fields", song.ID), `{"fields": ["album"]}`, "If-Match", "*").Code)
+	w = serve("PUT", fmt.Sprintf("/songs/%d/protected_fields", song.ID), `{"fields": ["LINK"]}`, "If-Match", songETag(song))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.FieldList{models.FieldLink}, decode(w).ProtectedFields)
+
+	version = "v3"
+	w = serve("POST", "/songs/refresh?group=Refresh+Test+Group", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var report jobs.RefreshReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Matched)
+	assert.Equal(t, 1, report.Refreshed)
+	assert.Equal(t, 1, report.Changed)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, enrich.ErrNotFound.Error(), report.Errors[0].Error)
+
+	song, err = models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Lyrics v3", song.Text)
+	assert.Equal(t, "https://example.com/v2", song.Link)
+
+	// A refresh that changes nothing keeps the version, so ETags stay valid.
+	w = serve("POST", fmt.Sprintf("/songs/%d/refresh", song.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	refreshed := decode(w)
+	assert.Equal(t, song.Version, refreshed.Version)
+	assert.Equal(t, songETag(song), w.Header().Get("ETag"))
+	require.NotNil(t, refreshed.EnrichedAt)
+	assert.True(t, refreshed.EnrichedAt.After(*song.EnrichedAt))
+	stored, err := models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, song.Version, stored.Version)
+	assert.WithinDuration(t, *refreshed.EnrichedAt, *stored.EnrichedAt, time.Millisecond)
+
+	w = serve("POST", fmt.Sprintf("/songs/%d/refresh", report.Errors[0].ID), "")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/songs/999999/refresh", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/songs/refresh?status=stale", "").Code)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ActorHeader names who makes a change; it is stored with the song revisions
+// the request creates.
+const ActorHeader = "X-Actor"
+
+// withActor returns a session that records the request's actor in song
+// revisions.
+func withActor(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return db.WithContext(models.WithActor(c.Request.Context(), c.GetHeader(ActorHeader)))
+}
+
+// GetSongRevisionsHandler godoc
+// @Summary      List song revisions
+// @Description  Every recorded state of a song, newest first, with who changed it and when. Songs in the trash keep their history.
+// @Tags         revisions
+// @Param        id     path      int  true   "Song ID"
+// @Param        page   query     int  false  "Page number"
+// @Param        limit  query     int  false  "Items per page"
+// @Success      200    {object}  models.RevisionList
+// @Header       200    {string}  Link  "Links to the first, prev, next and last pages"
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      404    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /songs/{id}/revisions [get]
+func GetSongRevisionsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/:id/revisions request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid page parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		list, err := models.ListRevisions(db, uint(id), page, limit)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		setLinkHeader(c, list.PageInfo)
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// GetSongRevisionDiffHandler godoc
+// @Summary      Diff song revision
+// @Description  What a revision changed compared to the revision before it: the metadata fields that differ and a line-level diff of the lyrics
+// @Tags         revisions
+// @Param        id   path      int  true  "Song ID"
+// @Param        rev  path      int  true  "Revision number"
+// @Success      200  {object}  models.RevisionDiff
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /songs/{id}/revisions/{rev}/diff [get]
+func GetSongRevisionDiffHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/:id/revisions/:rev/diff request")
+
+		id, rev, ok := revisionParams(c)
+		if !ok {
+			return
+		}
+
+		result, err := models.DiffRevision(db, uint(id), rev)
+		if errors.Is(err, models.ErrRevisionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// RevertSongRevisionHandler godoc
+// @Summary      Revert song to revision
+// @Description  Put a song back in the state of one of its revisions. The revert is recorded as a new revision.
+// @Tags         revisions
+// @Produce      json
+// @Param        id       path      int     true   "Song ID"
+// @Param        rev      path      int     true   "Revision number"
+// @Param        X-Actor  header    string  false  "Who makes the change"
+// @Success      200      {object}  models.Song
+// @Failure      400      {object}  map[string]interface{}
+// @Failure      404      {object}  map[string]interface{}
+// @Failure      500      {object}  map[string]interface{}
+// @Router       /songs/{id}/revisions/{rev}/revert [post]
+func RevertSongRevisionHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /songs/:id/revisions/:rev/revert request")
+
+		id, rev, ok := revisionParams(c)
+		if !ok {
+			return
+		}
+
+		song, err := models.RevertSong(withActor(c, db), uint(id), rev)
+		switch {
+		case errors.Is(err, models.ErrRevisionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		case err != nil:
+			logger.Log.WithError(err).Errorf("Failed to revert song ID %d to revision %d", id, rev)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("ETag", songETag(song))
+		c.JSON(http.StatusOK, song)
+	}
+}
+
+func revisionParams(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Log.WithError(err).Debug("Invalid ID parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, 0, false
+	}
+
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || rev < 1 {
+		logger.Log.WithError(err).Debug("Invalid revision parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return 0, 0, false
+	}
+	return id, rev, true
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"SongLibrary/internal/diff"
+	"SongLibrary/internal/models"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSongRevisionHandlers(t *testing.T) {
+	db := setupTestDB(t)
+
+	song := models.Song{
+		GroupName:   "Revision Test Group",
+		SongName:    "First Draft",
+		ReleaseDate: time.Date(2001, 5, 1, 0, 0, 0, 0, time.UTC),
+		Text:        "Verse one\nVerse two",
+		Link:        "https://link",
+	}
+	require.NoError(t, models.CreateSong(db, &song))
+	_, err := models.AttachTags(db, song.ID, []string{"Revision Test Tag"}, "")
+	require.NoError(t, err)
+	id := strconv.Itoa(int(song.ID))
+
+	router := gin.Default()
+	router.PUT("/songs/:id", UpdateSongHandler(db))
+	router.GET("/songs/:id/revisions", GetSongRevisionsHandler(db))
+	router.GET("/songs/:id/revisions/:rev/diff", GetSongRevisionDiffHandler(db))
+	router.POST("/songs/:id/revisions/:rev/revert", RevertSongRevisionHandler(db))
+
+	serve := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(ActorHeader, "alice")
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	update, _ := json.Marshal(map[string]string{
+		"group_name":   "Revision Test Group",
+		"song_name":    "Final Cut",
+		"release_date": "2001-05-01",
+		"text":         "Verse one\nVerse 2",
+		"link":         "https://link",
+	})
+	w := serve("PUT", "/songs/"+id, update)
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated models.Song
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	require.Len(t, updated.Tags, 1)
+	assert.Equal(t, "Revision Test Tag", updated.Tags[0].Name)
+
+	w = serve("GET", "/songs/"+id+"/revisions/2/diff", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var changes models.RevisionDiff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changes))
+	assert.Equal(t, 1, changes.Previous)
+	assert.Equal(t, "alice", changes.Actor)
+	assert.Equal(t, []models.FieldChange{{Field: "song_name", From: "First Draft", To: "Final Cut"}}, changes.Changes)
+	assert.Equal(t, []diff.Line{
+		{Op: diff.Equal, Text: "Verse one"},
+		{Op: diff.Delete, Text: "Verse two"},
+		{Op: diff.Insert, Text: "Verse 2"},
+	}, changes.Lyrics)
+
+	w = serve("POST", "/songs/"+id+"/revisions/1/revert", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var reverted models.Song
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reverted))
+	assert.Equal(t, "First Draft", reverted.SongName)
+	assert.Equal(t, "Verse one\nVerse two", reverted.Text)
+	// The PUT protected the lyrics it changed; the revert adds nothing.
+	assert.Equal(t, models.FieldList{models.FieldText}, reverted.ProtectedFields)
+	assert.Len(t, reverted.Tags, 1)
+
+	w = serve("GET", "/songs/"+id+"/revisions", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list models.RevisionList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.EqualValues(t, 3, list.Total)
+	require.Len(t, list.Items, 3)
+	assert.Equal(t, 3, list.Items[0].Revision)
+	assert.Equal(t, models.RevisionRevert, list.Items[0].Action)
+	assert.Equal(t, 1, list.Items[0].RevertedFrom)
+	assert.Equal(t, models.RevisionCreate, list.Items[2].Action)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/songs/"+id+"/revisions/9/diff", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/songs/"+id+"/revisions/9/revert", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("GET", "/songs/"+id+"/revisions/0/diff", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/songs/999999/revisions", nil).Code)
+}
+
+func TestSongRevisionBaseline(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Songs written before revision history have no revisions.
+	song := models.Song{
+		GroupName:   "Revision Test Group",
+		SongName:    "Old Timer",
+		ReleaseDate: time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC),
+		Text:        "Old lyrics",
+		Link:        "https://link",
+	}
+	group, err := models.FindOrCreateGroup(db, song.GroupName)
+	require.NoError(t, err)
+	song.GroupID = group.ID
+	require.NoError(t, db.Omit("Group").Create(&song).Error)
+
+	song.Text = "New lyrics"
+	require.NoError(t, models.UpdateSong(db, &song))
+
+	list, err := models.ListRevisions(db, song.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	assert.Equal(t, "Old lyrics", list.Items[1].Text)
+	assert.Equal(t, "New lyrics", list.Items[0].Text)
+
+	// An update that changes nothing is not a revision.
+	require.NoError(t, models.UpdateSong(db, &song))
+	list, err = models.ListRevisions(db, song.ID, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, list.Total)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"SongLibrary/internal/search"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSearchSongsHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, song := range []models.Song{
+		{GroupName: "Search Band", SongName: "Falling Stars", Text: "We were running through the night\n\nStars keep falling down"},
+		{GroupName: "Search Band", SongName: "Night Drive", Text: "Driving in the night\n\nRunning lights on the highway"},
+		{GroupName: "Search Band", SongName: "Morning", Text: "Sunrise over quiet water"},
+	} {
+		song.ReleaseDate = time.Now()
+		song.Link = "https://link"
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs/search", SearchSongsHandler(db))
+
+	search := func(q string) (int, []models.SearchResult, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", "/songs/search?q="+url.QueryEscape(q), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var results []models.SearchResult
+		var failure map[string]interface{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		} else {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failure))
+		}
+		return w.Code, results, failure
+	}
+
+	// "run" matches "running" through stemming.
+	code, results, _ := search("run")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, results, 2)
+	assert.Contains(t, results[0].Snippet, "<mark>")
+
+	_, results, _ = search(`"running lights"`)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Night Drive", results[0].Song.SongName)
+
+	_, results, _ = search("sunr*")
+	require.Len(t, results, 1)
+	assert.Equal(t, "Morning", results[0].Song.SongName)
+
+	_, results, _ = search("night -driving")
+	require.Len(t, results, 1)
+	assert.Equal(t, "Falling Stars", results[0].Song.SongName)
+
+	_, results, _ = search("(sunrise OR stars) water")
+	require.Len(t, results, 1)
+	assert.Equal(t, "Morning", results[0].Song.SongName)
+
+	// A match in the title ranks above a match only in the lyrics.
+	_, results, _ = search("night")
+	require.Len(t, results, 2)
+	assert.Equal(t, "Night Drive", results[0].Song.SongName)
+
+	code, _, failure := search(`night "unterminated`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, float64(6), failure["position"])
+
+	code, _, _ = search("-night")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetSongsTextFilterUsesSearchIndex(t *testing.T) {
+	db := setupTestDB(t)
+
+	index := search.NewMemoryIndex()
+	models.SetSearchIndex(index)
+	defer models.SetSearchIndex(nil)
+
+	for _, song := range []models.Song{
+		{GroupName: "Index Band", SongName: "Лето", Text: "Жаркое лето, долгие ночи"},
+		{GroupName: "Index Band", SongName: "Summer Nights", Text: "Summer nights, summer nights, dancing all night long"},
+		{GroupName: "Index Band", SongName: "Summer Rain", Text: "Rain falls on a summer night"},
+	} {
+		song.ReleaseDate = time.Now()
+		song.Link = "https://link"
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+	router.POST("/admin/search/reload", ReloadSearchHandler(db))
+
+	list := func(query string) models.SongList {
+		req, _ := http.NewRequest("GET", "/songs?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var list models.SongList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return list
+	}
+	get := func(text string) []models.Song {
+		return list("text=" + url.QueryEscape(text)).Items
+	}
+
+	songs := get("summer night")
+	require.Len(t, songs, 2)
+	assert.Equal(t, "Summer Nights", songs[0].SongName)
+
+	songs = get("ночь")
+	require.Len(t, songs, 1)
+	assert.Equal(t, "Лето", songs[0].SongName)
+
+	// Ranked by relevance alone, only the most relevant matches are listed;
+	// with other filters or a sort every match is.
+	defer func(max int) { models.SearchIndexMaxHits = max }(models.SearchIndexMaxHits)
+	models.SearchIndexMaxHits = 1
+	capped := list("text=summer")
+	require.Len(t, capped.Items, 1)
+	assert.Equal(t, "Summer Nights", capped.Items[0].SongName)
+	assert.EqualValues(t, 1, capped.Total)
+	assert.True(t, capped.Truncated)
+	for _, query := range []string{"text=summer&group=Index+Band", "text=summer&sort=-song_name"} {
+		full := list(query + "&limit=1")
+		assert.EqualValues(t, 2, full.Total, query)
+		assert.Equal(t, 2, full.Pages, query)
+		assert.False(t, full.Truncated, query)
+	}
+	assert.Empty(t, list("text=summer&group=Other+Band").Items)
+	models.SearchIndexMaxHits = 1000
+
+	require.NoError(t, models.DeleteSong(db, songs[0].ID, 0))
+	assert.Empty(t, get("ночь"))
+
+	// A song written in a transaction that rolls back is not indexed.
+	err := models.Transaction(db, func(tx *gorm.DB) error {
+		song := models.Song{GroupName: "Index Rollback", SongName: "Phantom", ReleaseDate: time.Now(),
+			Text: "Phantom lyrics", Link: "https://phantom"}
+		if err := models.CreateSong(tx, &song); err != nil {
+			return err
+		}
+		return errors.New("roll back")
+	})
+	require.Error(t, err)
+	assert.Empty(t, index.Search("phantom"))
+
+	// Writes made outside the server, like a songlib import, only show up
+	// after a reload.
+	outside := models.Song{GroupName: "Index Band", SongName: "Autumn", ReleaseDate: time.Now(),
+		Text: "Leaves of autumn", Link: "https://link"}
+	require.NoError(t, models.CreateSong(db, &outside))
+	index.Delete(outside.ID)
+	index.Put(1<<30, "Vanished", "Autumn leaves that no longer exist")
+	require.Len(t, get("autumn"), 0)
+
+	req, _ := http.NewRequest("POST", "/admin/search/reload", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{outside.ID}, index.Search("autumn"))
+	require.Len(t, get("autumn"), 1)
+}
+
+func TestSuggestSongsHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, s := range []struct{ group, name string }{
+		{"Radiohead", "Paranoid Android"},
+		{"Кино", "Группа крови"},
+		{"Suggest Placebo", "Suggested Pure Morning"},
+	} {
+		song := models.Song{GroupName: s.group, SongName: s.name, ReleaseDate: time.Now(),
+			Text: "Lyrics", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs/suggest", SuggestSongsHandler(db))
+
+	suggest := func(query string) []models.SongSuggestion {
+		req, _ := http.NewRequest("GET", "/songs/suggest?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var suggestions []models.SongSuggestion
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &suggestions))
+		return suggestions
+	}
+
+	suggestions := suggest("q=Radiohaed&field=group")
+	require.NotEmpty(t, suggestions)
+	assert.Equal(t, "Paranoid Android", suggestions[0].Song.SongName)
+	assert.Equal(t, "group", suggestions[0].Field)
+	assert.Greater(t, suggestions[0].Score, 0.8)
+
+	suggestions = suggest("q=kino")
+	require.NotEmpty(t, suggestions)
+	assert.Equal(t, "Группа крови", suggestions[0].Song.SongName)
+
+	suggestions = suggest("q=sugested+pure+mornin&field=song&threshold=0.9")
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "song", suggestions[0].Field)
+
+	assert.Empty(t, suggest("q=Radiohaed&field=song&threshold=0.9"))
+
+	// 0 is a threshold of its own, not the default.
+	assert.Empty(t, suggest("q=xyzzy&field=song"))
+	assert.NotEmpty(t, suggest("q=xyzzy&field=song&threshold=0"))
+
+	for _, query := range []string{"", "q=muse&field=album", "q=muse&threshold=2", "q=muse&threshold=high"} {
+		req, _ := http.NewRequest("GET", "/songs/suggest?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -2,32 +2,51 @@ package handlers
 
 import (
 	"SongLibrary/pkg/logger"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gorm.io/gorm"
 	"net/http"
-	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"SongLibrary/internal/enrich"
 	"SongLibrary/internal/models"
+	"SongLibrary/internal/patch"
 	"github.com/gin-gonic/gin"
 )
 
-var ExternalAPIURL = "http://localhost:8081"
-
 // GetSongsHandler godoc
 // @Summary      Get songs
-// @Description  Get list of songs with filtering and pagination
+// @Description  Get list of songs with filtering and pagination. The page comes with the total number of matches and a Link header (first/prev/next/last). Passing cursor (empty for the first page) or cursor_key switches to keyset pagination, which returns a models.SongPage envelope instead.
 // @Tags         songs
-// @Param        id           query     int    false  "Song ID"
-// @Param        group        query     string false  "Group name"
-// @Param        song         query     string false  "Song name"
-// @Param        releaseDate  query     string false  "Release date" format(date)
-// @Param        text         query     string false  "Text fragment"
-// @Param        page         query     int    false  "Page number"
-// @Param        limit        query     int    false  "Items per page"
-// @Success      200  {array}  models.Song
+// @Param        id               query  int     false  "Song ID"
+// @Param        group            query  string  false  "Group name"
+// @Param        song             query  string  false  "Song name"
+// @Param        album            query  string  false  "Album title"
+// @Param        releaseDate      query  string  false  "Release date" format(date)
+// @Param        releaseDateFrom  query  string  false  "Released on or after this date" format(date)
+// @Param        releaseDateTo    query  string  false  "Released on or before this date" format(date)
+// @Param        year             query  int     false  "Release year"
+// @Param        decade           query  string  false  "Release decade, e.g. 1990s"
+// @Param        createdAfter     query  string  false  "Created after this date or time" format(date-time)
+// @Param        updatedAfter     query  string  false  "Updated after this date or time" format(date-time)
+// @Param        text             query  string  false  "Text fragment"
+// @Param        tag              query  string  false  "Tag name"
+// @Param        tags_any         query  string  false  "Comma-separated tags, song has at least one"
+// @Param        tags_all         query  string  false  "Comma-separated tags, song has all of them"
+// @Param        status           query  string  false  "Enrichment status" Enums(ready, pending, failed)
+// @Param        filter           query  string  false  "Boolean filter expression, e.g. (group = Muse OR group = Radiohead) AND NOT song ~ live"
+// @Param        page             query  int     false  "Page number"
+// @Param        limit            query  int     false  "Items per page"
+// @Param        cursor           query  string  false  "Opaque cursor from next_cursor"
+// @Param        sort             query  string  false  "Comma-separated fields to sort by, '-' for descending, e.g. release_date,-song_name"
+// @Param        cursor_key       query  string  false  "Key to page on in cursor mode, shorthand for sort" Enums(id, release_date)
+// @Param        includeDeleted   query  bool    false  "Also list songs in the trash"
+// @Success      200  {object}  models.SongList
+// @Header       200  {string}  Link  "Links to the first, prev, next and last pages"
 // @Failure      400  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Router       /songs [get]
@@ -49,50 +68,349 @@ func GetSongsHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		id := 0
-		idStr := c.Query("id")
-		if idStr != "" {
-			id, err = strconv.Atoi(idStr)
+		filter, ok := songFilterFromQuery(c)
+		if !ok {
+			return
+		}
+		filter.Page, filter.Limit = page, limit
+
+		logger.Log.Debugf("Filter parameters: %+v", filter)
+
+		cursor, cursorMode := c.GetQuery("cursor")
+		if cursorKey := c.Query("cursor_key"); cursorMode || cursorKey != "" {
+			if cursorKey != "" {
+				if cursorKey != "id" && cursorKey != "release_date" {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor_key"})
+					return
+				}
+				if len(filter.Sort) == 0 {
+					filter.Sort, _ = models.ParseSongSort(cursorKey)
+				}
+			}
+			filter.Cursor = cursor
+
+			songPage, err := models.GetSongsPage(db, filter)
+			if errors.Is(err, models.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
 			if err != nil {
-				logger.Log.WithError(err).Debug("Invalid ID parameter")
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+				logger.Log.WithError(err).Error("Failed to fetch songs from database")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+
+			logger.Log.Infof("Found %d songs matching filter", len(songPage.Items))
+			c.JSON(http.StatusOK, songPage)
+			return
+		}
+
+		list, err := models.ListSongs(db, filter)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch songs from database")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Log.Infof("Found %d songs matching filter, returning %d", list.Total, len(list.Items))
+		setLinkHeader(c, list.PageInfo)
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// songFilterFromQuery reads the filter parameters of GET /songs, without
+// paging. It reports whether they are valid; otherwise the response has been
+// written.
+func songFilterFromQuery(c *gin.Context) (models.SongFilter, bool) {
+	id := 0
+	idStr := c.Query("id")
+	if idStr != "" {
+		var err error
+		id, err = strconv.Atoi(idStr)
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return models.SongFilter{}, false
+		}
+	}
+
+	var releaseDate, releaseDateFrom, releaseDateTo, createdAfter, updatedAfter time.Time
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"releaseDate", &releaseDate},
+		{"releaseDateFrom", &releaseDateFrom},
+		{"releaseDateTo", &releaseDateTo},
+		{"createdAfter", &createdAfter},
+		{"updatedAfter", &updatedAfter},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsedDate, err := parseDateFlexible(value)
+		if err != nil {
+			logger.Log.WithError(err).Debugf("Invalid %s format", param.name)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s format", param.name)})
+			return models.SongFilter{}, false
+		}
+		*param.dst = parsedDate
+	}
+
+	year := 0
+	if yearStr := c.Query("year"); yearStr != "" {
+		var err error
+		year, err = strconv.Atoi(yearStr)
+		if err != nil || year < 1 || year > 9999 {
+			logger.Log.WithError(err).Debug("Invalid year parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return models.SongFilter{}, false
+		}
+	}
+
+	decade := 0
+	if decadeStr := c.Query("decade"); decadeStr != "" {
+		var err error
+		decade, err = parseDecade(decadeStr)
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid decade parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid decade"})
+			return models.SongFilter{}, false
 		}
+	}
 
-		releaseDateStr := c.Query("releaseDate")
-		var releaseDate time.Time
-		if releaseDateStr != "" {
-			parsedDate, err := parseDateFlexible(releaseDateStr)
+	var query *models.SongQuery
+	if filterStr := c.Query("filter"); filterStr != "" {
+		var err error
+		query, err = models.ParseSongQuery(filterStr)
+		if err != nil {
+			var queryErr *models.FilterQueryError
+			if errors.As(err, &queryErr) {
+				logger.Log.WithError(err).Debug("Invalid filter expression")
+				c.JSON(http.StatusBadRequest, gin.H{"error": queryErr.Error(), "position": queryErr.Pos})
+				return models.SongFilter{}, false
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return models.SongFilter{}, false
+		}
+	}
+
+	var sort models.SongSort
+	if sortStr := c.Query("sort"); sortStr != "" {
+		var err error
+		sort, err = models.ParseSongSort(sortStr)
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid sort parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return models.SongFilter{}, false
+		}
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.SongReady, models.SongPending, models.SongFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status, expected ready, pending or failed"})
+		return models.SongFilter{}, false
+	}
+
+	includeDeleted, err := strconv.ParseBool(c.DefaultQuery("includeDeleted", "false"))
+	if err != nil {
+		logger.Log.WithError(err).Debug("Invalid includeDeleted parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid includeDeleted"})
+		return models.SongFilter{}, false
+	}
+
+	return models.SongFilter{
+		ID:              uint(id),
+		GroupName:       c.Query("group"),
+		SongName:        c.Query("song"),
+		Album:           c.Query("album"),
+		Text:            c.Query("text"),
+		Tag:             c.Query("tag"),
+		Status:          status,
+		TagsAny:         splitList(c.Query("tags_any")),
+		TagsAll:         splitList(c.Query("tags_all")),
+		ReleaseDate:     releaseDate,
+		ReleaseDateFrom: releaseDateFrom,
+		ReleaseDateTo:   releaseDateTo,
+		CreatedAfter:    createdAfter,
+		UpdatedAfter:    updatedAfter,
+		Year:            year,
+		Decade:          decade,
+		Query:           query,
+		Sort:            sort,
+		IncludeDeleted:  includeDeleted,
+	}, true
+}
+
+// SearchSongsHandler godoc
+// @Summary      Search songs
+// @Description  Full-text search over song titles and lyrics, ranked by relevance with highlighted lyric snippets. Words are stemmed; "quoted phrases", prefix* terms, OR, -word / NOT word and parentheses are supported.
+// @Tags         songs
+// @Param        q      query     string  true   "Search query"
+// @Param        page   query     int     false  "Page number"
+// @Param        limit  query     int     false  "Items per page"
+// @Success      200    {array}   models.SearchResult
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /songs/search [get]
+func SearchSongsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/search request")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid page parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		results, err := models.SearchSongs(db, c.Query("q"), page, limit)
+		if err != nil {
+			var queryErr *models.SearchQueryError
+			if errors.As(err, &queryErr) {
+				logger.Log.WithError(err).Debug("Invalid search query")
+				c.JSON(http.StatusBadRequest, gin.H{"error": queryErr.Error(), "position": queryErr.Pos})
+				return
+			}
+			logger.Log.WithError(err).Error("Failed to search songs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Log.Infof("Search returned %d songs", len(results))
+		c.JSON(http.StatusOK, results)
+	}
+}
+
+// ReloadSearchHandler godoc
+// @Summary      Reload search
+// @Description  Bring the in-memory search index and autocomplete up to date with songs written outside the server, such as by a songlib import
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /admin/search/reload [post]
+func ReloadSearchHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /admin/search/reload request")
+
+		if err := models.ReloadSearch(db); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Search reloaded"})
+	}
+}
+
+// SuggestSongsHandler godoc
+// @Summary      Suggest songs
+// @Description  Typo-tolerant lookup of songs by group or song name, most similar first. Uses pg_trgm trigram similarity on PostgreSQL and edit distance elsewhere; Cyrillic and Latin spellings match each other.
+// @Tags         songs
+// @Param        q          query     string  true   "Group or song name, possibly misspelled"
+// @Param        field      query     string  false  "Name to match on" Enums(any, group, song)
+// @Param        threshold  query     number  false  "Minimum similarity between 0 and 1, 0 for none (default 0.3)"
+// @Param        limit      query     int     false  "Maximum number of suggestions"
+// @Success      200        {array}   models.SongSuggestion
+// @Failure      400        {object}  map[string]interface{}
+// @Failure      500        {object}  map[string]interface{}
+// @Router       /songs/suggest [get]
+func SuggestSongsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/suggest request")
+
+		q := strings.TrimSpace(c.Query("q"))
+		if q == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+
+		var threshold *float64
+		if thresholdStr := c.Query("threshold"); thresholdStr != "" {
+			value, err := strconv.ParseFloat(thresholdStr, 64)
 			if err != nil {
-				logger.Log.WithError(err).Debug("Invalid releaseDate format")
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid releaseDate format"})
-			} else {
-				releaseDate = parsedDate
+				logger.Log.WithError(err).Debug("Invalid threshold parameter")
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold"})
+				return
 			}
+			threshold = &value
 		}
 
-		filter := models.SongFilter{
-			ID:          uint(id),
-			GroupName:   c.Query("group"),
-			SongName:    c.Query("song"),
-			Text:        c.Query("text"),
-			ReleaseDate: releaseDate,
-			Page:        page,
-			Limit:       limit,
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit > 50 {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, at most 50"})
+			return
 		}
 
-		logger.Log.Debugf("Filter parameters: %+v", filter)
+		suggestions, err := models.SuggestSongs(db, q, c.Query("field"), threshold, limit)
+		if errors.Is(err, models.ErrInvalidSuggestField) || errors.Is(err, models.ErrInvalidThreshold) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to suggest songs")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Log.Infof("Suggested %d songs for '%s'", len(suggestions), q)
+		c.JSON(http.StatusOK, suggestions)
+	}
+}
+
+// GetSongHandler godoc
+// @Summary      Get song
+// @Description  Get a song by its ID. The ETag response header identifies its version: send it back in If-Match to change the song, or in If-None-Match to get 304 Not Modified while it is unchanged.
+// @Tags         songs
+// @Produce      json
+// @Param        id             path      int     true   "Song ID"
+// @Param        If-None-Match  header    string  false  "ETag of a cached copy"
+// @Success      200            {object}  models.Song
+// @Header       200            {string}  ETag  "Tag of the song's version"
+// @Success      304            "Cached copy is current"
+// @Failure      400            {object}  map[string]interface{}
+// @Failure      404            {object}  map[string]interface{}
+// @Failure      500            {object}  map[string]interface{}
+// @Router       /songs/{id} [get]
+func GetSongHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/:id request")
 
-		songs, err := models.GetSongs(db, filter)
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		song, err := models.GetSong(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
 		if err != nil {
-			logger.Log.WithError(err).Error("Failed to fetch songs from database")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		logger.Log.Infof("Found %d songs matching filter", len(songs))
-		c.JSON(http.StatusOK, songs)
+		etag := songETag(song)
+		c.Header("ETag", etag)
+		if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.JSON(http.StatusOK, song)
 	}
 }
 
@@ -100,12 +418,14 @@ func GetSongsHandler(db *gorm.DB) gin.HandlerFunc {
 // @Summary      Get song verses
 // @Description  Get paginated verses of a song by its ID (split by paragraphs)
 // @Tags         songs
-// @Param        id     path      int     true  "Song ID"
-// @Param        page   query     int     false "Page number (default 1)"
-// @Param        limit  query     int     false "Verses per page (default 3)"
-// @Success      200    {object}  map[string]interface{}
-// @Failure      400    {object}  map[string]interface{}
-// @Failure      404    {object}  map[string]interface{}
+// @Param        id              path      int     true  "Song ID"
+// @Param        page            query     int     false "Page number (default 1)"
+// @Param        limit           query     int     false "Verses per page (default 3)"
+// @Param        includeDeleted  query     bool    false "Also find songs in the trash"
+// @Success      200             {object}  models.VerseList
+// @Header       200             {string}  Link  "Links to the first, prev, next and last pages"
+// @Failure      400             {object}  map[string]interface{}
+// @Failure      404             {object}  map[string]interface{}
 // @Router       /songs/{id}/verses [get]
 func GetSongVersesHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -133,33 +453,47 @@ func GetSongVersesHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		includeDeleted, err := strconv.ParseBool(c.DefaultQuery("includeDeleted", "false"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid includeDeleted parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid includeDeleted"})
+			return
+		}
+
 		logger.Log.Debugf("Request params — ID: %d, Page: %d, Limit: %d", id, page, limit)
 
-		verses, err := models.GetSongVerses(db, uint(id), page, limit)
+		verses, err := models.GetSongVerses(db, uint(id), page, limit, includeDeleted)
 		if err != nil {
 			logger.Log.WithError(err).Infof("Song with ID %d not found", id)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
 			return
 		}
 
-		logger.Log.Infof("Returning %d verses for song ID %d", len(verses), id)
-		c.JSON(http.StatusOK, gin.H{"verses": verses})
+		logger.Log.Infof("Returning %d of %d verses for song ID %d", len(verses.Items), verses.Total, id)
+		setLinkHeader(c, verses.PageInfo)
+		c.JSON(http.StatusOK, verses)
 	}
 }
 
 // CreateSongHandler godoc
 // @Summary      Add song
-// @Description  Add song using external API enrichment
+// @Description  Add song using external API enrichment, optionally placing it on an album. With async, the song is stored right away with status pending and enriched by a background job, which GET /jobs/{id} follows; the song becomes ready, or failed with enrichment_error once the job gives up.
 // @Tags         songs
 // @Accept       json
 // @Produce      json
-// @Param        song  body  models.CreateSongInput  true  "Group and Song"
-// @Success      201   {object}  models.Song
-// @Failure      400   {object}  map[string]interface{}
-// @Failure      502   {object}  map[string]interface{}
-// @Failure      500   {object}  map[string]interface{}
+// @Param        song     body      models.CreateSongInput  true   "Group and Song"
+// @Param        async    query     bool                    false  "Enrich in the background"
+// @Param        X-Actor  header    string                  false  "Who makes the change"
+// @Success      201      {object}  models.Song
+// @Success      202      {object}  models.PendingSong
+// @Header       202      {string}  Location  "URL of the enrichment job"
+// @Failure      400      {object}  map[string]interface{}
+// @Failure      409      {object}  map[string]interface{}
+// @Failure      502      {object}  map[string]interface{}
+// @Failure      503      {object}  map[string]interface{}
+// @Failure      500      {object}  map[string]interface{}
 // @Router       /songs [post]
-func CreateSongHandler(db *gorm.DB) gin.HandlerFunc {
+func CreateSongHandler(db *gorm.DB, enricher enrich.Enricher) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.Log.Debug("Handling POST /songs request")
 
@@ -173,78 +507,93 @@ func CreateSongHandler(db *gorm.DB) gin.HandlerFunc {
 
 		logger.Log.Infof("Received new song input: Group=%s, Song=%s", input.Group, input.Song)
 
-		apiURL := fmt.Sprintf("%s/info?group=%s&song=%s",
-			ExternalAPIURL, url.QueryEscape(input.Group), url.QueryEscape(input.Song))
-
-		logger.Log.Debugf("Requesting external API: %s", apiURL)
-
-		resp, err := http.Get(apiURL)
+		async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
 		if err != nil {
-			logger.Log.WithError(err).Error("Failed to contact external API")
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to contact external API"})
-			return
-		}
-		defer resp.Body.Close()
-
-		logger.Log.Debugf("External API response status: %d", resp.StatusCode)
-
-		if resp.StatusCode != http.StatusOK {
-			logger.Log.Warnf("External API returned non-200 status: %d", resp.StatusCode)
-			c.JSON(http.StatusBadGateway, gin.H{"error": "External API returned non-200 status"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid async"})
 			return
 		}
-
-		var externalData struct {
-			ReleaseDate string `json:"releaseDate"`
-			Text        string `json:"text"`
-			Link        string `json:"link"`
-		}
-
-		if err = json.NewDecoder(resp.Body).Decode(&externalData); err != nil {
-			logger.Log.WithError(err).Error("Failed to parse external API response")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse external API response"})
+		if async {
+			createPendingSong(c, db, input)
 			return
 		}
 
-		logger.Log.Debugf("External API data: %+v", externalData)
-
-		releaseDate, err := parseDateFlexible(externalData.ReleaseDate)
+		newSong, album, err := prepareSong(c.Request.Context(), db, enricher, input)
 		if err != nil {
-			logger.Log.WithError(err).Error("Invalid date format from external API")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format from external API"})
+			c.JSON(requestErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
-		newSong := models.Song{
-			GroupName:   input.Group,
-			SongName:    input.Song,
-			ReleaseDate: releaseDate,
-			Text:        externalData.Text,
-			Link:        externalData.Link,
-		}
-
-		if err = models.CreateSong(db, &newSong); err != nil {
+		err = models.Transaction(withActor(c, db), func(tx *gorm.DB) error {
+			return saveNewSong(tx, &newSong, album, input)
+		})
+		if err != nil {
 			logger.Log.WithError(err).Error("Failed to save song in database")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
 		logger.Log.Infof("Song successfully created: Group=%s, Song=%s", newSong.GroupName, newSong.SongName)
+		c.Header("ETag", songETag(newSong))
 		c.JSON(http.StatusCreated, newSong)
 	}
 }
 
+// createPendingSong stores a song without enrichment and queues a job for
+// it, answering 202 with both.
+func createPendingSong(c *gin.Context, db *gorm.DB, input models.CreateSongInput) {
+	album, err := inputAlbum(db, input)
+	if err != nil {
+		c.JSON(requestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	pending := models.PendingSong{Song: models.Song{
+		GroupName: input.Group,
+		SongName:  input.Song,
+		Status:    models.SongPending,
+	}}
+	err = models.Transaction(withActor(c, db), func(tx *gorm.DB) error {
+		if err := saveNewSong(tx, &pending.Song, album, input); err != nil {
+			return err
+		}
+		pending.Job, err = models.EnqueueEnrichment(tx, pending.Song.ID)
+		return err
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to save pending song in database")
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// The album may have given the song its release date.
+	if song, err := models.GetSong(db, pending.Song.ID); err == nil {
+		pending.Song = song
+	}
+
+	logger.Log.Infof("Song stored pending enrichment: ID=%d, job ID=%d", pending.Song.ID, pending.Job.ID)
+	c.Header("ETag", songETag(pending.Song))
+	c.Header("Location", fmt.Sprintf("/jobs/%d", pending.Job.ID))
+	c.JSON(http.StatusAccepted, pending)
+}
+
 // UpdateSongHandler godoc
-// @Summary      Update song
-// @Description  Update an existing song by its ID
+// @Summary      Replace song
+// @Description  Replace every field of an existing song. All fields are required, though text and link may be empty; use PATCH to change some of them.
 // @Tags         songs
 // @Accept       json
 // @Produce      json
-// @Param        id    path   int            true  "Song ID"
-// @Param        song  body   models.UpdateSongInput    true  "Updated song object"
-// @Success      200   {object}  models.Song
-// @Failure      400   {object}  map[string]interface{}
-// @Failure      500   {object}  map[string]interface{}
+// @Param        id        path      int                     true   "Song ID"
+// @Param        song      body      models.UpdateSongInput  true   "Updated song object"
+// @Param        If-Match  header    string                  true   "ETag of the song as last read, or *"
+// @Param        X-Actor   header    string                  false  "Who makes the change"
+// @Success      200       {object}  models.Song
+// @Header       200       {string}  ETag  "Tag of the new version"
+// @Failure      400       {object}  map[string]interface{}
+// @Failure      404       {object}  map[string]interface{}
+// @Failure      409       {object}  map[string]interface{}
+// @Failure      412       {object}  map[string]interface{}
+// @Failure      428       {object}  map[string]interface{}
+// @Failure      500       {object}  map[string]interface{}
 // @Router       /songs/{id} [put]
 func UpdateSongHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -258,6 +607,19 @@ func UpdateSongHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		existing, err := models.GetSong(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !checkIfMatch(c, existing) {
+			return
+		}
+
 		var updateSong models.UpdateSongInput
 		if err = c.ShouldBindJSON(&updateSong); err != nil {
 			logger.Log.WithError(err).Debug("Invalid JSON input")
@@ -280,30 +642,175 @@ func UpdateSongHandler(db *gorm.DB) gin.HandlerFunc {
 			GroupName:   updateSong.GroupName,
 			SongName:    updateSong.SongName,
 			ReleaseDate: parsedDate,
-			Text:        updateSong.Text,
-			Link:        updateSong.Link,
+			Text:        *updateSong.Text,
+			Link:        *updateSong.Link,
+			Version:     existing.Version,
 		}
 
-		if err = models.UpdateSong(db, song); err != nil {
+		if err = models.EditSong(withActor(c, db), &song); err != nil {
 			logger.Log.WithError(err).Errorf("Failed to update song ID %d", id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+				return
+			}
+			if errors.Is(err, models.ErrVersionMismatch) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, models.ErrInvalidGroupName) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, models.ErrSongExists) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
 		logger.Log.Infof("Song updated successfully: ID %d", id)
+		c.Header("ETag", songETag(song))
+		c.JSON(http.StatusOK, song)
+	}
+}
+
+// PatchSongHandler godoc
+// @Summary      Patch song
+// @Description  Change only some fields of a song. The body is a JSON Merge Patch (RFC 7396, Content-Type application/merge-patch+json or application/json) or a JSON Patch (RFC 6902, Content-Type application/json-patch+json) applied to {group_name, song_name, release_date, text, link}. Every field of the result is validated on its own; invalid fields are listed in "fields".
+// @Tags         songs
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int     true   "Song ID"
+// @Param        patch     body      object  true   "Merge patch object or JSON Patch operations"
+// @Param        If-Match  header    string  true   "ETag of the song as last read, or *"
+// @Param        X-Actor   header    string  false  "Who makes the change"
+// @Success      200       {object}  models.Song
+// @Header       200       {string}  ETag  "Tag of the new version"
+// @Failure      400       {object}  map[string]interface{}
+// @Failure      404       {object}  map[string]interface{}
+// @Failure      409       {object}  map[string]interface{}
+// @Failure      412       {object}  map[string]interface{}
+// @Failure      415       {object}  map[string]interface{}
+// @Failure      428       {object}  map[string]interface{}
+// @Failure      500       {object}  map[string]interface{}
+// @Router       /songs/{id} [patch]
+func PatchSongHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling PATCH /songs/:id request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		contentType := c.ContentType()
+		if contentType != patch.MergePatchType && contentType != patch.JSONPatchType && contentType != "application/json" {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Content-Type must be " + patch.MergePatchType + " or " + patch.JSONPatchType,
+			})
+			return
+		}
+
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		existing, err := models.GetSong(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !checkIfMatch(c, existing) {
+			return
+		}
+
+		var doc interface{} = songDocument(existing)
+		if contentType == patch.JSONPatchType {
+			var ops []patch.Operation
+			if err = json.Unmarshal(body, &ops); err != nil {
+				logger.Log.WithError(err).Debug("Invalid JSON Patch")
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON Patch: " + err.Error()})
+				return
+			}
+			doc, err = patch.Apply(doc, ops)
+			if errors.Is(err, patch.ErrTestFailed) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			var mergePatch interface{}
+			if err = json.Unmarshal(body, &mergePatch); err != nil {
+				logger.Log.WithError(err).Debug("Invalid merge patch")
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch: " + err.Error()})
+				return
+			}
+			doc = patch.Merge(doc, mergePatch)
+		}
+
+		song, fieldErrors := songFromDocument(doc)
+		if len(fieldErrors) > 0 {
+			logger.Log.Debugf("Patched song ID %d is invalid: %v", id, fieldErrors)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song fields", "fields": fieldErrors})
+			return
+		}
+		song.ID = uint(id)
+		song.Version = existing.Version
+
+		if err = models.EditSong(withActor(c, db), &song); err != nil {
+			logger.Log.WithError(err).Errorf("Failed to patch song ID %d", id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+				return
+			}
+			if errors.Is(err, models.ErrVersionMismatch) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, models.ErrInvalidGroupName) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid song fields", "fields": gin.H{"group_name": err.Error()}})
+				return
+			}
+			if errors.Is(err, models.ErrSongExists) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Log.Infof("Song patched successfully: ID %d", id)
+		c.Header("ETag", songETag(song))
 		c.JSON(http.StatusOK, song)
 	}
 }
 
 // DeleteSongHandler godoc
 // @Summary      Delete song
-// @Description  Delete a song by its ID
+// @Description  Move a song to the trash. It disappears from listings and search and leaves every playlist, but keeps its tags and album tracks until it is restored or purged.
 // @Tags         songs
 // @Produce      json
-// @Param        id   path   int   true  "Song ID"
-// @Success      200  {object}  map[string]interface{}
-// @Failure      400  {object}  map[string]interface{}
-// @Failure      500  {object}  map[string]interface{}
+// @Param        id        path      int     true   "Song ID"
+// @Param        If-Match  header    string  true   "ETag of the song as last read, or *"
+// @Param        X-Actor   header    string  false  "Who makes the change"
+// @Success      200       {object}  map[string]interface{}
+// @Failure      400       {object}  map[string]interface{}
+// @Failure      404       {object}  map[string]interface{}
+// @Failure      412       {object}  map[string]interface{}
+// @Failure      428       {object}  map[string]interface{}
+// @Failure      500       {object}  map[string]interface{}
 // @Router       /songs/{id} [delete]
 func DeleteSongHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -317,28 +824,239 @@ func DeleteSongHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		existing, err := models.GetSong(db, uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !checkIfMatch(c, existing) {
+			return
+		}
+
 		logger.Log.Debugf("Deleting song with ID %d", id)
 
-		if err = models.DeleteSong(db, uint(id)); err != nil {
+		if err = models.DeleteSong(withActor(c, db), uint(id), existing.Version); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+				return
+			}
+			if errors.Is(err, models.ErrVersionMismatch) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
 			logger.Log.WithError(err).Errorf("Failed to delete song ID %d", id)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		logger.Log.Infof("Song deleted successfully: ID %d", id)
+		logger.Log.Infof("Song moved to trash: ID %d", id)
 		c.JSON(http.StatusOK, gin.H{"message": "Song deleted"})
 	}
 }
 
-func parseDateFlexible(dateStr string) (time.Time, error) {
-	formats := []string{"2006.01.02", "2006-01-02", time.RFC3339}
-	var err error
-	for _, layout := range formats {
-		var t time.Time
-		t, err = time.Parse(layout, dateStr)
-		if err == nil {
-			return t, nil
+// requestError is a failed step of handling a request, with the status it is
+// reported as.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func requestErrorStatus(err error) int {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeErrorStatus maps the errors of song writes to response statuses.
+func writeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, models.ErrInvalidGroupName), errors.Is(err, models.ErrInvalidTrackNumber):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrTrackPositionTaken), errors.Is(err, models.ErrSongExists):
+		return http.StatusConflict
+	}
+	return requestErrorStatus(err)
+}
+
+// prepareSong builds the song to add for input: it looks up the album, if
+// any, and fills in the release date, lyrics and link from the enricher.
+// Nothing is written.
+func prepareSong(ctx context.Context, db *gorm.DB, enricher enrich.Enricher, input models.CreateSongInput) (models.Song, *models.Album, error) {
+	album, err := inputAlbum(db, input)
+	if err != nil {
+		return models.Song{}, nil, err
+	}
+
+	details, err := enricher.Enrich(ctx, input.Group, input.Song)
+	if err != nil {
+		return models.Song{}, nil, enrichError(err)
+	}
+
+	if details.ReleaseDate.IsZero() && album != nil && album.ReleaseDate != nil {
+		logger.Log.Debugf("External API has no release date, using date of album ID %d", album.ID)
+		details.ReleaseDate = *album.ReleaseDate
+	}
+	if details.ReleaseDate.IsZero() {
+		return models.Song{}, nil, &requestError{http.StatusBadGateway, "External API has no release date for the song"}
+	}
+
+	now := time.Now()
+	song := models.Song{
+		GroupName:   input.Group,
+		SongName:    input.Song,
+		ReleaseDate: details.ReleaseDate,
+		Text:        details.Text,
+		Link:        details.Link,
+		EnrichedAt:  &now,
+	}
+	return song, album, nil
+}
+
+// inputAlbum looks up the album a new song goes on, nil for none.
+func inputAlbum(db *gorm.DB, input models.CreateSongInput) (*models.Album, error) {
+	if input.AlbumID == 0 {
+		return nil, nil
+	}
+	album, err := models.GetAlbum(db, input.AlbumID)
+	if err != nil {
+		logger.Log.WithError(err).Debugf("Album with ID %d not found", input.AlbumID)
+		return nil, &requestError{http.StatusBadRequest, "Album not found"}
+	}
+	return &album, nil
+}
+
+// enrichError is the response to a failed enrichment.
+func enrichError(err error) error {
+	switch {
+	case errors.Is(err, enrich.ErrCircuitOpen):
+		return &requestError{http.StatusServiceUnavailable, "External API is unavailable, try again later"}
+	case errors.Is(err, enrich.ErrNotFound):
+		return &requestError{http.StatusBadGateway, "External API does not know the song"}
+	case errors.Is(err, enrich.ErrUnavailable):
+		return &requestError{http.StatusBadGateway, "Failed to contact external API"}
+	case errors.Is(err, enrich.ErrInvalidResponse):
+		return &requestError{http.StatusBadGateway, "Invalid response from external API"}
+	}
+	return err
+}
+
+// saveNewSong creates a song made by prepareSong and puts it on its album.
+func saveNewSong(tx *gorm.DB, song *models.Song, album *models.Album, input models.CreateSongInput) error {
+	if err := models.CreateSong(tx, song); err != nil {
+		return err
+	}
+	if album == nil {
+		return nil
+	}
+	return models.AddAlbumTrack(tx, &models.AlbumTrack{
+		AlbumID:     album.ID,
+		SongID:      song.ID,
+		DiscNumber:  input.DiscNumber,
+		TrackNumber: input.TrackNumber,
+	})
+}
+
+// splitList splits a comma-separated query value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return time.Time{}, fmt.Errorf("unsupported date format: %s", dateStr)
+	return items
+}
+
+// songDocument is the JSON document patches to a song apply to.
+func songDocument(song models.Song) map[string]interface{} {
+	return map[string]interface{}{
+		"group_name":   song.GroupName,
+		"song_name":    song.SongName,
+		"release_date": song.ReleaseDate.Format("2006-01-02"),
+		"text":         song.Text,
+		"link":         song.Link,
+	}
+}
+
+// songFromDocument reads a patched song document back, checking every field
+// on its own. The errors are keyed by field name.
+func songFromDocument(doc interface{}) (models.Song, map[string]string) {
+	fields, ok := doc.(map[string]interface{})
+	if !ok {
+		return models.Song{}, map[string]string{"": "must be an object"}
+	}
+
+	errs := make(map[string]string)
+	str := func(name string, nonBlank bool) string {
+		value, ok := fields[name]
+		if !ok || value == nil {
+			errs[name] = "is required"
+			return ""
+		}
+		s, ok := value.(string)
+		if !ok {
+			errs[name] = "must be a string"
+			return ""
+		}
+		if nonBlank && strings.TrimSpace(s) == "" {
+			errs[name] = "must not be blank"
+		}
+		return s
+	}
+
+	song := models.Song{
+		GroupName: str("group_name", true),
+		SongName:  str("song_name", true),
+		Text:      str("text", false),
+		Link:      str("link", false),
+	}
+	if date := str("release_date", true); date != "" {
+		parsed, err := parseDateFlexible(date)
+		if err != nil {
+			errs["release_date"] = err.Error()
+		}
+		song.ReleaseDate = parsed
+	}
+
+	known := songDocument(models.Song{})
+	for name := range fields {
+		if _, ok := known[name]; !ok {
+			errs[name] = "unknown field"
+		}
+	}
+	return song, errs
+}
+
+// parseDateFlexible reads the dates requests carry, in the formats
+// models.ParseDate takes. The parsing lives in models because the importer,
+// the enrichment providers and the filter expressions read dates too.
+func parseDateFlexible(dateStr string) (time.Time, error) {
+	return models.ParseDate(dateStr)
+}
+
+// parseDecade accepts a decade as its first year with or without a trailing
+// "s", e.g. "1990s" or "1990".
+func parseDecade(decadeStr string) (int, error) {
+	decade, err := strconv.Atoi(strings.TrimSuffix(decadeStr, "s"))
+	if err != nil {
+		return 0, err
+	}
+	if decade < 0 || decade > 9990 || decade%10 != 0 {
+		return 0, fmt.Errorf("not a decade: %s", decadeStr)
+	}
+	return decade, nil
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"SongLibrary/internal/enrich"
 	"SongLibrary/internal/models"
 	"bytes"
 	"encoding/json"
@@ -12,6 +13,7 @@ import (
 	"gorm.io/gorm"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
@@ -21,7 +23,10 @@ import (
 func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	require.NoError(t, err)
-	err = db.AutoMigrate(&models.Song{})
+	err = db.AutoMigrate(&models.Group{}, &models.Song{}, &models.Album{}, &models.AlbumTrack{},
+		&models.Playlist{}, &models.PlaylistEntry{}, &models.Tag{}, &models.SongRevision{}, &models.EnrichmentCacheEntry{}, &models.EnrichmentJob{})
+	require.NoError(t, err)
+	err = models.SetupSearch(db)
 	require.NoError(t, err)
 	return db
 }
@@ -40,10 +45,8 @@ func TestCreateSongHandler(t *testing.T) {
 	}))
 	defer mockExternalAPI.Close()
 
-	ExternalAPIURL = mockExternalAPI.URL
-
 	router := gin.Default()
-	router.POST("/songs", CreateSongHandler(db))
+	router.POST("/songs", CreateSongHandler(db, enrich.NewHTTP(mockExternalAPI.URL)))
 
 	requestBody := `{"group": "Test Group", "song": "Test Song"}`
 	req, _ := http.NewRequest("POST", "/songs", strings.NewReader(requestBody))
@@ -60,6 +63,12 @@ func TestCreateSongHandler(t *testing.T) {
 	assert.Equal(t, "Test Group", createdSong.GroupName)
 	assert.Equal(t, "Test Song", createdSong.SongName)
 	assert.Equal(t, "Test Lyrics", createdSong.Text)
+
+	req, _ = http.NewRequest("POST", "/songs", strings.NewReader(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
 }
 
 func TestUpdateSongHandler(t *testing.T) {
@@ -89,6 +98,7 @@ func TestUpdateSongHandler(t *testing.T) {
 	url := "/songs/" + strconv.Itoa(int(song.ID))
 	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -118,6 +128,7 @@ func TestDeleteSongHandler(t *testing.T) {
 
 	url := "/songs/" + strconv.Itoa(int(song.ID))
 	req, _ := http.NewRequest("DELETE", url, nil)
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -150,9 +161,481 @@ func TestGetSongVersesHandler(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response map[string][]string
+	var response models.VerseList
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Len(t, response["verses"], 2)
-	assert.Equal(t, "Line1", response["verses"][0])
+	assert.Len(t, response.Items, 2)
+	assert.Equal(t, "Line1", response.Items[0])
+	assert.EqualValues(t, 4, response.Total)
+	assert.Equal(t, 2, response.Pages)
+	assert.Contains(t, w.Header().Get("Link"), `page=2>; rel="next"`)
+}
+
+func TestGetSongsHandlerCursorPagination(t *testing.T) {
+	db := setupTestDB(t)
+
+	dates := []string{"2001-05-01", "1999-03-10", "2001-05-01", "1985-07-22", "2010-11-30"}
+	for i, date := range dates {
+		releaseDate, _ := time.Parse("2006-01-02", date)
+		song := models.Song{GroupName: "Cursor Band", SongName: fmt.Sprintf("Cursor Song %d", i),
+			ReleaseDate: releaseDate, Text: "Lyrics", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+
+	fetchAll := func(query string) []models.Song {
+		var all []models.Song
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			req, _ := http.NewRequest("GET", "/songs?group=cursor+band&limit=2&"+query+"&cursor="+cursor, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var page models.SongPage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			all = append(all, page.Items...)
+			if !page.HasMore {
+				assert.Empty(t, page.NextCursor)
+				return all
+			}
+			require.Len(t, page.Items, 2)
+			cursor = page.NextCursor
+		}
+		t.Fatal("cursor pagination did not end")
+		return nil
+	}
+
+	byID := fetchAll("cursor_key=id")
+	require.Len(t, byID, 5)
+	for i := 1; i < len(byID); i++ {
+		assert.Less(t, byID[i-1].ID, byID[i].ID)
+	}
+
+	byDate := fetchAll("cursor_key=release_date")
+	require.Len(t, byDate, 5)
+	names := make([]string, len(byDate))
+	for i, song := range byDate {
+		names[i] = song.SongName
+	}
+	assert.Equal(t, []string{"Cursor Song 3", "Cursor Song 1", "Cursor Song 0", "Cursor Song 2", "Cursor Song 4"}, names)
+
+	req, _ := http.NewRequest("GET", "/songs?cursor=not-a-cursor", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+}
+
+func TestGetSongsHandlerPageEnvelope(t *testing.T) {
+	db := setupTestDB(t)
+
+	for i := 0; i < 5; i++ {
+		song := models.Song{GroupName: "Envelope Band", SongName: fmt.Sprintf("Envelope Song %d", i),
+			ReleaseDate: time.Now(), Text: "Lyrics", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+
+	req, _ := http.NewRequest("GET", "/songs?group=envelope+band&page=2&limit=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list models.SongList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Items, 2)
+	assert.EqualValues(t, 5, list.Total)
+	assert.Equal(t, 2, list.Page)
+	assert.Equal(t, 2, list.Limit)
+	assert.Equal(t, 3, list.Pages)
+
+	links := w.Header().Get("Link")
+	assert.Contains(t, links, `</songs?group=envelope+band&limit=2&page=1>; rel="first"`)
+	assert.Contains(t, links, `</songs?group=envelope+band&limit=2&page=1>; rel="prev"`)
+	assert.Contains(t, links, `</songs?group=envelope+band&limit=2&page=3>; rel="next"`)
+	assert.Contains(t, links, `</songs?group=envelope+band&limit=2&page=3>; rel="last"`)
+}
+
+func TestGetSongsHandlerSort(t *testing.T) {
+	db := setupTestDB(t)
+
+	songs := []struct{ name, date string }{
+		{"Sort B", "2001-05-01"}, {"Sort A", "2001-05-01"}, {"Sort C", "1999-03-10"}, {"Sort D", "2010-11-30"},
+	}
+	for _, s := range songs {
+		releaseDate, _ := time.Parse("2006-01-02", s.date)
+		song := models.Song{GroupName: "Sort Band", SongName: s.name, ReleaseDate: releaseDate,
+			Text: "Lyrics", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+
+	names := func(items []models.Song) []string {
+		result := make([]string, len(items))
+		for i, song := range items {
+			result[i] = song.SongName
+		}
+		return result
+	}
+
+	req, _ := http.NewRequest("GET", "/songs?group=sort+band&sort=-release_date,song_name", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list models.SongList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, []string{"Sort D", "Sort A", "Sort B", "Sort C"}, names(list.Items))
+
+	// Cursor pages follow the same sort, descending fields included.
+	var paged []models.Song
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		req, _ = http.NewRequest("GET", "/songs?group=sort+band&limit=1&sort=release_date,-song_name&cursor="+cursor, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page models.SongPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		paged = append(paged, page.Items...)
+		if !page.HasMore {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"Sort C", "Sort B", "Sort A", "Sort D"}, names(paged))
+
+	req, _ = http.NewRequest("GET", "/songs?group=sort+band&sort=-group_name,-song_name", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, []string{"Sort D", "Sort C", "Sort B", "Sort A"}, names(list.Items))
+
+	for _, sort := range []string{"nope", "id;DROP TABLE songs", "song_name,-song_name"} {
+		req, _ = http.NewRequest("GET", "/songs?sort="+url.QueryEscape(sort), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, sort)
+	}
+}
+
+func TestGetSongsHandlerDateFilters(t *testing.T) {
+	db := setupTestDB(t)
+
+	for i, date := range []string{"1989-12-31", "1990-01-01", "1994-06-15", "1999-12-31", "2006-03-01"} {
+		releaseDate, _ := time.Parse("2006-01-02", date)
+		song := models.Song{GroupName: "Dates Band", SongName: fmt.Sprintf("Dates %d", i),
+			ReleaseDate: releaseDate, Text: "Lyrics", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+	}
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+
+	count := func(query string) int {
+		req, _ := http.NewRequest("GET", "/songs?group=dates+band&"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, query)
+		var list models.SongList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return int(list.Total)
+	}
+
+	assert.Equal(t, 3, count("decade=1990s"))
+	assert.Equal(t, 3, count("decade=1990"))
+	assert.Equal(t, 1, count("year=2006"))
+	assert.Equal(t, 3, count("releaseDateFrom=1990-01-01&releaseDateTo=1999.12.31"))
+	assert.Equal(t, 1, count("releaseDateFrom=1994-06-15&year=1994"))
+
+	since := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, 5, count("createdAfter="+since))
+	assert.Equal(t, 0, count("updatedAfter="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)))
+
+	for _, query := range []string{"decade=1995", "decade=nineties", "year=abc", "releaseDateFrom=yesterday"} {
+		req, _ := http.NewRequest("GET", "/songs?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetSongsHandlerFilterExpression(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, s := range []struct{ group, name, date string }{
+		{"Expr Muse", "Expr Hysteria", "2003-12-01"},
+		{"Expr Muse", "Expr Hysteria (Live)", "2008-03-17"},
+		{"Expr Radiohead", "Expr Creep", "1992-09-21"},
+		{"Expr Radiohead", "Expr Creep - live", "1995-01-01"},
+		{"Expr Placebo", "Expr Pure Morning", "1998-08-03"},
+	} {
+		releaseDate, _ := time.Parse("2006-01-02", s.date)
+		song := models.Song{GroupName: s.group, SongName: s.name, ReleaseDate: releaseDate,
+			Text: "Lyrics 100%", Link: "https://link"}
+		require.NoError(t, models.CreateSong(db, &song))
+		if strings.Contains(s.name, "Creep") {
+			_, err := models.AttachTags(db, song.ID, []string{"Expr Grunge"}, models.TagKindGenre)
+			require.NoError(t, err)
+		}
+	}
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+
+	names := func(filter string) []string {
+		req, _ := http.NewRequest("GET", "/songs?sort=song_name&filter="+url.QueryEscape(filter), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list models.SongList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		result := []string{}
+		for _, song := range list.Items {
+			result = append(result, song.SongName)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Expr Creep", "Expr Hysteria"},
+		names(`(group = "expr muse" OR group = 'Expr  Radiohead') AND NOT song ~ live`))
+	assert.Equal(t, []string{"Expr Creep - live", "Expr Pure Morning"},
+		names(`group ~ expr AND release_date between 1995-01-01 and 1999-12-31`))
+	assert.Equal(t, []string{"Expr Hysteria (Live)", "Expr Pure Morning"},
+		names(`group in ("Expr Placebo", "expr muse") and release_date >= 1998.01.01 and song != "expr hysteria"`))
+	assert.Equal(t, []string{"Expr Creep", "Expr Creep - live"}, names(`tag = "expr grunge"`))
+	assert.Equal(t, []string{"Expr Hysteria", "Expr Hysteria (Live)", "Expr Pure Morning"},
+		names(`group ~ expr AND tag != "Expr Grunge"`))
+	assert.Equal(t, []string{"Expr Creep", "Expr Creep - live"},
+		names(`group CONTAINS radio AND text ~ "100%"`))
+	assert.Empty(t, names(`group ~ expr AND song ~ "_"`))
+
+	for _, tc := range []struct {
+		filter string
+		pos    int
+	}{
+		{`group = muse AND`, 16},
+		{`(group = muse`, 0},
+		{`colour = red`, 0},
+		{`id ~ 5`, 3},
+		{`id > five`, 5},
+		{`release_date < yesterday`, 15},
+		{`group IN (a, b`, 14},
+		{`song = "unterminated`, 7},
+		{`group = muse muse`, 13},
+	} {
+		req, _ := http.NewRequest("GET", "/songs?filter="+url.QueryEscape(tc.filter), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code, tc.filter)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.EqualValues(t, tc.pos, response["position"], tc.filter)
+	}
+}
+
+func TestUpdateSongHandlerRequiresAllFields(t *testing.T) {
+	db := setupTestDB(t)
+
+	song := models.Song{
+		GroupName:   "Patch Test Group",
+		SongName:    "Whole",
+		ReleaseDate: time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC),
+		Text:        "Lyrics",
+		Link:        "https://link",
+	}
+	require.NoError(t, models.CreateSong(db, &song))
+
+	router := gin.Default()
+	router.PUT("/songs/:id", UpdateSongHandler(db))
+
+	body, _ := json.Marshal(map[string]string{"link": "https://new"})
+	req, _ := http.NewRequest("PUT", "/songs/"+strconv.Itoa(int(song.ID)), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	stored, err := models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Lyrics", stored.Text)
+
+	// Empty lyrics and link are fine, as long as they are there.
+	body, _ = json.Marshal(map[string]string{"group_name": "Patch Test Group", "song_name": "Whole",
+		"release_date": "2008-01-01", "text": "", "link": ""})
+	req, _ = http.NewRequest("PUT", "/songs/"+strconv.Itoa(int(song.ID)), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	stored, err = models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Text)
+	assert.Empty(t, stored.Link)
+}
+
+func TestPatchSongHandler(t *testing.T) {
+	db := setupTestDB(t)
+
+	song := models.Song{
+		GroupName:   "Patch Test Group",
+		SongName:    "Partial",
+		ReleaseDate: time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC),
+		Text:        "Lyrics",
+		Link:        "https://link",
+	}
+	require.NoError(t, models.CreateSong(db, &song))
+	path := "/songs/" + strconv.Itoa(int(song.ID))
+
+	router := gin.Default()
+	router.PATCH("/songs/:id", PatchSongHandler(db))
+
+	patchSong := func(contentType, body string) (*httptest.ResponseRecorder, models.Song) {
+		req, _ := http.NewRequest("PATCH", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result models.Song
+		_ = json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+
+	w, result := patchSong("application/merge-patch+json", `{"link": "https://new"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://new", result.Link)
+	assert.Equal(t, "Lyrics", result.Text)
+	assert.Equal(t, "Partial", result.SongName)
+	assert.Equal(t, "2008-01-01", result.ReleaseDate.Format("2006-01-02"))
+
+	w, result = patchSong("application/json-patch+json", `[
+		{"op": "test", "path": "/song_name", "value": "Partial"},
+		{"op": "replace", "path": "/release_date", "value": "2010-02-03"},
+		{"op": "copy", "from": "/song_name", "path": "/text"}
+	]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2010-02-03", result.ReleaseDate.Format("2006-01-02"))
+	assert.Equal(t, "Partial", result.Text)
+	assert.Equal(t, "https://new", result.Link)
+
+	w, _ = patchSong("application/merge-patch+json", `{"song_name": null, "release_date": "soon", "text": 5, "rating": 10}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var response struct {
+		Fields map[string]string `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]string{
+		"song_name":    "is required",
+		"release_date": "unsupported date format: soon",
+		"text":         "must be a string",
+		"rating":       "unknown field",
+	}, response.Fields)
+
+	w, _ = patchSong("application/json-patch+json", `[{"op": "test", "path": "/song_name", "value": "Other"}]`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w, _ = patchSong("application/json-patch+json", `[{"op": "remove", "path": "/nothing"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = patchSong("text/plain", `link=x`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	stored, err := models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Partial", stored.SongName)
+	assert.Equal(t, "https://new", stored.Link)
+}
+
+func TestSongETags(t *testing.T) {
+	db := setupTestDB(t)
+
+	song := models.Song{
+		GroupName:   "ETag Test Group",
+		SongName:    "Versioned",
+		ReleaseDate: time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC),
+		Text:        "Lyrics",
+		Link:        "https://link",
+	}
+	require.NoError(t, models.CreateSong(db, &song))
+	path := "/songs/" + strconv.Itoa(int(song.ID))
+
+	router := gin.Default()
+	router.GET("/songs/:id", GetSongHandler(db))
+	router.PUT("/songs/:id", UpdateSongHandler(db))
+	router.PATCH("/songs/:id", PatchSongHandler(db))
+	router.DELETE("/songs/:id", DeleteSongHandler(db))
+
+	serve := func(method string, headers map[string]string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", nil, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	w = serve("GET", map[string]string{"If-None-Match": etag}, "")
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusNotModified, serve("GET", map[string]string{"If-None-Match": "W/" + etag}, "").Code)
+
+	merge := map[string]string{"Content-Type": "application/merge-patch+json"}
+	assert.Equal(t, http.StatusPreconditionRequired, serve("PATCH", merge, `{"link": "https://a"}`).Code)
+
+	// The first editor wins; the second still holds the old ETag.
+	merge["If-Match"] = etag
+	w = serve("PATCH", merge, `{"link": "https://a"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	newETag := w.Header().Get("ETag")
+	assert.Equal(t, `"2"`, newETag)
+
+	w = serve("PATCH", merge, `{"link": "https://b"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, newETag, w.Header().Get("ETag"))
+
+	put := `{"group_name": "ETag Test Group", "song_name": "Versioned", "release_date": "2008-01-01", "text": "Lyrics", "link": "https://b"}`
+	assert.Equal(t, http.StatusPreconditionFailed,
+		serve("PUT", map[string]string{"Content-Type": "application/json", "If-Match": etag}, put).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, serve("DELETE", map[string]string{"If-Match": etag}, "").Code)
+	assert.Equal(t, http.StatusPreconditionFailed, serve("DELETE", map[string]string{"If-Match": "W/" + newETag}, "").Code)
+
+	assert.Equal(t, http.StatusOK, serve("GET", map[string]string{"If-None-Match": etag}, "").Code)
+
+	stored, err := models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://a", stored.Link)
+
+	// Tags and the group name are part of the song too.
+	_, err = models.AttachTags(db, song.ID, []string{"ETag Tag"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, serve("GET", map[string]string{"If-None-Match": newETag}, "").Code)
+	w = serve("GET", nil, "")
+	_, err = models.AttachTags(db, song.ID, []string{"etag tag"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, serve("GET", map[string]string{"If-None-Match": w.Header().Get("ETag")}, "").Code)
+	require.NoError(t, models.DetachTag(db, song.ID, "ETag Tag"))
+	assert.Equal(t, http.StatusOK, serve("GET", map[string]string{"If-None-Match": w.Header().Get("ETag")}, "").Code)
+	w = serve("GET", nil, "")
+	_, err = models.UpdateGroup(db, stored.GroupID, "ETag Renamed Group")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, serve("GET", map[string]string{"If-None-Match": w.Header().Get("ETag")}, "").Code)
+	newETag = serve("GET", nil, "").Header().Get("ETag")
+
+	assert.Equal(t, http.StatusOK, serve("DELETE", map[string]string{"If-Match": `"0", ` + newETag}, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", nil, "").Code)
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetTagsHandler godoc
+// @Summary      Get tags
+// @Description  Get all tags, optionally only those of one kind
+// @Tags         tags
+// @Param        kind  query     string  false  "Tag kind"  Enums(genre, mood, custom)
+// @Success      200   {array}   models.Tag
+// @Failure      500   {object}  map[string]interface{}
+// @Router       /tags [get]
+func GetTagsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /tags request")
+
+		tags, err := models.GetTags(db, c.Query("kind"))
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch tags from database")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, tags)
+	}
+}
+
+// AttachSongTagsHandler godoc
+// @Summary      Tag song
+// @Description  Attach tags to a song. Names are normalized ("Hip Hop" and "hip-hop" are one tag) and missing tags are created with the given kind.
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int               true  "Song ID"
+// @Param        tags  body      models.TagsInput  true  "Tag names and kind"
+// @Success      200   {array}   models.Tag
+// @Failure      400   {object}  map[string]interface{}
+// @Failure      404   {object}  map[string]interface{}
+// @Failure      500   {object}  map[string]interface{}
+// @Router       /songs/{id}/tags [post]
+func AttachSongTagsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /songs/:id/tags request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var input models.TagsInput
+		if err = c.ShouldBindJSON(&input); err != nil {
+			logger.Log.WithError(err).Debug("Invalid JSON input")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tags, err := models.AttachTags(db, uint(id), input.Tags, input.Kind)
+		if err != nil {
+			writeTagError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, tags)
+	}
+}
+
+// DetachSongTagHandler godoc
+// @Summary      Untag song
+// @Description  Detach a tag from a song; any spelling that normalizes to the tag works
+// @Tags         tags
+// @Produce      json
+// @Param        id   path      int     true  "Song ID"
+// @Param        tag  path      string  true  "Tag name or slug"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Router       /songs/{id}/tags/{tag} [delete]
+func DetachSongTagHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling DELETE /songs/:id/tags/:tag request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		if err = models.DetachTag(db, uint(id), c.Param("tag")); err != nil {
+			writeTagError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tag detached"})
+	}
+}
+
+func writeTagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidTagName), errors.Is(err, models.ErrInvalidTagKind):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Song or tag not found"})
+	default:
+		logger.Log.WithError(err).Error("Tag operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
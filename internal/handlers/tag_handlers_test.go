@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTagName(t *testing.T) {
+	assert.Equal(t, "hip-hop", models.NormalizeTagName("Hip Hop"))
+	assert.Equal(t, "hip-hop", models.NormalizeTagName(" HIP_HOP "))
+	assert.Equal(t, "hip-hop", models.NormalizeTagName("hip--hop!"))
+	assert.Equal(t, "пост-панк", models.NormalizeTagName("Пост Панк"))
+	assert.Equal(t, "", models.NormalizeTagName(" - "))
+}
+
+func TestTagFilters(t *testing.T) {
+	db := setupTestDB(t)
+
+	calm := models.Song{GroupName: "Tag Band", SongName: "Calm", ReleaseDate: time.Now(), Text: "t", Link: "l"}
+	loud := models.Song{GroupName: "Tag Band", SongName: "Loud", ReleaseDate: time.Now(), Text: "t", Link: "l"}
+	require.NoError(t, models.CreateSong(db, &calm))
+	require.NoError(t, models.CreateSong(db, &loud))
+
+	router := gin.Default()
+	router.POST("/songs/:id/tags", AttachSongTagsHandler(db))
+
+	attach := func(song models.Song, body string) []models.Tag {
+		req, _ := http.NewRequest("POST", "/songs/"+strconv.Itoa(int(song.ID))+"/tags", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var tags []models.Tag
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tags))
+		return tags
+	}
+
+	tags := attach(calm, `{"tags": ["Trip Hop", "chill"], "kind": "genre"}`)
+	assert.Len(t, tags, 2)
+
+	tags = attach(loud, `{"tags": ["trip-hop", "Angry"], "kind": "mood"}`)
+	assert.Len(t, tags, 2)
+
+	var count int64
+	db.Model(&models.Tag{}).Where("slug = ?", "trip-hop").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	songs, err := models.GetSongs(db, models.SongFilter{Tag: "TRIP HOP"})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	songs, err = models.GetSongs(db, models.SongFilter{TagsAny: []string{"chill", "angry"}})
+	require.NoError(t, err)
+	assert.Len(t, songs, 2)
+
+	songs, err = models.GetSongs(db, models.SongFilter{TagsAll: []string{"trip hop", "angry"}})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+	assert.Equal(t, "Loud", songs[0].SongName)
+	assert.Len(t, songs[0].Tags, 2)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+
+	"SongLibrary/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrashHandler godoc
+// @Summary      List trash
+// @Description  Get deleted songs that can still be restored, most recently deleted first
+// @Tags         songs
+// @Param        page   query     int  false  "Page number"
+// @Param        limit  query     int  false  "Items per page"
+// @Success      200    {object}  models.SongList
+// @Header       200    {string}  Link  "Links to the first, prev, next and last pages"
+// @Failure      400    {object}  map[string]interface{}
+// @Failure      500    {object}  map[string]interface{}
+// @Router       /songs/trash [get]
+func GetTrashHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling GET /songs/trash request")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid page parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		list, err := models.ListTrash(db, page, limit)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch trash")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		setLinkHeader(c, list.PageInfo)
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// RestoreSongHandler godoc
+// @Summary      Restore song
+// @Description  Take a song out of the trash with its tags and album tracks. Playlist entries removed on delete are not restored. A song whose group has got another song of the same name meanwhile cannot be restored (409).
+// @Tags         songs
+// @Produce      json
+// @Param        id       path      int     true   "Song ID"
+// @Param        X-Actor  header    string  false  "Who makes the change"
+// @Success      200      {object}  models.Song
+// @Failure      400      {object}  map[string]interface{}
+// @Failure      404      {object}  map[string]interface{}
+// @Failure      409      {object}  map[string]interface{}
+// @Failure      500      {object}  map[string]interface{}
+// @Router       /songs/{id}/restore [post]
+func RestoreSongHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /songs/:id/restore request")
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			logger.Log.WithError(err).Debug("Invalid ID parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		song, err := models.RestoreSong(withActor(c, db), uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Song not found in trash"})
+			return
+		}
+		if errors.Is(err, models.ErrSongExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to restore song ID %d", id)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("ETag", songETag(song))
+		c.JSON(http.StatusOK, song)
+	}
+}
+
+// PurgeTrashHandler godoc
+// @Summary      Purge trash
+// @Description  Permanently delete songs that have been in the trash longer than olderThan (default: the configured retention period)
+// @Tags         admin
+// @Produce      json
+// @Param        olderThan  query     string  false  "Minimum time in the trash, as a Go duration such as 720h; 0 empties the trash"
+// @Success      200        {object}  map[string]interface{}
+// @Failure      400        {object}  map[string]interface{}
+// @Failure      500        {object}  map[string]interface{}
+// @Router       /admin/trash/purge [post]
+func PurgeTrashHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger.Log.Debug("Handling POST /admin/trash/purge request")
+
+		olderThan := models.TrashRetention
+		if param := c.Query("olderThan"); param != "" {
+			d, err := time.ParseDuration(param)
+			if err != nil || d < 0 {
+				logger.Log.WithError(err).Debug("Invalid olderThan parameter")
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid olderThan"})
+				return
+			}
+			olderThan = d
+		}
+
+		purged, err := models.PurgeTrash(db, time.Now().Add(-olderThan))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"purged": purged})
+	}
+}
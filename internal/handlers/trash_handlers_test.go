@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"SongLibrary/internal/models"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTrashHandlers(t *testing.T) {
+	db := setupTestDB(t)
+
+	song := models.Song{
+		GroupName:   "Trash Test Group",
+		SongName:    "Binned",
+		ReleaseDate: time.Now(),
+		Text:        "Line1\n\nLine2",
+		Link:        "https://link",
+	}
+	require.NoError(t, models.CreateSong(db, &song))
+	_, err := models.AttachTags(db, song.ID, []string{"Trash Test Tag"}, "")
+	require.NoError(t, err)
+	id := strconv.Itoa(int(song.ID))
+
+	router := gin.Default()
+	router.GET("/songs", GetSongsHandler(db))
+	router.GET("/songs/trash", GetTrashHandler(db))
+	router.GET("/songs/:id/verses", GetSongVersesHandler(db))
+	router.DELETE("/songs/:id", DeleteSongHandler(db))
+	router.POST("/songs/:id/restore", RestoreSongHandler(db))
+	router.POST("/admin/trash/purge", PurgeTrashHandler(db))
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	listed := func(url string) []uint {
+		w := serve("GET", url)
+		require.Equal(t, http.StatusOK, w.Code)
+		var list models.SongList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		var ids []uint
+		for _, s := range list.Items {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, http.StatusOK, serve("DELETE", "/songs/"+id).Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/songs/"+id).Code)
+
+	assert.NotContains(t, listed("/songs?group=Trash+Test+Group"), song.ID)
+	assert.Contains(t, listed("/songs?group=Trash+Test+Group&includeDeleted=true"), song.ID)
+	assert.Contains(t, listed("/songs/trash?limit=100"), song.ID)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/songs/"+id+"/verses").Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/songs/"+id+"/verses?includeDeleted=true").Code)
+
+	w := serve("POST", "/songs/"+id+"/restore")
+	require.Equal(t, http.StatusOK, w.Code)
+	var restored models.Song
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	require.Len(t, restored.Tags, 1)
+	assert.Equal(t, "Trash Test Tag", restored.Tags[0].Name)
+	assert.Contains(t, listed("/songs?group=Trash+Test+Group"), song.ID)
+	assert.NotContains(t, listed("/songs/trash?limit=100"), song.ID)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/songs/"+id+"/restore").Code)
+
+	assert.Equal(t, http.StatusOK, serve("DELETE", "/songs/"+id).Code)
+
+	// A trashed song does not block adding it again, but then cannot be
+	// restored next to the new one.
+	again := models.Song{GroupName: song.GroupName, SongName: song.SongName, ReleaseDate: time.Now()}
+	require.NoError(t, models.CreateSong(db, &again))
+	assert.Equal(t, http.StatusConflict, serve("POST", "/songs/"+id+"/restore").Code)
+	assert.Contains(t, listed("/songs/trash?limit=100"), song.ID)
+	_, err = models.EnqueueEnrichment(db, song.ID)
+	require.NoError(t, err)
+
+	// Trashed just now, so the default retention keeps it.
+	assert.Equal(t, http.StatusOK, serve("POST", "/admin/trash/purge").Code)
+	assert.Contains(t, listed("/songs/trash?limit=100"), song.ID)
+
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/admin/trash/purge?olderThan=soon").Code)
+
+	w = serve("POST", "/admin/trash/purge?olderThan=0s")
+	require.Equal(t, http.StatusOK, w.Code)
+	var purge map[string]int64
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &purge))
+	assert.GreaterOrEqual(t, purge["purged"], int64(1))
+	assert.NotContains(t, listed("/songs/trash?limit=100"), song.ID)
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/songs/"+id+"/restore").Code)
+
+	var links, jobs int64
+	db.Table("song_tags").Where("song_id = ?", song.ID).Count(&links)
+	assert.Zero(t, links)
+	db.Model(&models.EnrichmentJob{}).Where("song_id = ?", song.ID).Count(&jobs)
+	assert.Zero(t, jobs)
+}
@@ -0,0 +1,391 @@
+package importer
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"SongLibrary/pkg/logger"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of an import file.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl" // one JSON object per line
+	FormatJSON  = "json"  // a JSON array of objects
+)
+
+// What to do with a row naming a song that already exists, by group and
+// song name.
+const (
+	OnDuplicateSkip      = "skip"      // keep the stored song
+	OnDuplicateOverwrite = "overwrite" // replace every field with the row
+	OnDuplicateMerge     = "merge"     // take the fields the row has, keep the others
+)
+
+// Song fields a column can be mapped to.
+const (
+	FieldGroupName   = "group_name"
+	FieldSongName    = "song_name"
+	FieldReleaseDate = "release_date"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+// maxLineSize bounds a JSON Lines record, lyrics included.
+const maxLineSize = 4 << 20
+
+// columnFields maps the column names recognized without a mapping, in lower
+// case, to fields. The short names are the ones POST /songs takes.
+var columnFields = map[string]string{
+	FieldGroupName:   FieldGroupName,
+	FieldSongName:    FieldSongName,
+	FieldReleaseDate: FieldReleaseDate,
+	FieldText:        FieldText,
+	FieldLink:        FieldLink,
+	"group":          FieldGroupName,
+	"song":           FieldSongName,
+}
+
+var ErrUnknownFormat = errors.New("unknown format, expected csv, jsonl or json")
+
+type Options struct {
+	Format string
+	// Columns maps input columns (CSV headers or JSON keys) to song fields.
+	// Columns named like a field, or "group" and "song", need no mapping;
+	// other columns are ignored.
+	Columns     map[string]string
+	OnDuplicate string
+	// Enricher, if set, fills in the fields a row lacks.
+	Enricher enrich.Enricher
+}
+
+// RowError is why a row was not imported. Row counts records from 1, not
+// counting the CSV header.
+type RowError struct {
+	Row   int    `json:"row" example:"3"`
+	Field string `json:"field,omitempty" example:"release_date"`
+	Error string `json:"error" example:"unsupported date format: soon"`
+}
+
+type Report struct {
+	Rows    int        `json:"rows"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"`
+	Failed  int        `json:"failed"`
+	Errors  []RowError `json:"errors"`
+}
+
+// DetectFormat guesses the format of a file from its extension, returning ""
+// when it cannot tell.
+func DetectFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".json":
+		return FormatJSON
+	}
+	return ""
+}
+
+// ParseColumns reads a column mapping written as "column=field,...".
+func ParseColumns(spec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		column, field, ok := strings.Cut(pair, "=")
+		column, field = strings.TrimSpace(column), strings.ToLower(strings.TrimSpace(field))
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected column=field", pair)
+		}
+		if _, known := columnFields[field]; !known || field == "group" || field == "song" {
+			return nil, fmt.Errorf("unknown song field %q", field)
+		}
+		columns[column] = field
+	}
+	return columns, nil
+}
+
+// Import reads songs from r and writes them one by one. Rows that fail are
+// reported and skipped; the returned error is for input that cannot be read
+// any further, with the rows before it already imported.
+func Import(db *gorm.DB, r io.Reader, opts Options) (Report, error) {
+	report := Report{Errors: []RowError{}}
+
+	switch opts.OnDuplicate {
+	case "":
+		opts.OnDuplicate = OnDuplicateSkip
+	case OnDuplicateSkip, OnDuplicateOverwrite, OnDuplicateMerge:
+	default:
+		return report, fmt.Errorf("unknown duplicate handling %q, expected skip, overwrite or merge", opts.OnDuplicate)
+	}
+
+	next, err := newReader(r, opts.Format)
+	if err != nil {
+		return report, err
+	}
+
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		var unreadable *unreadableError
+		if errors.As(err, &unreadable) {
+			return report, unreadable.err
+		}
+		report.Rows++
+		if err == nil {
+			err = importRow(db, mapColumns(record, opts.Columns), opts, &report)
+		}
+		if err != nil {
+			rowErr := RowError{Row: report.Rows, Error: err.Error()}
+			var invalid *fieldError
+			if errors.As(err, &invalid) {
+				rowErr.Field, rowErr.Error = invalid.field, invalid.message
+			}
+			report.Failed++
+			report.Errors = append(report.Errors, rowErr)
+		}
+	}
+
+	logger.Log.Infof("Import done: %d rows, %d created, %d updated, %d skipped, %d failed",
+		report.Rows, report.Created, report.Updated, report.Skipped, report.Failed)
+	return report, nil
+}
+
+// fieldError is an invalid field of a row.
+type fieldError struct {
+	field, message string
+}
+
+func (e *fieldError) Error() string {
+	return e.field + ": " + e.message
+}
+
+// unreadableError stops an import: the input cannot be read past it.
+type unreadableError struct {
+	err error
+}
+
+func (e *unreadableError) Error() string {
+	return e.err.Error()
+}
+
+// newReader returns a function reading the records of r one at a time,
+// io.EOF after the last.
+func newReader(r io.Reader, format string) (func() (map[string]interface{}, error), error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	case FormatJSON:
+		return newJSONReader(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func newCSVReader(r io.Reader) (func() (map[string]interface{}, error), error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return func() (map[string]interface{}, error) { return nil, io.EOF }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	reader.FieldsPerRecord = len(header)
+
+	return func() (map[string]interface{}, error) {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return nil, &unreadableError{err}
+		}
+		if err != nil {
+			return nil, parseErr.Err
+		}
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			record[column] = fields[i]
+		}
+		return record, nil
+	}, nil
+}
+
+func newJSONLReader(r io.Reader) func() (map[string]interface{}, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	return func() (map[string]interface{}, error) {
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			return decodeRecord(line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, &unreadableError{err}
+		}
+		return nil, io.EOF
+	}
+}
+
+func newJSONReader(r io.Reader) (func() (map[string]interface{}, error), error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("invalid JSON: expected an array of songs")
+	}
+	return func() (map[string]interface{}, error) {
+		if !decoder.More() {
+			return nil, io.EOF
+		}
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, &unreadableError{fmt.Errorf("invalid JSON: %w", err)}
+		}
+		return decodeRecord(raw)
+	}, nil
+}
+
+func decodeRecord(data []byte) (map[string]interface{}, error) {
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil || record == nil {
+		return nil, errors.New("record is not a JSON object")
+	}
+	return record, nil
+}
+
+// mapColumns keys the values of a record by song field. Explicit mappings
+// win over columns that are named like a field.
+func mapColumns(record map[string]interface{}, columns map[string]string) map[string]interface{} {
+	values := make(map[string]interface{}, len(columnFields))
+	for column, value := range record {
+		if _, mapped := columns[column]; mapped {
+			continue
+		}
+		if field, ok := columnFields[strings.ToLower(strings.TrimSpace(column))]; ok {
+			values[field] = value
+		}
+	}
+	for column, value := range record {
+		if field, ok := columns[column]; ok {
+			values[field] = value
+		}
+	}
+	return values
+}
+
+func importRow(db *gorm.DB, values map[string]interface{}, opts Options, report *Report) error {
+	row := make(map[string]string, len(values))
+	for field, value := range values {
+		switch value := value.(type) {
+		case nil:
+		case string:
+			if field != FieldText {
+				value = strings.TrimSpace(value)
+			}
+			row[field] = value
+		default:
+			return &fieldError{field, "must be a string"}
+		}
+	}
+
+	song := models.Song{
+		GroupName: row[FieldGroupName],
+		SongName:  row[FieldSongName],
+		Text:      row[FieldText],
+		Link:      row[FieldLink],
+	}
+	if song.GroupName == "" {
+		return &fieldError{FieldGroupName, "is required"}
+	}
+	if song.SongName == "" {
+		return &fieldError{FieldSongName, "is required"}
+	}
+	if date := row[FieldReleaseDate]; date != "" {
+		releaseDate, err := models.ParseDate(date)
+		if err != nil {
+			return &fieldError{FieldReleaseDate, err.Error()}
+		}
+		song.ReleaseDate = releaseDate
+	}
+
+	existing, err := models.FindSongByName(db, song.GroupName, song.SongName)
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if found {
+		switch opts.OnDuplicate {
+		case OnDuplicateSkip:
+			report.Skipped++
+			return nil
+		case OnDuplicateMerge:
+			song = mergeSong(existing, song)
+		}
+		song.ID, song.Version = existing.ID, existing.Version
+	}
+
+	if opts.Enricher != nil && (song.ReleaseDate.IsZero() || song.Text == "" || song.Link == "") {
+		if err = enrich.FillSong(db.Statement.Context, opts.Enricher, &song); err != nil {
+			return err
+		}
+	}
+	if song.ReleaseDate.IsZero() {
+		return &fieldError{FieldReleaseDate, "is required"}
+	}
+
+	if found {
+		if err = models.UpdateSong(db, &song); err != nil {
+			return err
+		}
+		report.Updated++
+		return nil
+	}
+	if err = models.CreateSong(db, &song); err != nil {
+		return err
+	}
+	report.Created++
+	return nil
+}
+
+// mergeSong is the stored song with the fields a row has set. The names
+// identify the song and stay as stored.
+func mergeSong(existing, row models.Song) models.Song {
+	merged := models.Song{
+		GroupName:   existing.GroupName,
+		SongName:    existing.SongName,
+		ReleaseDate: existing.ReleaseDate,
+		Text:        existing.Text,
+		Link:        existing.Link,
+	}
+	if !row.ReleaseDate.IsZero() {
+		merged.ReleaseDate = row.ReleaseDate
+	}
+	if row.Text != "" {
+		merged.Text = row.Text
+	}
+	if row.Link != "" {
+		merged.Link = row.Link
+	}
+	return merged
+}
@@ -0,0 +1,70 @@
+package importer
+
+import (
+	"SongLibrary/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, models.Migrate(db))
+	require.NoError(t, models.SetupSearch(db))
+	return db
+}
+
+func TestImportFormats(t *testing.T) {
+	db := setupTestDB(t)
+
+	jsonl := `{"group": "Importer Group", "song": "Lines One", "release_date": "2001-02-03", "text": "la", "link": "l"}
+
+{"group": "Importer Group", "song": "Lines Two", "release_date": 2001}
+["not", "an", "object"]
+{"group": "Importer Group", "title": "Lines Three", "release_date": "2001.02.03"}
+`
+	report, err := Import(db, strings.NewReader(jsonl), Options{
+		Format:  FormatJSONL,
+		Columns: map[string]string{"title": FieldSongName},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, []RowError{
+		{Row: 2, Field: FieldReleaseDate, Error: "must be a string"},
+		{Row: 3, Error: "record is not a JSON object"},
+	}, report.Errors)
+
+	array := `[
+		{"group_name": "importer group", "song_name": "LINES ONE", "release_date": "2001-02-03"},
+		{"group_name": "Importer Group", "song_name": "Array One", "release_date": "2002-01-01"},
+		{"group_name": "Importer Group"`
+	report, err = Import(db, strings.NewReader(array), Options{Format: FormatJSON})
+	assert.Error(t, err)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Created)
+
+	_, err = Import(db, strings.NewReader(`{}`), Options{Format: FormatJSON})
+	assert.Error(t, err)
+	_, err = Import(db, strings.NewReader(``), Options{Format: "xml"})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("Artist = group_name, Title=SONG_NAME,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Artist": FieldGroupName, "Title": FieldSongName}, columns)
+
+	for _, bad := range []string{"artist", "=group_name", "artist=group", "artist=album"} {
+		_, err = ParseColumns(bad)
+		assert.Error(t, err, bad)
+	}
+	assert.Equal(t, FormatJSONL, DetectFormat("songs.NDJSON"))
+	assert.Empty(t, DetectFormat("songs.txt"))
+}
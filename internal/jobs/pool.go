@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"SongLibrary/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+// Defaults of NewPool.
+const (
+	defaultWorkers      = 4
+	defaultPollInterval = time.Second
+	defaultLease        = 2 * time.Minute
+	defaultBackoff      = 30 * time.Second
+	maxBackoff          = time.Hour
+)
+
+var (
+	errNoReleaseDate = errors.New("external API has no release date for the song")
+	errGaveUp        = errors.New("worker stopped during the last attempt")
+)
+
+// Pool runs enrichment jobs with Workers workers, each asking the queue for
+// a due job every PollInterval while it is idle. A job that fails is retried
+// after Backoff, doubling with every attempt up to an hour, until it has had
+// its attempts; songs the providers do not know fail right away.
+type Pool struct {
+	DB           *gorm.DB
+	Enricher     enrich.Enricher
+	Workers      int
+	PollInterval time.Duration
+	// Lease is how long a worker holds a job before others may take it
+	// over. It should be well above the time an enrichment can take.
+	Lease   time.Duration
+	Backoff time.Duration
+}
+
+func NewPool(db *gorm.DB, enricher enrich.Enricher) *Pool {
+	return &Pool{
+		DB:           db,
+		Enricher:     enricher,
+		Workers:      defaultWorkers,
+		PollInterval: defaultPollInterval,
+		Lease:        defaultLease,
+		Backoff:      defaultBackoff,
+	}
+}
+
+// Run works off the queue until ctx is done, then waits for the jobs in
+// progress.
+func (p *Pool) Run(ctx context.Context) {
+	logger.Log.Infof("Starting %d enrichment worker(s)", p.Workers)
+	var wg sync.WaitGroup
+	for i := 0; i < p.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+	logger.Log.Info("Enrichment workers stopped")
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := p.RunOne(ctx)
+		if err != nil {
+			logger.Log.WithError(err).Error("Enrichment worker failed")
+		}
+		if ran {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(p.PollInterval):
+		}
+	}
+}
+
+// RunOne runs the job that is due longest, if any, reporting whether there
+// was one.
+func (p *Pool) RunOne(ctx context.Context) (bool, error) {
+	job, err := models.ClaimEnrichmentJob(p.DB, p.Lease)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, p.run(ctx, &job)
+}
+
+func (p *Pool) run(ctx context.Context, job *models.EnrichmentJob) error {
+	if job.Attempts > job.MaxAttempts {
+		return models.BuryEnrichmentJob(p.DB, job, errGaveUp)
+	}
+
+	song, err := models.GetSong(p.DB.Unscoped(), job.SongID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.BuryEnrichmentJob(p.DB, job, errors.New("song no longer exists"))
+	}
+	if err != nil {
+		return p.retry(job, err)
+	}
+
+	err = enrich.FillSong(ctx, p.Enricher, &song)
+	switch {
+	case errors.Is(err, enrich.ErrNotFound):
+		return models.BuryEnrichmentJob(p.DB, job, err)
+	case err != nil && ctx.Err() != nil:
+		// Stopping is not the job's fault; it runs again next time.
+		return models.ReleaseEnrichmentJob(p.DB, job, err)
+	case err != nil:
+		return p.retry(job, err)
+	case song.ReleaseDate.IsZero():
+		return models.BuryEnrichmentJob(p.DB, job, errNoReleaseDate)
+	}
+
+	err = models.CompleteEnrichmentJob(p.DB, job, &song)
+	if errors.Is(err, models.ErrVersionMismatch) {
+		// The song was written meanwhile; the next attempt starts from
+		// what is stored now.
+		return models.ReleaseEnrichmentJob(p.DB, job, err)
+	}
+	if err != nil && !errors.Is(err, models.ErrJobLost) {
+		return p.retry(job, err)
+	}
+	return err
+}
+
+// retry puts a failed job back in the queue, or buries it when it has had
+// its attempts.
+func (p *Pool) retry(job *models.EnrichmentJob, cause error) error {
+	if job.Attempts >= job.MaxAttempts {
+		return models.BuryEnrichmentJob(p.DB, job, fmt.Errorf("gave up after %d attempts: %w", job.Attempts, cause))
+	}
+	return models.RetryEnrichmentJob(p.DB, job, cause, time.Now().Add(p.backoff(job.Attempts)))
+}
+
+func (p *Pool) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, models.Migrate(db))
+	require.NoError(t, models.SetupSearch(db))
+	return db
+}
+
+func TestPool(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	var calls int
+	var conflict uint // a song to write while it is being enriched, once
+	enricher := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		calls++
+		if conflict != 0 {
+			require.NoError(t, db.Model(&models.Song{}).Where("id = ?", conflict).
+				Update("version", gorm.Expr("version + 1")).Error)
+			conflict = 0
+		}
+		switch song {
+		case "Pool Unknown":
+			return enrich.Details{}, enrich.ErrNotFound
+		case "Pool Flaky", "Pool Flaky Again":
+			if calls < 3 {
+				return enrich.Details{}, enrich.ErrUnavailable
+			}
+		case "Pool Down", "Pool Down By Hand":
+			return enrich.Details{}, enrich.ErrUnavailable
+		}
+		return enrich.Details{
+			ReleaseDate: time.Date(1999, 9, 9, 0, 0, 0, 0, time.UTC),
+			Text:        "Pool lyrics",
+			Link:        "https://example.com/pool",
+		}, nil
+	})
+	pool := NewPool(db, enricher)
+	pool.Backoff = 0
+
+	enqueue := func(name string) (models.Song, models.EnrichmentJob) {
+		song := models.Song{GroupName: "Pool Test Group", SongName: name, Status: models.SongPending}
+		require.NoError(t, models.CreateSong(db, &song))
+		job, err := models.EnqueueEnrichment(db, song.ID)
+		require.NoError(t, err)
+		return song, job
+	}
+	drain := func() int {
+		n := 0
+		for {
+			ran, err := pool.RunOne(ctx)
+			require.NoError(t, err)
+			if !ran {
+				return n
+			}
+			n++
+		}
+	}
+
+	// A failing job is retried until it succeeds.
+	calls = 0
+	song, job := enqueue("Pool Flaky")
+	assert.Equal(t, 3, drain())
+	job, err := models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDone, job.State)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Empty(t, job.LastError)
+	assert.NotNil(t, job.FinishedAt)
+	song, err = models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SongReady, song.Status)
+	assert.Equal(t, "Pool lyrics", song.Text)
+	assert.Equal(t, uint(2), song.Version)
+	revisions, err := models.ListRevisions(db, song.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.RevisionEnrich, revisions.Items[0].Action)
+
+	// Until it has had its attempts.
+	song, job = enqueue("Pool Down")
+	assert.Equal(t, models.EnrichmentJobAttempts, drain())
+	job, err = models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDead, job.State)
+	assert.Contains(t, job.LastError, "gave up after 5 attempts")
+	song, err = models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SongFailed, song.Status)
+	assert.Equal(t, job.LastError, song.EnrichmentError)
+
+	// Writing the song in full makes it ready.
+	song.ReleaseDate, song.Text, song.Link = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), "By hand", "https://hand"
+	require.NoError(t, models.UpdateSong(db, &song))
+	assert.Equal(t, models.SongReady, song.Status)
+	assert.Empty(t, song.EnrichmentError)
+
+	// A pending song written in full is ready, and stays so when its job
+	// gives up afterwards.
+	song, job = enqueue("Pool Down By Hand")
+	song.ReleaseDate, song.Text, song.Link = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), "By hand", "https://hand"
+	require.NoError(t, models.UpdateSong(db, &song))
+	assert.Equal(t, models.SongReady, song.Status)
+	assert.Equal(t, models.EnrichmentJobAttempts, drain())
+	job, err = models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDead, job.State)
+	stored, err := models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SongReady, stored.Status)
+	assert.Empty(t, stored.EnrichmentError)
+	assert.Equal(t, song.Version, stored.Version)
+
+	// A song written during the last attempt does not use it up.
+	song, job = enqueue("Pool Conflict")
+	require.NoError(t, db.Model(&job).Update("attempts", job.MaxAttempts-1).Error)
+	conflict = song.ID
+	assert.Equal(t, 2, drain())
+	job, err = models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDone, job.State)
+	assert.Equal(t, job.MaxAttempts, job.Attempts)
+	song, err = models.GetSong(db, song.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SongReady, song.Status)
+
+	// Unknown songs fail right away.
+	song, job = enqueue("Pool Unknown")
+	assert.Equal(t, 1, drain())
+	job, err = models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDead, job.State)
+	assert.Equal(t, 1, job.Attempts)
+
+	// Retries wait for the backoff.
+	pool.Backoff = time.Minute
+	calls = 0
+	_, job = enqueue("Pool Flaky Again")
+	assert.Equal(t, 1, drain())
+	job, err = models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobQueued, job.State)
+	assert.Equal(t, enrich.ErrUnavailable.Error(), job.LastError)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), job.RunAt, 5*time.Second)
+	assert.Equal(t, 2*time.Minute, pool.backoff(2))
+	assert.Equal(t, time.Hour, pool.backoff(20))
+	require.NoError(t, db.Delete(&job).Error)
+
+	// A job whose worker went away is taken over once its lease is up, and
+	// the first worker cannot finish it any more.
+	_, job = enqueue("Pool Lost")
+	lost, err := models.ClaimEnrichmentJob(db, -time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, lost.ID)
+	assert.Equal(t, 1, drain())
+	assert.ErrorIs(t, models.RetryEnrichmentJob(db, &lost, enrich.ErrUnavailable, time.Now()), models.ErrJobLost)
+	job, err = models.GetEnrichmentJob(db, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDone, job.State)
+	assert.Equal(t, 2, job.Attempts)
+}
+
+func TestScheduler(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	enricher := enrich.Func(func(ctx context.Context, group, song string) (enrich.Details, error) {
+		return enrich.Details{Text: "Fresh lyrics"}, nil
+	})
+	scheduler := NewScheduler(db, enricher, 24*time.Hour)
+
+	var stale, fresh models.Song
+	for name, song := range map[string]*models.Song{"Scheduler Stale": &stale, "Scheduler Fresh": &fresh} {
+		*song = models.Song{
+			GroupName:   "Scheduler Test Group",
+			SongName:    name,
+			ReleaseDate: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			Text:        "Old lyrics",
+		}
+		require.NoError(t, models.CreateSong(db, song))
+	}
+	require.NoError(t, db.Model(&stale).Update("created_at", time.Now().Add(-48*time.Hour)).Error)
+
+	report, err := scheduler.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Matched)
+	assert.Equal(t, 1, report.Changed)
+
+	stale, err = models.GetSong(db, stale.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Fresh lyrics", stale.Text)
+	require.NotNil(t, stale.EnrichedAt)
+	fresh, err = models.GetSong(db, fresh.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Old lyrics", fresh.Text)
+
+	report, err = scheduler.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.Matched)
+}
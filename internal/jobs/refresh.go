@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"SongLibrary/internal/enrich"
+	"SongLibrary/internal/models"
+	"SongLibrary/pkg/logger"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+// Defaults of NewScheduler.
+const (
+	defaultRefreshInterval = time.Hour
+	defaultRefreshBatch    = 100
+)
+
+// RefreshReport sums up a refresh of several songs.
+type RefreshReport struct {
+	Matched   int `json:"matched" example:"3"`
+	Refreshed int `json:"refreshed" example:"2"`
+	// Changed counts the refreshed songs whose fields changed.
+	Changed int            `json:"changed" example:"1"`
+	Failed  int            `json:"failed" example:"1"`
+	Errors  []RefreshError `json:"errors"`
+}
+
+type RefreshError struct {
+	ID    uint   `json:"id" example:"7"`
+	Error string `json:"error" example:"song is unknown to the enrichment provider"`
+}
+
+// RefreshSong fetches a song from the providers again, past the enrichment
+// cache, and writes the answer over the fields that are not protected. A
+// song the providers no longer know keeps its fields but counts as
+// enriched, so that the scheduler moves on to other songs; the error is
+// still returned.
+func RefreshSong(ctx context.Context, db *gorm.DB, enricher enrich.Enricher, id uint) (models.Song, bool, error) {
+	song, err := models.GetSong(db, id)
+	if err != nil {
+		return models.Song{}, false, err
+	}
+
+	if cache := enrich.CacheOf(enricher); cache != nil {
+		if _, err = cache.Invalidate(ctx, song.GroupName, song.SongName); err != nil {
+			logger.Log.WithError(err).Warnf("Failed to invalidate cached enrichment of song ID=%d", id)
+		}
+	}
+	details, enrichErr := enricher.Enrich(ctx, song.GroupName, song.SongName)
+	if enrichErr != nil && !errors.Is(enrichErr, enrich.ErrNotFound) {
+		return song, false, enrichErr
+	}
+
+	song, changed, err := models.RefreshSong(db, id, models.Song{
+		ReleaseDate: details.ReleaseDate,
+		Text:        details.Text,
+		Link:        details.Link,
+	})
+	if err != nil {
+		return models.Song{}, false, err
+	}
+	return song, changed, enrichErr
+}
+
+// RefreshSongs refreshes the songs with workers requests at a time.
+func RefreshSongs(ctx context.Context, db *gorm.DB, enricher enrich.Enricher, ids []uint, workers int) RefreshReport {
+	report := RefreshReport{Matched: len(ids), Errors: []RefreshError{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	next := make(chan uint)
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range next {
+				_, changed, err := RefreshSong(ctx, db, enricher, id)
+				mu.Lock()
+				switch {
+				case err != nil:
+					report.Failed++
+					report.Errors = append(report.Errors, RefreshError{ID: id, Error: err.Error()})
+				case changed:
+					report.Changed++
+					fallthrough
+				default:
+					report.Refreshed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		next <- id
+	}
+	close(next)
+	wg.Wait()
+
+	logger.Log.Infof("Refreshed %d of %d song(s), %d changed, %d failed",
+		report.Refreshed, report.Matched, report.Changed, report.Failed)
+	return report
+}
+
+// Scheduler refreshes songs enriched more than MaxAge ago, every Interval at
+// most Batch of them, oldest first.
+type Scheduler struct {
+	DB       *gorm.DB
+	Enricher enrich.Enricher
+	MaxAge   time.Duration
+	Interval time.Duration
+	Batch    int
+	Workers  int
+}
+
+func NewScheduler(db *gorm.DB, enricher enrich.Enricher, maxAge time.Duration) *Scheduler {
+	return &Scheduler{
+		DB:       db,
+		Enricher: enricher,
+		MaxAge:   maxAge,
+		Interval: defaultRefreshInterval,
+		Batch:    defaultRefreshBatch,
+		Workers:  defaultWorkers,
+	}
+}
+
+// Run refreshes stale songs right away and then every Interval until ctx is
+// done.
+func (s *Scheduler) Run(ctx context.Context) {
+	logger.Log.Infof("Refreshing songs older than %s every %s", s.MaxAge, s.Interval)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(ctx); err != nil {
+			logger.Log.WithError(err).Error("Failed to refresh stale songs")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce refreshes one batch of stale songs.
+func (s *Scheduler) RunOnce(ctx context.Context) (RefreshReport, error) {
+	ids, err := models.StaleSongIDs(s.DB, time.Now().Add(-s.MaxAge), s.Batch)
+	if err != nil {
+		return RefreshReport{}, err
+	}
+	if len(ids) == 0 {
+		logger.Log.Debug("No stale songs to refresh")
+		return RefreshReport{Errors: []RefreshError{}}, nil
+	}
+	return RefreshSongs(ctx, s.DB, s.Enricher, ids, s.Workers), nil
+}
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidAlbumTitle  = errors.New("album title is required")
+	ErrInvalidTrackNumber = errors.New("track and disc numbers must be positive")
+	ErrTrackPositionTaken = errors.New("track position is already taken on this album")
+)
+
+type Album struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	GroupID     uint         `gorm:"not null" json:"group_id"`
+	Group       *Group       `json:"-"`
+	GroupName   string       `gorm:"-" json:"group_name"`
+	Title       string       `gorm:"not null" json:"title"`
+	ReleaseDate *time.Time   `json:"release_date,omitempty"`
+	Tracks      []AlbumTrack `json:"tracks,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// AlbumTrack places a song on an album. A song may appear on several albums
+// (original record, compilations, live releases) with a different position on each.
+type AlbumTrack struct {
+	AlbumID     uint  `gorm:"primaryKey" json:"album_id"`
+	SongID      uint  `gorm:"primaryKey" json:"song_id"`
+	Song        *Song `json:"song,omitempty"`
+	DiscNumber  int   `gorm:"not null;default:1" json:"disc_number"`
+	TrackNumber int   `gorm:"not null" json:"track_number"`
+}
+
+// AfterFind exposes the name of the preloaded group as GroupName.
+func (a *Album) AfterFind(tx *gorm.DB) error {
+	if a.Group != nil {
+		a.GroupName = a.Group.Name
+	}
+	return nil
+}
+
+type AlbumFilter struct {
+	GroupName string
+	Title     string
+	Page      int
+	Limit     int
+}
+
+type AlbumInput struct {
+	Group       string `json:"group" binding:"required" example:"Muse"`
+	Title       string `json:"title" binding:"required" example:"Black Holes and Revelations"`
+	ReleaseDate string `json:"release_date" example:"2006-07-03"`
+}
+
+type AlbumTrackInput struct {
+	SongID      uint `json:"song_id" binding:"required" example:"1"`
+	DiscNumber  int  `json:"disc_number" example:"1"`
+	TrackNumber int  `json:"track_number" example:"3"`
+}
+
+func GetAlbums(db *gorm.DB, filter AlbumFilter) ([]Album, error) {
+	var albums []Album
+	query := db.Model(&Album{}).Preload("Group")
+
+	logger.Log.Debug("Building query for GetAlbums")
+
+	if filter.GroupName != "" {
+		groupIDs := db.Model(&Group{}).Select("id").
+			Where("normalized_name LIKE ?", "%"+NormalizeGroupName(filter.GroupName)+"%")
+		query = query.Where("group_id IN (?)", groupIDs)
+		logger.Log.Debugf("Filter: Group name LIKE '%%%s%%'", NormalizeGroupName(filter.GroupName))
+	}
+	if filter.Title != "" {
+		query = query.Where("LOWER(title) LIKE ?", "%"+strings.ToLower(filter.Title)+"%")
+		logger.Log.Debugf("Filter: Title LIKE '%%%s%%'", filter.Title)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	err := query.Order("id").Limit(filter.Limit).Offset(offset).Find(&albums).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch albums from database")
+	} else {
+		logger.Log.Infof("Fetched %d album(s) from database", len(albums))
+	}
+
+	return albums, err
+}
+
+// GetAlbum loads an album together with its track list in disc/track order.
+func GetAlbum(db *gorm.DB, id uint) (Album, error) {
+	var album Album
+	err := db.Preload("Group").
+		Preload("Tracks", func(tx *gorm.DB) *gorm.DB {
+			// Tracks of trashed songs keep their place for a restore but are
+			// not listed.
+			return tx.Where("song_id IN (?)", db.Model(&Song{}).Select("id")).
+				Order("disc_number, track_number")
+		}).
+		Preload("Tracks.Song.Group").
+		First(&album, id).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to fetch album with ID %d", id)
+	}
+	return album, err
+}
+
+func CreateAlbum(db *gorm.DB, album *Album) error {
+	album.Title = strings.TrimSpace(album.Title)
+	if album.Title == "" {
+		return ErrInvalidAlbumTitle
+	}
+
+	logger.Log.Infof("Creating album: Group=%s, Title=%s", album.GroupName, album.Title)
+
+	group, err := FindOrCreateGroup(db, album.GroupName)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to resolve group for album")
+		return err
+	}
+	album.GroupID = group.ID
+	album.Group = &group
+	album.GroupName = group.Name
+
+	err = db.Omit("Group", "Tracks").Create(album).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to create album in database")
+	} else {
+		logger.Log.Infof("Album created successfully: ID=%d", album.ID)
+	}
+
+	return err
+}
+
+func UpdateAlbum(db *gorm.DB, updatedAlbum *Album) error {
+	logger.Log.Debugf("Attempting to update album with ID=%d", updatedAlbum.ID)
+
+	title := strings.TrimSpace(updatedAlbum.Title)
+	if title == "" {
+		return ErrInvalidAlbumTitle
+	}
+
+	var existing Album
+	err := db.First(&existing, updatedAlbum.ID).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Album with ID=%d not found for update", updatedAlbum.ID)
+		return err
+	}
+
+	group, err := FindOrCreateGroup(db, updatedAlbum.GroupName)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to resolve group for album ID=%d", updatedAlbum.ID)
+		return err
+	}
+
+	existing.GroupID = group.ID
+	existing.GroupName = group.Name
+	existing.Title = title
+	existing.ReleaseDate = updatedAlbum.ReleaseDate
+
+	err = db.Omit("Group", "Tracks").Save(&existing).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to update album ID=%d", updatedAlbum.ID)
+	} else {
+		logger.Log.Infof("Album updated successfully: ID=%d", updatedAlbum.ID)
+		*updatedAlbum = existing
+	}
+
+	return err
+}
+
+// DeleteAlbum removes the album and its track list; the songs themselves stay.
+func DeleteAlbum(db *gorm.DB, id uint) error {
+	logger.Log.Debugf("Attempting to delete album with ID=%d", id)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", id).Delete(&AlbumTrack{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Album{}, id).Error
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to delete album ID=%d", id)
+	} else {
+		logger.Log.Infof("Album deleted successfully: ID=%d", id)
+	}
+
+	return err
+}
+
+// AddAlbumTrack puts a song on an album, or moves it if it is already there.
+// A zero disc number means disc 1 and a zero track number appends the song to
+// the end of that disc. A song without a release date inherits the album's.
+func AddAlbumTrack(db *gorm.DB, track *AlbumTrack) error {
+	if track.DiscNumber == 0 {
+		track.DiscNumber = 1
+	}
+	if track.DiscNumber < 0 || track.TrackNumber < 0 {
+		return ErrInvalidTrackNumber
+	}
+
+	logger.Log.Debugf("Adding song ID=%d to album ID=%d", track.SongID, track.AlbumID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var album Album
+		if err := tx.First(&album, track.AlbumID).Error; err != nil {
+			return err
+		}
+		var song Song
+		if err := tx.First(&song, track.SongID).Error; err != nil {
+			return err
+		}
+
+		if track.TrackNumber == 0 {
+			var last int
+			err := tx.Model(&AlbumTrack{}).
+				Where("album_id = ? AND disc_number = ? AND song_id <> ?", track.AlbumID, track.DiscNumber, track.SongID).
+				Select("COALESCE(MAX(track_number), 0)").Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			track.TrackNumber = last + 1
+		}
+
+		var taken int64
+		err := tx.Model(&AlbumTrack{}).
+			Where("album_id = ? AND disc_number = ? AND track_number = ? AND song_id <> ?",
+				track.AlbumID, track.DiscNumber, track.TrackNumber, track.SongID).
+			Count(&taken).Error
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrTrackPositionTaken
+		}
+
+		if err = tx.Omit("Song").Save(track).Error; err != nil {
+			return err
+		}
+
+		if song.ReleaseDate.IsZero() && album.ReleaseDate != nil {
+			logger.Log.Debugf("Song ID=%d has no release date, using album date", song.ID)
+			return tx.Model(&song).Update("release_date", *album.ReleaseDate).Error
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to add song ID=%d to album ID=%d", track.SongID, track.AlbumID)
+	} else {
+		logger.Log.Infof("Song ID=%d placed on album ID=%d as disc %d track %d",
+			track.SongID, track.AlbumID, track.DiscNumber, track.TrackNumber)
+	}
+
+	return err
+}
+
+func RemoveAlbumTrack(db *gorm.DB, albumID, songID uint) error {
+	logger.Log.Debugf("Removing song ID=%d from album ID=%d", songID, albumID)
+
+	result := db.Where("album_id = ? AND song_id = ?", albumID, songID).Delete(&AlbumTrack{})
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Errorf("Failed to remove song ID=%d from album ID=%d", songID, albumID)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	logger.Log.Infof("Song ID=%d removed from album ID=%d", songID, albumID)
+	return nil
+}
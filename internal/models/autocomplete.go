@@ -0,0 +1,247 @@
+package models
+
+import (
+	"SongLibrary/internal/search"
+	"SongLibrary/pkg/logger"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields autocompletion works on.
+const (
+	AutocompleteGroup = "group"
+	AutocompleteSong  = "song"
+)
+
+// Bounds on autocompletion requests and the cache answering them.
+const (
+	AutocompleteMaxLimit     = 25
+	AutocompleteMaxPrefix    = 100
+	autocompleteCacheTTL     = 2 * time.Second
+	autocompleteCacheEntries = 1000
+)
+
+var (
+	ErrInvalidAutocompleteField  = errors.New("field must be group or song")
+	ErrInvalidAutocompletePrefix = errors.New("prefix must be between 1 and 100 characters")
+)
+
+// autocompleter keeps a trie of group names and one of song names, each
+// weighted by number of songs, and caches recent answers for a moment so a
+// burst of keystrokes does not walk the trie every time. Song and group
+// writes move it to the next generation; the next request rebuilds the tries
+// from the database, without holding mu, and swaps them in.
+type autocompleter struct {
+	mu         sync.Mutex
+	generation uint64
+	tries      map[string]*search.Trie
+	built      uint64 // the generation tries were built for
+	answers    map[string]autocompleteAnswer
+	// building lets one request at a time rebuild the tries.
+	building sync.Mutex
+}
+
+type autocompleteAnswer struct {
+	completions []search.Completion
+	expires     time.Time
+}
+
+var completions = &autocompleter{}
+
+// prefixSearch is set once the prefix indexes are in place; completions
+// then come from the database, which every instance of the service shares,
+// instead of the tries.
+var prefixSearch bool
+
+// normalizedSongNameSQL normalizes a song name like NormalizeGroupName.
+const normalizedSongNameSQL = `lower(regexp_replace(btrim(song_name), '\s+', ' ', 'g'))`
+
+func setupPostgresAutocomplete(db *gorm.DB) {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_groups_normalized_name_prefix ON groups (normalized_name text_pattern_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_songs_song_name_prefix ON songs ((` + normalizedSongNameSQL + `) text_pattern_ops)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			logger.Log.WithError(err).Warn("Failed to create prefix indexes, autocompletion falls back to in-memory tries")
+			return
+		}
+	}
+	prefixSearch = true
+	logger.Log.Info("PostgreSQL prefix indexes ready")
+}
+
+// InvalidateAutocomplete marks the completions stale. Song and group writes
+// call it once they have committed (see Transaction), so a rebuild cannot
+// miss them.
+func InvalidateAutocomplete() {
+	completions.mu.Lock()
+	defer completions.mu.Unlock()
+	completions.generation++
+	completions.answers = nil
+}
+
+// Autocomplete returns up to limit distinct group or song names starting with
+// prefix (ignoring case and extra spaces), the names used by most songs
+// first.
+func Autocomplete(db *gorm.DB, field, prefix string, limit int) ([]search.Completion, error) {
+	if field != AutocompleteGroup && field != AutocompleteSong {
+		return nil, ErrInvalidAutocompleteField
+	}
+	key := NormalizeGroupName(prefix)
+	if key == "" || len([]rune(prefix)) > AutocompleteMaxPrefix {
+		return nil, ErrInvalidAutocompletePrefix
+	}
+	if limit <= 0 || limit > AutocompleteMaxLimit {
+		limit = AutocompleteMaxLimit
+	}
+
+	a := completions
+	cacheKey := fmt.Sprintf("%s|%d|%s", field, limit, key)
+	result, generation, ok := a.cached(cacheKey)
+	if ok {
+		return result, nil
+	}
+
+	if db.Dialector.Name() == "postgres" && prefixSearch {
+		result, err := completeByPrefix(db, field, key, limit)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to look up completions")
+			return nil, err
+		}
+		result = a.remember(cacheKey, generation, result)
+		logger.Log.Debugf("Autocomplete %s '%s': %d completion(s)", field, prefix, len(result))
+		return result, nil
+	}
+
+	tries, err := a.triesFor(db, generation)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to rebuild autocompletion")
+		return nil, err
+	}
+	result = a.remember(cacheKey, generation, tries[field].Complete(key, limit))
+
+	logger.Log.Debugf("Autocomplete %s '%s': %d completion(s)", field, prefix, len(result))
+	return result, nil
+}
+
+// cached returns a recent answer for key, if any, and the current
+// generation.
+func (a *autocompleter) cached(key string) ([]search.Completion, uint64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if answer, ok := a.answers[key]; ok && time.Now().Before(answer.expires) {
+		return answer.completions, a.generation, true
+	}
+	return nil, a.generation, false
+}
+
+// remember caches an answer worked out for generation, unless a write has
+// made it stale meanwhile, and returns it.
+func (a *autocompleter) remember(key string, generation uint64, result []search.Completion) []search.Completion {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if generation != a.generation {
+		return result
+	}
+	if a.answers == nil || len(a.answers) >= autocompleteCacheEntries {
+		a.answers = make(map[string]autocompleteAnswer)
+	}
+	a.answers[key] = autocompleteAnswer{completions: result, expires: time.Now().Add(autocompleteCacheTTL)}
+	return result
+}
+
+// triesFor returns tries at least as recent as generation, rebuilding them
+// if they are older. Writes are not held up by a rebuild: they only take mu,
+// which is not held while the database is read.
+func (a *autocompleter) triesFor(db *gorm.DB, generation uint64) (map[string]*search.Trie, error) {
+	a.building.Lock()
+	defer a.building.Unlock()
+
+	a.mu.Lock()
+	tries, built := a.tries, a.built
+	a.mu.Unlock()
+	if tries != nil && built >= generation {
+		return tries, nil
+	}
+
+	tries, err := buildAutocompleteTries(db)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	// A write during the build moved to a later generation, whose first
+	// request builds again.
+	a.tries, a.built = tries, generation
+	a.mu.Unlock()
+	return tries, nil
+}
+
+// completeByPrefix looks the completions up with the prefix indexes.
+func completeByPrefix(db *gorm.DB, field, key string, limit int) ([]search.Completion, error) {
+	pattern := escapeLike(key) + "%"
+	query := db.Model(&Song{})
+	if field == AutocompleteGroup {
+		query = query.Select("groups.name AS value, COUNT(songs.id) AS count").
+			Joins("JOIN groups ON groups.id = songs.group_id").
+			Where(`groups.normalized_name LIKE ? ESCAPE '\'`, pattern).
+			Group("groups.id, groups.name").
+			Order("count DESC").Order("lower(groups.name)")
+	} else {
+		query = query.Select("MIN(btrim(song_name)) AS value, COUNT(*) AS count").
+			Where(normalizedSongNameSQL+` LIKE ? ESCAPE '\'`, pattern).
+			Group(normalizedSongNameSQL).
+			Order("count DESC").Order("lower(MIN(btrim(song_name)))")
+	}
+
+	result := []search.Completion{}
+	err := query.Limit(limit).Scan(&result).Error
+	return result, err
+}
+
+func buildAutocompleteTries(db *gorm.DB) (map[string]*search.Trie, error) {
+	type nameCount struct {
+		Name  string
+		Count int
+	}
+
+	var groups []nameCount
+	err := db.Model(&Song{}).
+		Select("groups.name AS name, COUNT(songs.id) AS count").
+		Joins("JOIN groups ON groups.id = songs.group_id").
+		Group("groups.id, groups.name").
+		Scan(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []nameCount
+	err = db.Model(&Song{}).
+		Select("song_name AS name, COUNT(*) AS count").
+		Group("song_name").
+		Scan(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tries := map[string]*search.Trie{
+		AutocompleteGroup: search.NewTrie(),
+		AutocompleteSong:  search.NewTrie(),
+	}
+	for _, group := range groups {
+		tries[AutocompleteGroup].Add(NormalizeGroupName(group.Name), group.Name, group.Count)
+	}
+	for _, song := range songs {
+		// Song names are matched the way group names are: case and runs of
+		// spaces do not matter.
+		tries[AutocompleteSong].Add(NormalizeGroupName(song.Name), strings.TrimSpace(song.Name), song.Count)
+	}
+
+	logger.Log.Infof("Autocompletion rebuilt: %d group name(s), %d song name(s)",
+		tries[AutocompleteGroup].Len(), tries[AutocompleteSong].Len())
+	return tries, nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import "encoding/json"
+
+// Operations a bulk request can hold.
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// How a bulk request runs: all operations in one transaction that rolls back
+// on the first failure, or each on its own.
+const (
+	BulkAtomic     = "atomic"
+	BulkBestEffort = "best_effort"
+)
+
+// MaxBulkOperations bounds the number of operations in one bulk request.
+const MaxBulkOperations = 1000
+
+type BulkRequest struct {
+	Mode       string          `json:"mode" binding:"required,oneof=atomic best_effort" enums:"atomic,best_effort"`
+	Operations []BulkOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+// BulkOperation is one write of a bulk request. Song is a CreateSongInput
+// for create and an UpdateSongInput for update; update and delete name the
+// song by ID and need the ETag of the version they change, as If-Match
+// would carry it.
+type BulkOperation struct {
+	Op      string          `json:"op" enums:"create,update,delete" example:"create"`
+	ID      uint            `json:"id,omitempty" example:"1"`
+	IfMatch string          `json:"if_match,omitempty" example:"\"1\""`
+	Song    json.RawMessage `json:"song,omitempty" swaggertype:"object"`
+}
+
+// BulkResult is the outcome of one operation, at the same index as in the
+// request. Status is the HTTP status the operation would have had on its
+// own; in atomic mode operations rolled back because of another one report
+// 424 Failed Dependency.
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status int    `json:"status"`
+	ID     uint   `json:"id,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Song   *Song  `json:"song,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Mode      string       `json:"mode"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// EnrichmentCacheEntry is a stored answer of the enrichment providers for a
+// song, keyed on its normalized group and song name. NotFound entries
+// remember that no provider knew the song.
+type EnrichmentCacheEntry struct {
+	CacheKey    string `gorm:"primaryKey"`
+	ReleaseDate *time.Time
+	Text        string    `gorm:"not null"`
+	Link        string    `gorm:"not null"`
+	NotFound    bool      `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time
+}
+
+func (EnrichmentCacheEntry) TableName() string {
+	return "enrichment_cache"
+}
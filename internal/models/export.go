@@ -0,0 +1,51 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// ExportedSong is a song as it is exported: its own fields and the group
+// name, without tags.
+type ExportedSong struct {
+	ID          uint      `json:"id"`
+	GroupName   string    `json:"group_name"`
+	SongName    string    `json:"song_name"`
+	ReleaseDate time.Time `json:"release_date"`
+	Text        string    `json:"text"`
+	Link        string    `json:"link"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportSongs calls fn with every song matching the filter, in the filter's
+// order (by ID without one), reading them from a database cursor rather than
+// loading them all. Paging fields of the filter are ignored. It stops at the
+// first error fn returns.
+func ExportSongs(db *gorm.DB, filter SongFilter, fn func(song ExportedSong) error) error {
+	query := filterSongs(db, filter, false).
+		Select("songs.id, (SELECT name FROM groups WHERE groups.id = songs.group_id) AS group_name, " +
+			"song_name, release_date, text, link, songs.created_at, songs.updated_at")
+	if len(filter.Sort) > 0 {
+		query = filter.Sort.apply(query)
+	} else {
+		query = query.Order("id")
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var song ExportedSong
+		if err = db.ScanRows(rows, &song); err != nil {
+			return err
+		}
+		if err = fn(song); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,456 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FilterQueryError reports a malformed filter expression and where it went
+// wrong.
+type FilterQueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterQueryError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+// Kinds of song fields a filter expression can test.
+const (
+	filterNumber = "number"
+	filterDate   = "date"
+	filterString = "string"
+	filterGroup  = "group"
+	filterAlbum  = "album"
+	filterTag    = "tag"
+)
+
+type filterField struct {
+	kind   string
+	column string
+}
+
+var filterFields = map[string]filterField{
+	"id":           {filterNumber, "id"},
+	"group":        {filterGroup, ""},
+	"group_name":   {filterGroup, ""},
+	"song":         {filterString, "song_name"},
+	"song_name":    {filterString, "song_name"},
+	"text":         {filterString, "text"},
+	"link":         {filterString, "link"},
+	"status":       {filterString, "status"},
+	"release_date": {filterDate, "release_date"},
+	"created_at":   {filterDate, "created_at"},
+	"updated_at":   {filterDate, "updated_at"},
+	"album":        {filterAlbum, ""},
+	"tag":          {filterTag, ""},
+}
+
+// filterOperators lists the comparisons each kind of field supports.
+var filterOperators = map[string][]string{
+	filterNumber: {"=", "!=", "<", "<=", ">", ">=", "IN", "BETWEEN"},
+	filterDate:   {"=", "!=", "<", "<=", ">", ">=", "IN", "BETWEEN"},
+	filterString: {"=", "!=", "~", "IN"},
+	filterGroup:  {"=", "!=", "~", "IN"},
+	filterAlbum:  {"=", "!=", "~", "IN"},
+	filterTag:    {"=", "!=", "IN"},
+}
+
+// filterNode is a parsed filter expression. Op is "and", "or", "not" or
+// "cmp"; a comparison tests Field with Cmp against Values, which are already
+// converted to the field's type.
+type filterNode struct {
+	Op       string
+	Children []*filterNode
+	Child    *filterNode
+	Field    string
+	Cmp      string
+	Values   []interface{}
+}
+
+// SongQuery is a parsed filter expression over song fields.
+type SongQuery struct {
+	root *filterNode
+}
+
+type filterToken struct {
+	kind string // "word", "string", "op", "(", ")", ",", "AND", "OR", "NOT", "IN", "BETWEEN"
+	text string
+	pos  int
+}
+
+// ParseSongQuery parses a filter expression such as
+//
+//	(group = Muse OR group = Radiohead) AND NOT song ~ live
+//	release_date BETWEEN 1990-01-01 AND 1999-12-31
+//	tag IN (rock, "trip hop") AND id > 100
+//
+// Comparisons are =, !=, <, <=, >, >=, ~ (or CONTAINS), IN (...) and
+// BETWEEN ... AND ...; they combine with AND, OR, NOT and parentheses, NOT
+// binding tightest and OR loosest. Keywords are case-insensitive and values
+// with spaces or punctuation are quoted with " or '. String comparisons
+// ignore case.
+func ParseSongQuery(q string) (*SongQuery, error) {
+	tokens, err := tokenizeFilterQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, &FilterQueryError{Pos: 0, Msg: "filter is empty"}
+	}
+
+	p := &filterParser{tokens: tokens, end: len([]rune(q))}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return &SongQuery{root: root}, nil
+}
+
+func tokenizeFilterQuery(q string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, filterToken{kind: string(r), text: string(r), pos: i})
+			i++
+		case r == '=' || r == '~':
+			tokens = append(tokens, filterToken{kind: "op", text: string(r), pos: i})
+			i++
+		case r == '!' || r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			} else if r == '!' {
+				return nil, &FilterQueryError{Pos: i, Msg: `expected "!="`}
+			}
+			tokens = append(tokens, filterToken{kind: "op", text: op, pos: i})
+			i += len(op)
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				i++
+			}
+			if i == len(runes) {
+				return nil, &FilterQueryError{Pos: start, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, filterToken{kind: "string", text: string(runes[start+1 : i]), pos: start})
+			i++
+		case isFilterWordRune(r):
+			start := i
+			for i < len(runes) && isFilterWordRune(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			token := filterToken{kind: "word", text: word, pos: start}
+			switch upper := strings.ToUpper(word); upper {
+			case "AND", "OR", "NOT", "IN", "BETWEEN":
+				token.kind = upper
+			case "CONTAINS":
+				token.kind, token.text = "op", "~"
+			}
+			tokens = append(tokens, token)
+		default:
+			return nil, &FilterQueryError{Pos: i, Msg: fmt.Sprintf("unexpected %q", string(r))}
+		}
+	}
+	return tokens, nil
+}
+
+// isFilterWordRune covers field names and unquoted values, dates and times
+// included.
+func isFilterWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:+", r)
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	end    int
+}
+
+func (p *filterParser) peek() *filterToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// expect consumes the next token if it has the given kind.
+func (p *filterParser) expect(kind, what string) (*filterToken, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, &FilterQueryError{Pos: p.end, Msg: "expected " + what}
+	}
+	if t.kind != kind {
+		return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("expected %s, got %q", what, t.text)}
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) parseOr() (*filterNode, error) {
+	return p.parseList("or", "OR", p.parseAnd)
+}
+
+func (p *filterParser) parseAnd() (*filterNode, error) {
+	return p.parseList("and", "AND", p.parseNot)
+}
+
+func (p *filterParser) parseList(op, keyword string, next func() (*filterNode, error)) (*filterNode, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	children := []*filterNode{first}
+	for t := p.peek(); t != nil && t.kind == keyword; t = p.peek() {
+		p.pos++
+		child, err := next()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &filterNode{Op: op, Children: children}, nil
+}
+
+func (p *filterParser) parseNot() (*filterNode, error) {
+	if t := p.peek(); t != nil && t.kind == "NOT" {
+		p.pos++
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNode{Op: "not", Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (*filterNode, error) {
+	t := p.peek()
+	if t != nil && t.kind == "(" {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil || closing.kind != ")" {
+			return nil, &FilterQueryError{Pos: t.pos, Msg: "unclosed parenthesis"}
+		}
+		p.pos++
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (*filterNode, error) {
+	name, err := p.expect("word", "a field name")
+	if err != nil {
+		return nil, err
+	}
+	field, ok := filterFields[strings.ToLower(name.text)]
+	if !ok {
+		return nil, &FilterQueryError{Pos: name.pos, Msg: fmt.Sprintf("unknown field %q", name.text)}
+	}
+	node := &filterNode{Op: "cmp", Field: strings.ToLower(name.text)}
+
+	t := p.peek()
+	if t == nil {
+		return nil, &FilterQueryError{Pos: p.end, Msg: "expected a comparison after " + name.text}
+	}
+	switch t.kind {
+	case "op", "IN", "BETWEEN":
+		node.Cmp = t.text
+		if t.kind != "op" {
+			node.Cmp = t.kind
+		}
+	default:
+		return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("expected a comparison, got %q", t.text)}
+	}
+	if !containsString(filterOperators[field.kind], node.Cmp) {
+		return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("%s cannot be compared with %s", name.text, t.text)}
+	}
+	p.pos++
+
+	switch node.Cmp {
+	case "IN":
+		if _, err := p.expect("(", `"(" after IN`); err != nil {
+			return nil, err
+		}
+		for {
+			value, err := p.parseValue(field)
+			if err != nil {
+				return nil, err
+			}
+			node.Values = append(node.Values, value)
+			if next := p.peek(); next != nil && next.kind == "," {
+				p.pos++
+				continue
+			}
+			break
+		}
+		if _, err := p.expect(")", `")" after the IN list`); err != nil {
+			return nil, err
+		}
+	case "BETWEEN":
+		low, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("AND", "AND between the bounds"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		node.Values = []interface{}{low, high}
+	default:
+		value, err := p.parseValue(field)
+		if err != nil {
+			return nil, err
+		}
+		node.Values = []interface{}{value}
+	}
+	return node, nil
+}
+
+// parseValue reads a value and converts it to the field's type.
+func (p *filterParser) parseValue(field filterField) (interface{}, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, &FilterQueryError{Pos: p.end, Msg: "expected a value"}
+	}
+	if t.kind != "word" && t.kind != "string" {
+		return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("expected a value, got %q", t.text)}
+	}
+	p.pos++
+
+	switch field.kind {
+	case filterNumber:
+		n, err := strconv.ParseUint(t.text, 10, 64)
+		if err != nil {
+			return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("%q is not a number", t.text)}
+		}
+		return uint(n), nil
+	case filterDate:
+		date, err := ParseDate(t.text)
+		if err != nil {
+			return nil, &FilterQueryError{Pos: t.pos, Msg: fmt.Sprintf("%q is not a date", t.text)}
+		}
+		return date, nil
+	}
+	return t.text, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// apply adds the expression to the query as a single parameterized WHERE
+// condition.
+func (q *SongQuery) apply(db, query *gorm.DB) *gorm.DB {
+	sql, args := q.root.compile(db)
+	return query.Where(sql, args...)
+}
+
+func (n *filterNode) compile(db *gorm.DB) (string, []interface{}) {
+	switch n.Op {
+	case "and", "or":
+		parts := make([]string, len(n.Children))
+		var args []interface{}
+		for i, child := range n.Children {
+			sql, childArgs := child.compile(db)
+			parts[i] = sql
+			args = append(args, childArgs...)
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(n.Op)+" ") + ")", args
+	case "not":
+		sql, args := n.Child.compile(db)
+		return "NOT " + sql, args
+	}
+
+	field := filterFields[n.Field]
+	// Related fields are matched in a subquery, and != turns into NOT IN.
+	related := n.Cmp
+	if related == "!=" {
+		related = "="
+	}
+	switch field.kind {
+	case filterNumber, filterDate:
+		return compileComparison(field.column, n.Cmp, n.Values)
+	case filterString:
+		return compileComparison("LOWER("+field.column+")", n.Cmp, lowerValues(n.Values, strings.ToLower))
+	case filterGroup:
+		sql, args := compileComparison("normalized_name", related, lowerValues(n.Values, NormalizeGroupName))
+		return inSubquery("group_id", n.Cmp, db.Model(&Group{}).Select("id").Where(sql, args...))
+	case filterAlbum:
+		sql, args := compileComparison("LOWER(title)", related, lowerValues(n.Values, strings.ToLower))
+		albumIDs := db.Model(&Album{}).Select("id").Where(sql, args...)
+		return inSubquery("id", n.Cmp, db.Model(&AlbumTrack{}).Select("song_id").Where("album_id IN (?)", albumIDs))
+	default: // filterTag
+		slugs := make([]string, len(n.Values))
+		for i, value := range n.Values {
+			slugs[i] = NormalizeTagName(value.(string))
+		}
+		return inSubquery("id", n.Cmp, songIDsWithTags(db, slugs, false))
+	}
+}
+
+// compileComparison renders one comparison on a column or expression.
+func compileComparison(column, cmp string, values []interface{}) (string, []interface{}) {
+	switch cmp {
+	case "IN":
+		return column + " IN ?", []interface{}{values}
+	case "BETWEEN":
+		return column + " BETWEEN ? AND ?", values
+	case "~":
+		return column + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(values[0].(string)) + "%"}
+	case "!=":
+		return column + " <> ?", values
+	}
+	return column + " " + cmp + " ?", values
+}
+
+// inSubquery matches songs whose column is (or, for !=, is not) among the
+// subquery's rows. The subquery always looks for a match, so that "tag != x"
+// also excludes songs that have x among other tags.
+func inSubquery(column, cmp string, subquery *gorm.DB) (string, []interface{}) {
+	if cmp == "!=" {
+		return column + " NOT IN (?)", []interface{}{subquery}
+	}
+	return column + " IN (?)", []interface{}{subquery}
+}
+
+func lowerValues(values []interface{}, normalize func(string) string) []interface{} {
+	lowered := make([]interface{}, len(values))
+	for i, value := range values {
+		lowered[i] = normalize(value.(string))
+	}
+	return lowered
+}
+
+// escapeLike escapes the LIKE wildcards in s, using \ as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
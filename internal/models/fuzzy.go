@@ -0,0 +1,258 @@
+package models
+
+import (
+	"SongLibrary/internal/search"
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FuzzyThreshold is the lowest similarity, from 0 to 1, a name needs to be
+// suggested when the request does not set its own.
+var FuzzyThreshold = 0.3
+
+// Names a suggestion can match on.
+const (
+	SuggestFieldGroup = "group"
+	SuggestFieldSong  = "song"
+	SuggestFieldAny   = "any"
+)
+
+var (
+	ErrInvalidSuggestField = errors.New("field must be group, song or any")
+	ErrInvalidThreshold    = errors.New("threshold must be between 0 and 1")
+)
+
+// SongSuggestion is a song whose group or song name resembles the query.
+type SongSuggestion struct {
+	Song  Song    `json:"song"`
+	Field string  `json:"field"`
+	Score float64 `json:"score"`
+}
+
+type fuzzyHit struct {
+	ID    uint
+	Field string
+	Score float64
+}
+
+// trigramSearch is set once pg_trgm is available; without it suggestions are
+// scored in Go.
+var trigramSearch bool
+
+func setupPostgresFuzzy(db *gorm.DB) {
+	// suggestTrigram also matches the transliterated names, which need
+	// indexes of their own on exactly the expression it uses.
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_songs_song_name_trgm ON songs USING GIN (song_name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_groups_name_trgm ON groups USING GIN (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_songs_song_name_translit_trgm ON songs USING GIN ((` + transliterateSQL("song_name") + `) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_groups_name_translit_trgm ON groups USING GIN ((` + transliterateSQL("name") + `) gin_trgm_ops)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			logger.Log.WithError(err).Warn("pg_trgm is not available, fuzzy suggestions fall back to Levenshtein distance")
+			return
+		}
+	}
+	trigramSearch = true
+	logger.Log.Info("PostgreSQL trigram similarity ready")
+}
+
+// SuggestSongs returns the songs whose group or song name (or either, for
+// SuggestFieldAny) resembles q, most similar first. On PostgreSQL with pg_trgm
+// similarity is trigram similarity; elsewhere it is one minus the edit
+// distance relative to the longer name. Both sides are transliterated, so
+// Latin queries find Cyrillic names and the other way round. A nil minScore
+// is FuzzyThreshold; 0 keeps every match.
+func SuggestSongs(db *gorm.DB, q, field string, minScore *float64, limit int) ([]SongSuggestion, error) {
+	if field == "" {
+		field = SuggestFieldAny
+	}
+	if field != SuggestFieldGroup && field != SuggestFieldSong && field != SuggestFieldAny {
+		return nil, ErrInvalidSuggestField
+	}
+	threshold := FuzzyThreshold
+	if minScore != nil {
+		threshold = *minScore
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, ErrInvalidThreshold
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	fields := []string{field}
+	if field == SuggestFieldAny {
+		fields = []string{SuggestFieldGroup, SuggestFieldSong}
+	}
+
+	best := make(map[uint]fuzzyHit)
+	for _, f := range fields {
+		var hits []fuzzyHit
+		var err error
+		if db.Dialector.Name() == "postgres" && trigramSearch {
+			hits, err = suggestTrigram(db, q, f, threshold, limit)
+		} else {
+			hits, err = suggestLevenshtein(db, q, f, threshold)
+		}
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to look up fuzzy suggestions")
+			return nil, err
+		}
+		for _, hit := range hits {
+			if current, ok := best[hit.ID]; !ok || hit.Score > current.Score {
+				best[hit.ID] = hit
+			}
+		}
+	}
+
+	hits := make([]fuzzyHit, 0, len(best))
+	for _, hit := range best {
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	logger.Log.Infof("Fuzzy suggestions for '%s' on %s: %d song(s)", q, field, len(hits))
+	return loadSuggestions(db, hits)
+}
+
+func suggestTrigram(db *gorm.DB, q, field string, threshold float64, limit int) ([]fuzzyHit, error) {
+	column := "songs.song_name"
+	if field == SuggestFieldGroup {
+		column = "groups.name"
+	}
+	sql := `SELECT songs.id AS id, GREATEST(similarity(` + column + `, @q), similarity(` + transliterateSQL(column) + `, @tq)) AS score
+		FROM songs JOIN groups ON groups.id = songs.group_id
+		WHERE (` + column + ` % @q OR ` + transliterateSQL(column) + ` % @tq) AND songs.deleted_at IS NULL
+		ORDER BY score DESC, songs.id
+		LIMIT @limit`
+
+	var hits []fuzzyHit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The % operator compares against this setting; SET cannot take a
+		// parameter, but the threshold is a validated number.
+		if err := tx.Exec("SET LOCAL pg_trgm.similarity_threshold = " + strconv.FormatFloat(threshold, 'f', -1, 64)).Error; err != nil {
+			return err
+		}
+		args := map[string]interface{}{"q": q, "tq": search.Transliterate(q), "limit": limit}
+		return tx.Raw(sql, args).Scan(&hits).Error
+	})
+	for i := range hits {
+		hits[i].Field = field
+	}
+	return hits, err
+}
+
+// transliterateSQL spells search.Transliterate as SQL: replace() for the
+// letters that become several Latin ones, translate() for the rest. Letters
+// without a Latin spelling come last in translate's list, which drops them.
+// migrations/00014_fuzzy_transliterated indexes the result; it has to be
+// rewritten when the transliteration changes.
+func transliterateSQL(column string) string {
+	letters := make([]rune, 0, len(search.Transliteration))
+	for r := range search.Transliteration {
+		letters = append(letters, r)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+
+	expr := "lower(" + column + ")"
+	var from, to, dropped strings.Builder
+	for _, r := range letters {
+		switch latin := search.Transliteration[r]; len(latin) {
+		case 0:
+			dropped.WriteRune(r)
+		case 1:
+			from.WriteRune(r)
+			to.WriteString(latin)
+		default:
+			expr = "replace(" + expr + ", '" + string(r) + "', '" + latin + "')"
+		}
+	}
+	return "translate(" + expr + ", '" + from.String() + dropped.String() + "', '" + to.String() + "')"
+}
+
+func suggestLevenshtein(db *gorm.DB, q, field string, threshold float64) ([]fuzzyHit, error) {
+	var hits []fuzzyHit
+
+	if field == SuggestFieldGroup {
+		var groups []Group
+		if err := db.Select("id", "name").Find(&groups).Error; err != nil {
+			return nil, err
+		}
+		scores := make(map[uint]float64)
+		for _, group := range groups {
+			if score := search.Similarity(q, group.Name); score >= threshold {
+				scores[group.ID] = score
+			}
+		}
+		if len(scores) == 0 {
+			return nil, nil
+		}
+		groupIDs := make([]uint, 0, len(scores))
+		for id := range scores {
+			groupIDs = append(groupIDs, id)
+		}
+
+		var songs []Song
+		if err := db.Select("id", "group_id").Where("group_id IN ?", groupIDs).Find(&songs).Error; err != nil {
+			return nil, err
+		}
+		for _, song := range songs {
+			hits = append(hits, fuzzyHit{ID: song.ID, Field: field, Score: scores[song.GroupID]})
+		}
+		return hits, nil
+	}
+
+	var songs []Song
+	err := db.Select("id", "song_name").FindInBatches(&songs, 500, func(tx *gorm.DB, batch int) error {
+		for _, song := range songs {
+			if score := search.Similarity(q, song.SongName); score >= threshold {
+				hits = append(hits, fuzzyHit{ID: song.ID, Field: field, Score: score})
+			}
+		}
+		return nil
+	}).Error
+	return hits, err
+}
+
+func loadSuggestions(db *gorm.DB, hits []fuzzyHit) ([]SongSuggestion, error) {
+	suggestions := make([]SongSuggestion, 0, len(hits))
+	if len(hits) == 0 {
+		return suggestions, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	var songs []Song
+	if err := db.Preload("Group").Preload("Tags").Where("id IN ?", ids).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Song, len(songs))
+	for _, song := range songs {
+		byID[song.ID] = song
+	}
+
+	for _, hit := range hits {
+		if song, ok := byID[hit.ID]; ok {
+			suggestions = append(suggestions, SongSuggestion{Song: song, Field: hit.Field, Score: hit.Score})
+		}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidGroupName = errors.New("group name is required")
+	ErrGroupExists      = errors.New("group with this name already exists")
+	ErrGroupHasSongs    = errors.New("group still has songs")
+)
+
+type Group struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Name           string    `gorm:"not null" json:"name"`
+	NormalizedName string    `gorm:"not null;uniqueIndex:unique_group" json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type GroupFilter struct {
+	Name  string
+	Page  int
+	Limit int
+}
+
+type GroupInput struct {
+	Name string `json:"name" binding:"required" example:"Muse"`
+}
+
+// cleanGroupName trims the name and collapses inner whitespace, so "Muse " and
+// " Muse" are stored the same way.
+func cleanGroupName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// NormalizeGroupName returns the key used to decide whether two group names
+// refer to the same group: "Muse", "muse" and "MUSE " all normalize to "muse".
+func NormalizeGroupName(name string) string {
+	return strings.ToLower(cleanGroupName(name))
+}
+
+func GetGroups(db *gorm.DB, filter GroupFilter) ([]Group, error) {
+	var groups []Group
+	query := db.Model(&Group{})
+
+	logger.Log.Debug("Building query for GetGroups")
+
+	if filter.Name != "" {
+		query = query.Where("normalized_name LIKE ?", "%"+NormalizeGroupName(filter.Name)+"%")
+		logger.Log.Debugf("Filter: Name LIKE '%%%s%%'", filter.Name)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	err := query.Order("id").Limit(filter.Limit).Offset(offset).Find(&groups).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch groups from database")
+	} else {
+		logger.Log.Infof("Fetched %d group(s) from database", len(groups))
+	}
+
+	return groups, err
+}
+
+func GetGroup(db *gorm.DB, id uint) (Group, error) {
+	var group Group
+	err := db.First(&group, id).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to fetch group with ID %d", id)
+	}
+	return group, err
+}
+
+func findGroupByName(db *gorm.DB, name string) (Group, error) {
+	var group Group
+	err := db.Where("normalized_name = ?", NormalizeGroupName(name)).First(&group).Error
+	return group, err
+}
+
+// FindOrCreateGroup returns the group matching name after normalization,
+// creating it when no such group exists yet.
+func FindOrCreateGroup(db *gorm.DB, name string) (Group, error) {
+	name = cleanGroupName(name)
+	if name == "" {
+		return Group{}, ErrInvalidGroupName
+	}
+
+	group, err := findGroupByName(db, name)
+	if err == nil {
+		return group, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Log.WithError(err).Errorf("Failed to look up group %q", name)
+		return Group{}, err
+	}
+
+	group = Group{Name: name, NormalizedName: NormalizeGroupName(name)}
+	if err = db.Create(&group).Error; err != nil {
+		// Another request may have created the same group concurrently.
+		if existing, findErr := findGroupByName(db, name); findErr == nil {
+			return existing, nil
+		}
+		logger.Log.WithError(err).Errorf("Failed to create group %q", name)
+		return Group{}, err
+	}
+
+	logger.Log.Infof("Group created: ID=%d, Name=%s", group.ID, group.Name)
+	return group, nil
+}
+
+func CreateGroup(db *gorm.DB, group *Group) error {
+	group.Name = cleanGroupName(group.Name)
+	if group.Name == "" {
+		return ErrInvalidGroupName
+	}
+	group.NormalizedName = NormalizeGroupName(group.Name)
+
+	if _, err := findGroupByName(db, group.Name); err == nil {
+		return ErrGroupExists
+	}
+
+	err := db.Create(group).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to create group in database")
+	} else {
+		logger.Log.Infof("Group created successfully: ID=%d", group.ID)
+	}
+
+	return err
+}
+
+func UpdateGroup(db *gorm.DB, id uint, name string) (Group, error) {
+	logger.Log.Debugf("Attempting to update group with ID=%d", id)
+
+	name = cleanGroupName(name)
+	if name == "" {
+		return Group{}, ErrInvalidGroupName
+	}
+
+	var existing Group
+	if err := db.First(&existing, id).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Group with ID=%d not found for update", id)
+		return Group{}, err
+	}
+
+	if other, err := findGroupByName(db, name); err == nil && other.ID != id {
+		return Group{}, ErrGroupExists
+	}
+
+	renamed := existing.Name != name
+	existing.Name = name
+	existing.NormalizedName = NormalizeGroupName(name)
+
+	// Songs show their group's name, so they move to their next version
+	// with it.
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&existing).Error; err != nil || !renamed {
+			return err
+		}
+		return tx.Unscoped().Model(&Song{}).Where("group_id = ?", id).Updates(map[string]interface{}{
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to update group ID=%d", id)
+	} else {
+		logger.Log.Infof("Group updated successfully: ID=%d", id)
+		afterCommit(db, InvalidateAutocomplete)
+	}
+
+	return existing, err
+}
+
+func DeleteGroup(db *gorm.DB, id uint) error {
+	logger.Log.Debugf("Attempting to delete group with ID=%d", id)
+
+	// Trashed songs count too: they still belong to the group and may be
+	// restored.
+	var songs int64
+	if err := db.Unscoped().Model(&Song{}).Where("group_id = ?", id).Count(&songs).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to count songs of group ID=%d", id)
+		return err
+	}
+	if songs > 0 {
+		logger.Log.Infof("Group ID=%d still has %d song(s), refusing to delete", id, songs)
+		return ErrGroupHasSongs
+	}
+
+	err := db.Delete(&Group{}, id).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to delete group ID=%d", id)
+	} else {
+		logger.Log.Infof("Group deleted successfully: ID=%d", id)
+	}
+
+	return err
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// EnrichmentJobAttempts is how often a job is tried before it is dead.
+var EnrichmentJobAttempts = 5
+
+// States of an enrichment job.
+const (
+	JobQueued  = "queued"  // waiting for RunAt
+	JobRunning = "running" // claimed by a worker until RunAt
+	JobDone    = "done"
+	JobDead    = "dead" // gave up; LastError says why
+)
+
+// ErrJobLost is returned when finishing a job another worker has claimed
+// since, its lease having run out.
+var ErrJobLost = errors.New("enrichment job has been claimed by another worker")
+
+// EnrichmentJob enriches a pending song in the background. A running job
+// whose worker did not finish it by RunAt, e.g. because the service stopped,
+// is claimed again.
+type EnrichmentJob struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	SongID      uint       `gorm:"not null;index" json:"song_id"`
+	State       string     `gorm:"not null;index:idx_enrichment_jobs_state_run_at,priority:1" json:"state" enums:"queued,running,done,dead"`
+	Attempts    int        `gorm:"not null" json:"attempts"`
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	LastError   string     `gorm:"not null" json:"last_error,omitempty"`
+	RunAt       time.Time  `gorm:"not null;index:idx_enrichment_jobs_state_run_at,priority:2" json:"run_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// PendingSong is a song stored before enrichment and the job enriching it.
+type PendingSong struct {
+	Song Song          `json:"song"`
+	Job  EnrichmentJob `json:"job"`
+}
+
+// EnqueueEnrichment adds a job enriching the song, to run right away.
+func EnqueueEnrichment(db *gorm.DB, songID uint) (EnrichmentJob, error) {
+	job := EnrichmentJob{
+		SongID:      songID,
+		State:       JobQueued,
+		MaxAttempts: EnrichmentJobAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := db.Create(&job).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to enqueue enrichment of song ID=%d", songID)
+		return EnrichmentJob{}, err
+	}
+	logger.Log.Infof("Enrichment of song ID=%d queued as job ID=%d", songID, job.ID)
+	return job, nil
+}
+
+// GetEnrichmentJob returns a job by ID.
+func GetEnrichmentJob(db *gorm.DB, id uint) (EnrichmentJob, error) {
+	var job EnrichmentJob
+	err := db.First(&job, id).Error
+	return job, err
+}
+
+// ClaimEnrichmentJob takes the job that is due longest for a worker, which
+// holds it for lease, and counts the attempt. It returns
+// gorm.ErrRecordNotFound when no job is due. Workers racing for a job do not
+// block each other: the one whose update lands gets it, the others look
+// again.
+func ClaimEnrichmentJob(db *gorm.DB, lease time.Duration) (EnrichmentJob, error) {
+	for {
+		now := time.Now()
+		var job EnrichmentJob
+		err := db.Where("state IN ? AND run_at <= ?", []string{JobQueued, JobRunning}, now).
+			Order("run_at").Order("id").Take(&job).Error
+		if err != nil {
+			return EnrichmentJob{}, err
+		}
+
+		result := db.Model(&EnrichmentJob{}).
+			Where("id = ? AND state = ? AND attempts = ?", job.ID, job.State, job.Attempts).
+			Updates(map[string]interface{}{
+				"state":      JobRunning,
+				"attempts":   job.Attempts + 1,
+				"run_at":     now.Add(lease),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return EnrichmentJob{}, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.State, job.Attempts, job.RunAt, job.UpdatedAt = JobRunning, job.Attempts+1, now.Add(lease), now
+			logger.Log.Debugf("Claimed enrichment job ID=%d, attempt %d", job.ID, job.Attempts)
+			return job, nil
+		}
+	}
+}
+
+// CompleteEnrichmentJob writes the enriched fields of song, which must still
+// be at the version it was read at, marks it ready and the job done.
+func CompleteEnrichmentJob(db *gorm.DB, job *EnrichmentJob, song *Song) error {
+	var existing Song
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Preload("Group").First(&existing, song.ID).Error; err != nil {
+			return err
+		}
+		if existing.Version != song.Version {
+			return ErrVersionMismatch
+		}
+		before := existing
+		existing.Group = nil
+		existing.ReleaseDate, existing.Text, existing.Link = song.ReleaseDate, song.Text, song.Link
+		existing.Status, existing.EnrichmentError = SongReady, ""
+		now := time.Now()
+		existing.EnrichedAt = &now
+
+		if err := bumpVersion(tx, &existing, map[string]interface{}{
+			"release_date":     existing.ReleaseDate,
+			"text":             existing.Text,
+			"link":             existing.Link,
+			"status":           existing.Status,
+			"enrichment_error": existing.EnrichmentError,
+			"enriched_at":      existing.EnrichedAt,
+		}); err != nil {
+			return err
+		}
+		if !sameContent(&before, &existing) {
+			if err := recordRevision(tx, &before, &existing, RevisionEnrich, 0); err != nil {
+				return err
+			}
+		}
+		return finishJob(tx, job, JobDone, "")
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to complete enrichment job ID=%d", job.ID)
+		return err
+	}
+
+	logger.Log.Infof("Song ID=%d enriched by job ID=%d", song.ID, job.ID)
+	*song = existing
+	if !existing.DeletedAt.Valid {
+		indexSong(db, existing)
+	}
+	return nil
+}
+
+// RetryEnrichmentJob puts a job that failed back in the queue until at.
+func RetryEnrichmentJob(db *gorm.DB, job *EnrichmentJob, cause error, at time.Time) error {
+	result := ownJob(db, job).Updates(map[string]interface{}{
+		"state":      JobQueued,
+		"last_error": cause.Error(),
+		"run_at":     at,
+	})
+	err := result.Error
+	if err == nil && result.RowsAffected == 0 {
+		err = ErrJobLost
+	}
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to requeue enrichment job ID=%d", job.ID)
+		return err
+	}
+	job.State, job.LastError, job.RunAt = JobQueued, cause.Error(), at
+	logger.Log.Infof("Enrichment job ID=%d failed attempt %d of %d, retrying at %s: %v",
+		job.ID, job.Attempts, job.MaxAttempts, at.Format(time.RFC3339), cause)
+	return nil
+}
+
+// ReleaseEnrichmentJob puts a job back in the queue to run right away and
+// gives back the attempt it was claimed for, for causes that are not the
+// job's fault: the worker stopping, or the song being written meanwhile.
+func ReleaseEnrichmentJob(db *gorm.DB, job *EnrichmentJob, cause error) error {
+	now := time.Now()
+	result := ownJob(db, job).Updates(map[string]interface{}{
+		"state":      JobQueued,
+		"attempts":   job.Attempts - 1,
+		"last_error": cause.Error(),
+		"run_at":     now,
+	})
+	err := result.Error
+	if err == nil && result.RowsAffected == 0 {
+		err = ErrJobLost
+	}
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to release enrichment job ID=%d", job.ID)
+		return err
+	}
+	job.State, job.Attempts, job.LastError, job.RunAt = JobQueued, job.Attempts-1, cause.Error(), now
+	logger.Log.Infof("Enrichment job ID=%d released without counting the attempt: %v", job.ID, cause)
+	return nil
+}
+
+// BuryEnrichmentJob gives up on a job: the job is dead and its song failed,
+// both with the cause. A song that is no longer pending, because it has been
+// completed by hand meanwhile, keeps its status.
+func BuryEnrichmentJob(db *gorm.DB, job *EnrichmentJob, cause error) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The song may have been purged from the trash meanwhile, which
+		// leaves nothing to mark.
+		err := tx.Unscoped().Model(&Song{}).Where("id = ? AND status = ?", job.SongID, SongPending).Updates(map[string]interface{}{
+			"status":           SongFailed,
+			"enrichment_error": cause.Error(),
+			"version":          gorm.Expr("version + 1"),
+			"updated_at":       time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return finishJob(tx, job, JobDead, cause.Error())
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to bury enrichment job ID=%d", job.ID)
+		return err
+	}
+	logger.Log.Warnf("Enrichment job ID=%d of song ID=%d is dead after %d attempt(s): %v",
+		job.ID, job.SongID, job.Attempts, cause)
+	return nil
+}
+
+// ownJob selects a job as long as it is running the attempt it was claimed
+// for.
+func ownJob(db *gorm.DB, job *EnrichmentJob) *gorm.DB {
+	return db.Model(&EnrichmentJob{}).
+		Where("id = ? AND state = ? AND attempts = ?", job.ID, JobRunning, job.Attempts)
+}
+
+func finishJob(tx *gorm.DB, job *EnrichmentJob, state, lastError string) error {
+	now := time.Now()
+	result := ownJob(tx, job).
+		Updates(map[string]interface{}{"state": state, "last_error": lastError, "finished_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobLost
+	}
+	job.State, job.LastError, job.FinishedAt = state, lastError, &now
+	return nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// Migrate creates or updates the tables of all models.
+func Migrate(db *gorm.DB) error {
+	if err := migrateGroups(db); err != nil {
+		return err
+	}
+	// unique_song also counted trashed songs; unique_active_song replaces
+	// it.
+	if db.Migrator().HasIndex(&Song{}, "unique_song") {
+		if err := db.Migrator().DropIndex(&Song{}, "unique_song"); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&Group{}, &Song{}, &Album{}, &AlbumTrack{},
+		&Playlist{}, &PlaylistEntry{}, &Tag{}, &SongRevision{}, &EnrichmentCacheEntry{}, &EnrichmentJob{})
+}
+
+// migrateGroups moves a songs table from before groups, which names the group
+// in group_name, over to group_id, as migrations/00002_groups does. Songs
+// that only differ in how their group is spelled would become duplicates;
+// rather than dropping any, it fails and names them, to be merged by hand.
+// AutoMigrate makes group_id NOT NULL and adds unique_active_song afterwards.
+func migrateGroups(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&Song{}) || !migrator.HasColumn(&Song{}, "group_name") {
+		return nil
+	}
+	logger.Log.Info("Moving songs from group_name to groups")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if err := migrator.AutoMigrate(&Group{}); err != nil {
+			return err
+		}
+		if !migrator.HasColumn(&Song{}, "group_id") {
+			if err := tx.Exec("ALTER TABLE songs ADD COLUMN group_id INTEGER REFERENCES groups (id)").Error; err != nil {
+				return err
+			}
+		}
+
+		// The earliest song decides how a group name is spelled.
+		var names []string
+		err := tx.Table("songs").Where("group_id IS NULL").
+			Group("group_name").Order("MIN(id)").Pluck("group_name", &names).Error
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			group, err := FindOrCreateGroup(tx, name)
+			if err != nil {
+				return fmt.Errorf("group %q: %w", name, err)
+			}
+			err = tx.Table("songs").Where("group_id IS NULL AND group_name = ?", name).
+				Update("group_id", group.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var duplicates []struct {
+			GroupID  uint
+			SongName string
+		}
+		err = tx.Table("songs").Select("group_id, song_name").
+			Group("group_id, song_name").Having("COUNT(*) > 1").Scan(&duplicates).Error
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			songs := make([]string, len(duplicates))
+			for i, d := range duplicates {
+				songs[i] = fmt.Sprintf("%q of group ID=%d", d.SongName, d.GroupID)
+			}
+			return fmt.Errorf("songs differing only in the spelling of their group must be merged first: %s",
+				strings.Join(songs, ", "))
+		}
+
+		if migrator.HasIndex(&Song{}, "unique_song") {
+			if err := migrator.DropIndex(&Song{}, "unique_song"); err != nil {
+				return err
+			}
+		}
+		// Song.GroupName is not a column, so the migrator cannot drop it.
+		return tx.Exec("ALTER TABLE songs DROP COLUMN group_name").Error
+	})
+}
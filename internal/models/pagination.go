@@ -0,0 +1,77 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"database/sql"
+	"gorm.io/gorm"
+)
+
+// PageInfo describes where a page sits in the full result set.
+type PageInfo struct {
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Pages int   `json:"pages"`
+}
+
+func newPageInfo(total int64, page, limit int) PageInfo {
+	pages := 0
+	if limit > 0 {
+		pages = int((total + int64(limit) - 1) / int64(limit))
+	}
+	return PageInfo{Total: total, Page: page, Limit: limit, Pages: pages}
+}
+
+// SongList is a page of songs with the total number of matches.
+type SongList struct {
+	Items []Song `json:"items"`
+	PageInfo
+	// Truncated is set when a text filter ranked by relevance alone matched
+	// more songs than are listed, the SearchIndexMaxHits most relevant ones.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// VerseList is a page of a song's verses with the total number of verses.
+type VerseList struct {
+	Items []string `json:"items"`
+	PageInfo
+}
+
+// ListSongs is GetSongs plus the number of songs matching the filter. Both
+// queries run in one read-only repeatable-read transaction, so the total
+// always describes the same snapshot as the page.
+func ListSongs(db *gorm.DB, filter SongFilter) (SongList, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	var list SongList
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Counted as GetSongs ranks, so a text filter cut to the most
+		// relevant matches counts only those.
+		var total int64
+		count := filterSongs(tx, filter, len(filter.Sort) == 0)
+		if err := count.Count(&total).Error; err != nil {
+			return err
+		}
+		_, truncated := count.Get(textTruncatedKey)
+
+		songs, err := GetSongs(tx, filter)
+		if err != nil {
+			return err
+		}
+
+		list = SongList{Items: songs, PageInfo: newPageInfo(total, filter.Page, filter.Limit), Truncated: truncated}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to list songs")
+		return SongList{}, err
+	}
+
+	logger.Log.Debugf("Listed page %d of %d (%d song(s) in total)", list.Page, list.Pages, list.Total)
+	return list, nil
+}
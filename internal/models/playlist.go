@@ -0,0 +1,328 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPlaylistName = errors.New("playlist name is required")
+	ErrInvalidPosition     = errors.New("position is out of range")
+	ErrOrderMismatch       = errors.New("entry list does not match the current playlist, reload and retry")
+)
+
+type Playlist struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Name      string          `gorm:"not null" json:"name"`
+	Version   uint            `gorm:"not null;default:0" json:"version"`
+	Entries   []PlaylistEntry `json:"entries,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// PlaylistEntry is one slot of a playlist. Entries have their own ID so the
+// same song can appear more than once; Position runs from 1 without gaps.
+type PlaylistEntry struct {
+	ID         uint  `gorm:"primaryKey" json:"id"`
+	PlaylistID uint  `gorm:"not null;index" json:"playlist_id"`
+	SongID     uint  `gorm:"not null;index" json:"song_id"`
+	Song       *Song `json:"song,omitempty"`
+	Position   int   `gorm:"not null" json:"position"`
+}
+
+type PlaylistInput struct {
+	Name string `json:"name" binding:"required" example:"Friday setlist"`
+}
+
+type PlaylistEntryInput struct {
+	SongID   uint `json:"song_id" binding:"required" example:"1"`
+	Position int  `json:"position,omitempty" example:"2"`
+}
+
+type PlaylistOrderInput struct {
+	EntryIDs []uint `json:"entry_ids" binding:"required" example:"3,1,2"`
+}
+
+func GetPlaylists(db *gorm.DB, page, limit int) ([]Playlist, error) {
+	var playlists []Playlist
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	err := db.Order("id").Limit(limit).Offset(offset).Find(&playlists).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch playlists from database")
+	} else {
+		logger.Log.Infof("Fetched %d playlist(s) from database", len(playlists))
+	}
+
+	return playlists, err
+}
+
+// GetPlaylist loads a playlist with its entries in play order.
+func GetPlaylist(db *gorm.DB, id uint) (Playlist, error) {
+	var playlist Playlist
+	err := db.Preload("Entries", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position")
+	}).
+		Preload("Entries.Song.Group").
+		First(&playlist, id).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to fetch playlist with ID %d", id)
+	}
+	return playlist, err
+}
+
+func CreatePlaylist(db *gorm.DB, playlist *Playlist) error {
+	playlist.Name = strings.TrimSpace(playlist.Name)
+	if playlist.Name == "" {
+		return ErrInvalidPlaylistName
+	}
+
+	err := db.Omit("Entries").Create(playlist).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to create playlist in database")
+	} else {
+		logger.Log.Infof("Playlist created successfully: ID=%d", playlist.ID)
+	}
+
+	return err
+}
+
+func RenamePlaylist(db *gorm.DB, id uint, name string) (Playlist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Playlist{}, ErrInvalidPlaylistName
+	}
+
+	var playlist Playlist
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if playlist, err = lockPlaylist(tx, id); err != nil {
+			return err
+		}
+		playlist.Name = name
+		return tx.Omit("Entries").Save(&playlist).Error
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to rename playlist ID=%d", id)
+	} else {
+		logger.Log.Infof("Playlist renamed successfully: ID=%d", id)
+	}
+
+	return playlist, err
+}
+
+func DeletePlaylist(db *gorm.DB, id uint) error {
+	logger.Log.Debugf("Attempting to delete playlist with ID=%d", id)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("playlist_id = ?", id).Delete(&PlaylistEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Playlist{}, id).Error
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to delete playlist ID=%d", id)
+	} else {
+		logger.Log.Infof("Playlist deleted successfully: ID=%d", id)
+	}
+
+	return err
+}
+
+// AddPlaylistEntry inserts a song at the given position, shifting the entries
+// after it down by one. A zero position appends the song.
+func AddPlaylistEntry(db *gorm.DB, playlistID uint, entry *PlaylistEntry) error {
+	logger.Log.Debugf("Adding song ID=%d to playlist ID=%d", entry.SongID, playlistID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		playlist, err := lockPlaylist(tx, playlistID)
+		if err != nil {
+			return err
+		}
+		if err = tx.Select("id").First(&Song{}, entry.SongID).Error; err != nil {
+			return err
+		}
+
+		entries, err := playlistEntries(tx, playlistID)
+		if err != nil {
+			return err
+		}
+
+		if entry.Position == 0 {
+			entry.Position = len(entries) + 1
+		}
+		if entry.Position < 1 || entry.Position > len(entries)+1 {
+			return ErrInvalidPosition
+		}
+
+		entry.ID = 0
+		entry.PlaylistID = playlistID
+		if err = tx.Omit("Song").Create(entry).Error; err != nil {
+			return err
+		}
+
+		ordered := make([]PlaylistEntry, 0, len(entries)+1)
+		ordered = append(ordered, entries[:entry.Position-1]...)
+		ordered = append(ordered, *entry)
+		ordered = append(ordered, entries[entry.Position-1:]...)
+
+		return renumberEntries(tx, &playlist, ordered)
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to add song ID=%d to playlist ID=%d", entry.SongID, playlistID)
+	} else {
+		logger.Log.Infof("Song ID=%d added to playlist ID=%d at position %d", entry.SongID, playlistID, entry.Position)
+	}
+
+	return err
+}
+
+func RemovePlaylistEntry(db *gorm.DB, playlistID, entryID uint) error {
+	logger.Log.Debugf("Removing entry ID=%d from playlist ID=%d", entryID, playlistID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		playlist, err := lockPlaylist(tx, playlistID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("playlist_id = ? AND id = ?", playlistID, entryID).Delete(&PlaylistEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		entries, err := playlistEntries(tx, playlistID)
+		if err != nil {
+			return err
+		}
+		return renumberEntries(tx, &playlist, entries)
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to remove entry ID=%d from playlist ID=%d", entryID, playlistID)
+	} else {
+		logger.Log.Infof("Entry ID=%d removed from playlist ID=%d", entryID, playlistID)
+	}
+
+	return err
+}
+
+// ReorderPlaylist puts the entries in the order given by entryIDs. The list
+// must name every current entry exactly once; a client working from a stale
+// copy of the playlist gets ErrOrderMismatch instead of losing entries.
+func ReorderPlaylist(db *gorm.DB, playlistID uint, entryIDs []uint) error {
+	logger.Log.Debugf("Reordering playlist ID=%d: %v", playlistID, entryIDs)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		playlist, err := lockPlaylist(tx, playlistID)
+		if err != nil {
+			return err
+		}
+
+		entries, err := playlistEntries(tx, playlistID)
+		if err != nil {
+			return err
+		}
+		if len(entries) != len(entryIDs) {
+			return ErrOrderMismatch
+		}
+
+		byID := make(map[uint]PlaylistEntry, len(entries))
+		for _, e := range entries {
+			byID[e.ID] = e
+		}
+
+		ordered := make([]PlaylistEntry, 0, len(entryIDs))
+		for _, id := range entryIDs {
+			e, ok := byID[id]
+			if !ok {
+				return ErrOrderMismatch
+			}
+			delete(byID, id)
+			ordered = append(ordered, e)
+		}
+
+		return renumberEntries(tx, &playlist, ordered)
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to reorder playlist ID=%d", playlistID)
+	} else {
+		logger.Log.Infof("Playlist ID=%d reordered", playlistID)
+	}
+
+	return err
+}
+
+// removeSongFromPlaylists drops every entry of the song and closes the gaps it
+// leaves behind. It is called by DeleteSong inside its transaction.
+func removeSongFromPlaylists(tx *gorm.DB, songID uint) error {
+	var playlistIDs []uint
+	err := tx.Model(&PlaylistEntry{}).Distinct("playlist_id").
+		Where("song_id = ?", songID).Pluck("playlist_id", &playlistIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, playlistID := range playlistIDs {
+		playlist, err := lockPlaylist(tx, playlistID)
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("playlist_id = ? AND song_id = ?", playlistID, songID).Delete(&PlaylistEntry{}).Error; err != nil {
+			return err
+		}
+		entries, err := playlistEntries(tx, playlistID)
+		if err != nil {
+			return err
+		}
+		if err = renumberEntries(tx, &playlist, entries); err != nil {
+			return err
+		}
+		logger.Log.Debugf("Song ID=%d removed from playlist ID=%d", songID, playlistID)
+	}
+
+	return nil
+}
+
+// lockPlaylist loads the playlist row with SELECT ... FOR UPDATE so that
+// concurrent edits of the same playlist are applied one after another.
+func lockPlaylist(tx *gorm.DB, id uint) (Playlist, error) {
+	var playlist Playlist
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&playlist, id).Error
+	return playlist, err
+}
+
+func playlistEntries(tx *gorm.DB, playlistID uint) ([]PlaylistEntry, error) {
+	var entries []PlaylistEntry
+	err := tx.Where("playlist_id = ?", playlistID).Order("position, id").Find(&entries).Error
+	return entries, err
+}
+
+// renumberEntries stores positions 1..n in the order of entries and bumps the
+// playlist version.
+func renumberEntries(tx *gorm.DB, playlist *Playlist, entries []PlaylistEntry) error {
+	for i, e := range entries {
+		if e.Position == i+1 {
+			continue
+		}
+		if err := tx.Model(&PlaylistEntry{}).Where("id = ?", e.ID).Update("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+
+	playlist.Version++
+	return tx.Model(playlist).Update("version", playlist.Version).Error
+}
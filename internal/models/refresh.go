@@ -0,0 +1,211 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"database/sql/driver"
+	"fmt"
+	"gorm.io/gorm"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Song fields the enrichment providers fill in.
+const (
+	FieldReleaseDate = "release_date"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+// EnrichedFields lists the fields the providers fill in, in order.
+var EnrichedFields = FieldList{FieldReleaseDate, FieldText, FieldLink}
+
+// ProtectManualEdits lists the enriched fields that EditSong protects from
+// refreshes when it changes them.
+var ProtectManualEdits = EnrichedFields
+
+// FieldList is a set of enriched field names, kept in the order of
+// EnrichedFields and stored comma-separated.
+type FieldList []string
+
+// ParseFieldList reads enriched field names in any case and order; "none"
+// names no field.
+func ParseFieldList(names []string) (FieldList, error) {
+	var list FieldList
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "" || name == "none":
+		case slices.Contains(EnrichedFields, name):
+			list = list.With(name)
+		default:
+			return nil, fmt.Errorf("unknown field %q, expected release_date, text or link", name)
+		}
+	}
+	return list, nil
+}
+
+func (l FieldList) Has(field string) bool {
+	return slices.Contains(l, field)
+}
+
+// With returns the list with field added, leaving l as it is.
+func (l FieldList) With(field string) FieldList {
+	if l.Has(field) {
+		return l
+	}
+	var list FieldList
+	for _, f := range EnrichedFields {
+		if f == field || l.Has(f) {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+func (l FieldList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *FieldList) Scan(src interface{}) error {
+	var value string
+	switch src := src.(type) {
+	case nil:
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into FieldList", src)
+	}
+	*l = nil
+	if value != "" {
+		*l = strings.Split(value, ",")
+	}
+	return nil
+}
+
+// ProtectedFieldsInput lists the fields of a song that refreshes leave alone.
+type ProtectedFieldsInput struct {
+	Fields []string `json:"fields" example:"text"`
+}
+
+// sameField reports whether two states of a song agree on an enriched
+// field.
+func sameField(a, b *Song, field string) bool {
+	switch field {
+	case FieldReleaseDate:
+		return a.ReleaseDate.Equal(b.ReleaseDate)
+	case FieldText:
+		return a.Text == b.Text
+	case FieldLink:
+		return a.Link == b.Link
+	}
+	return true
+}
+
+// SetProtectedFields replaces the fields of a song that refreshes leave
+// alone. A version other than zero must match the stored song's, as for
+// UpdateSong.
+func SetProtectedFields(db *gorm.DB, id, version uint, fields FieldList) (Song, error) {
+	var song Song
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Group").Preload("Tags").First(&song, id).Error; err != nil {
+			return err
+		}
+		if version != 0 && version != song.Version {
+			return ErrVersionMismatch
+		}
+		song.ProtectedFields = fields
+		return bumpVersion(tx, &song, map[string]interface{}{"protected_fields": fields})
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to set protected fields of song ID=%d", id)
+		return Song{}, err
+	}
+	logger.Log.Infof("Protected fields of song ID=%d set to %v", id, fields)
+	return song, nil
+}
+
+// RefreshSong writes what the providers answered for a song, fetched, over
+// the fields that are not protected, skipping those fetched lacks. The song
+// is read in the same transaction, so an edit made while the providers were
+// asked is protected already. A song that has a release date afterwards is
+// ready. It reports whether any field changed; when none did and the status
+// stays the same, only enriched_at is written and the version is kept.
+func RefreshSong(db *gorm.DB, id uint, fetched Song) (Song, bool, error) {
+	var song Song
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Group").Preload("Tags").First(&song, id).Error; err != nil {
+			return err
+		}
+		before := song
+
+		if !fetched.ReleaseDate.IsZero() && !song.ProtectedFields.Has(FieldReleaseDate) {
+			song.ReleaseDate = fetched.ReleaseDate
+		}
+		if fetched.Text != "" && !song.ProtectedFields.Has(FieldText) {
+			song.Text = fetched.Text
+		}
+		if fetched.Link != "" && !song.ProtectedFields.Has(FieldLink) {
+			song.Link = fetched.Link
+		}
+		if song.Status != SongReady && !song.ReleaseDate.IsZero() {
+			song.Status, song.EnrichmentError = SongReady, ""
+		}
+		now := time.Now()
+		song.EnrichedAt = &now
+
+		changed = !sameContent(&before, &song)
+		if !changed && song.Status == before.Status {
+			// Only the time of the refresh is new, which does not make
+			// another version of the song.
+			return tx.Model(&Song{}).Where("id = ?", id).UpdateColumn("enriched_at", song.EnrichedAt).Error
+		}
+		if err := bumpVersion(tx, &song, map[string]interface{}{
+			"release_date":     song.ReleaseDate,
+			"text":             song.Text,
+			"link":             song.Link,
+			"status":           song.Status,
+			"enrichment_error": song.EnrichmentError,
+			"enriched_at":      song.EnrichedAt,
+		}); err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		return recordRevision(tx, &before, &song, RevisionEnrich, 0)
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to refresh song ID=%d", id)
+		return Song{}, false, err
+	}
+
+	logger.Log.Infof("Song ID=%d refreshed, changed: %t", id, changed)
+	if changed {
+		indexSong(db, song)
+	}
+	return song, changed, nil
+}
+
+// SongIDs returns the IDs of the songs matching the filter's conditions, at
+// most limit of them.
+func SongIDs(db *gorm.DB, filter SongFilter, limit int) ([]uint, error) {
+	var ids []uint
+	err := filterSongs(db, filter, false).Order("id").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// StaleSongIDs returns the songs enriched (or, if never, created) before
+// cutoff, oldest first, at most limit of them. Pending songs are left to
+// their jobs.
+func StaleSongIDs(db *gorm.DB, cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&Song{}).
+		Where("status <> ? AND COALESCE(enriched_at, created_at) < ?", SongPending, cutoff).
+		Order("COALESCE(enriched_at, created_at)").Order("id").
+		Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
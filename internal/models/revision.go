@@ -0,0 +1,260 @@
+package models
+
+import (
+	"SongLibrary/internal/diff"
+	"SongLibrary/pkg/logger"
+	"context"
+	"database/sql"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// Changes a revision can record.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+	RevisionEnrich  = "enrich"
+)
+
+// MaxActorLength bounds the actor name stored with a revision.
+const MaxActorLength = 100
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// SongRevision is the state of a song right after one change to it, with
+// who made the change and when. Revisions are numbered per song from 1.
+type SongRevision struct {
+	ID           uint      `gorm:"primaryKey" json:"-"`
+	SongID       uint      `gorm:"not null;uniqueIndex:unique_song_revision" json:"song_id"`
+	Revision     int       `gorm:"not null;uniqueIndex:unique_song_revision" json:"revision"`
+	Action       string    `gorm:"not null" json:"action" enums:"create,update,delete,restore,revert,enrich"`
+	Actor        string    `gorm:"not null" json:"actor"`
+	RevertedFrom int       `json:"reverted_from,omitempty"`
+	GroupName    string    `gorm:"not null" json:"group_name"`
+	SongName     string    `gorm:"not null" json:"song_name"`
+	ReleaseDate  time.Time `gorm:"not null" json:"release_date"`
+	Text         string    `gorm:"not null" json:"text"`
+	Link         string    `gorm:"not null" json:"link"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RevisionList is a page of a song's revisions with the total number of
+// revisions.
+type RevisionList struct {
+	Items []SongRevision `json:"items"`
+	PageInfo
+}
+
+// FieldChange is a metadata field that differs between two revisions.
+// Release dates are compared as dates.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// RevisionDiff is what a revision changed compared to the one before it
+// (revision 0, an empty song, for the first).
+type RevisionDiff struct {
+	SongID    uint          `json:"song_id"`
+	Revision  int           `json:"revision"`
+	Previous  int           `json:"previous"`
+	Action    string        `json:"action"`
+	Actor     string        `json:"actor"`
+	CreatedAt time.Time     `json:"created_at"`
+	Changes   []FieldChange `json:"changes"`
+	Lyrics    []diff.Line   `json:"lyrics"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a context naming who makes the changes. Song writes made
+// through a session with that context (db.WithContext) record the actor in
+// their revisions.
+func WithActor(ctx context.Context, actor string) context.Context {
+	actor = strings.TrimSpace(actor)
+	if r := []rune(actor); len(r) > MaxActorLength {
+		actor = string(r[:MaxActorLength])
+	}
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorOf(db *gorm.DB) string {
+	if db.Statement.Context == nil {
+		return ""
+	}
+	actor, _ := db.Statement.Context.Value(actorKey{}).(string)
+	return actor
+}
+
+// recordRevision stores the state of song as its next revision. Songs that
+// predate revision history have no revisions yet; for them the state before
+// the change, when known, is stored first as a create revision dated to the
+// song's last update, so the first diff has something to compare against.
+func recordRevision(tx *gorm.DB, before, song *Song, action string, revertedFrom int) error {
+	var last int
+	err := tx.Model(&SongRevision{}).Where("song_id = ?", song.ID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+
+	if last == 0 && before != nil && action != RevisionCreate {
+		baseline := snapshot(before, 1, RevisionCreate)
+		baseline.CreatedAt = before.UpdatedAt
+		if err := tx.Create(&baseline).Error; err != nil {
+			return err
+		}
+		last = 1
+	}
+
+	revision := snapshot(song, last+1, action)
+	revision.Actor = actorOf(tx)
+	revision.RevertedFrom = revertedFrom
+	if err := tx.Create(&revision).Error; err != nil {
+		return err
+	}
+	logger.Log.Debugf("Recorded revision %d (%s) of song ID=%d", revision.Revision, action, song.ID)
+	return nil
+}
+
+func snapshot(song *Song, revision int, action string) SongRevision {
+	return SongRevision{
+		SongID:      song.ID,
+		Revision:    revision,
+		Action:      action,
+		GroupName:   song.GroupName,
+		SongName:    song.SongName,
+		ReleaseDate: song.ReleaseDate,
+		Text:        song.Text,
+		Link:        song.Link,
+	}
+}
+
+// ListRevisions returns a page of a song's revisions, newest first. Songs in
+// the trash keep their history.
+func ListRevisions(db *gorm.DB, songID uint, page, limit int) (RevisionList, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	var list RevisionList
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Select("id").First(&Song{}, songID).Error; err != nil {
+			return err
+		}
+
+		var total int64
+		if err := tx.Model(&SongRevision{}).Where("song_id = ?", songID).Count(&total).Error; err != nil {
+			return err
+		}
+
+		var revisions []SongRevision
+		err := tx.Where("song_id = ?", songID).Order("revision DESC").
+			Limit(limit).Offset((page - 1) * limit).
+			Find(&revisions).Error
+		if err != nil {
+			return err
+		}
+
+		list = RevisionList{Items: revisions, PageInfo: newPageInfo(total, page, limit)}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to list revisions of song ID=%d", songID)
+		return RevisionList{}, err
+	}
+
+	logger.Log.Infof("Fetched %d of %d revision(s) of song ID=%d", len(list.Items), list.Total, songID)
+	return list, nil
+}
+
+// GetRevision returns one revision of a song.
+func GetRevision(db *gorm.DB, songID uint, revision int) (SongRevision, error) {
+	var rev SongRevision
+	err := db.Where("song_id = ? AND revision = ?", songID, revision).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return SongRevision{}, ErrRevisionNotFound
+	}
+	return rev, err
+}
+
+// DiffRevision compares a revision with the one before it: the metadata
+// fields that changed and a line-level diff of the lyrics.
+func DiffRevision(db *gorm.DB, songID uint, revision int) (RevisionDiff, error) {
+	rev, err := GetRevision(db, songID, revision)
+	if err != nil {
+		return RevisionDiff{}, err
+	}
+
+	var prev SongRevision
+	err = db.Where("song_id = ? AND revision < ?", songID, revision).
+		Order("revision DESC").Limit(1).Find(&prev).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to load revision before %d of song ID=%d", revision, songID)
+		return RevisionDiff{}, err
+	}
+
+	result := RevisionDiff{
+		SongID:    songID,
+		Revision:  rev.Revision,
+		Previous:  prev.Revision,
+		Action:    rev.Action,
+		Actor:     rev.Actor,
+		CreatedAt: rev.CreatedAt,
+		Changes:   []FieldChange{},
+		Lyrics:    diff.Lines(prev.Text, rev.Text),
+	}
+
+	dates := [2]string{}
+	for i, d := range []time.Time{prev.ReleaseDate, rev.ReleaseDate} {
+		if !d.IsZero() {
+			dates[i] = d.Format("2006-01-02")
+		}
+	}
+	fields := []FieldChange{
+		{Field: "group_name", From: prev.GroupName, To: rev.GroupName},
+		{Field: "song_name", From: prev.SongName, To: rev.SongName},
+		{Field: "release_date", From: dates[0], To: dates[1]},
+		{Field: "link", From: prev.Link, To: rev.Link},
+	}
+	for _, field := range fields {
+		if field.From != field.To {
+			result.Changes = append(result.Changes, field)
+		}
+	}
+	return result, nil
+}
+
+// RevertSong puts a song back in the state recorded by one of its revisions.
+// The revert itself is recorded as a new revision; history is never
+// rewritten.
+func RevertSong(db *gorm.DB, songID uint, revision int) (Song, error) {
+	rev, err := GetRevision(db, songID, revision)
+	if err != nil {
+		return Song{}, err
+	}
+
+	song := Song{
+		ID:          songID,
+		GroupName:   rev.GroupName,
+		SongName:    rev.SongName,
+		ReleaseDate: rev.ReleaseDate,
+		Text:        rev.Text,
+		Link:        rev.Link,
+	}
+	if err = updateSong(db, &song, RevisionRevert, revision, false); err != nil {
+		return Song{}, err
+	}
+
+	logger.Log.Infof("Song ID=%d reverted to revision %d", songID, revision)
+	return song, nil
+}
@@ -0,0 +1,329 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"encoding/binary"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+)
+
+// SearchConfig is the PostgreSQL text search configuration used for lyrics.
+const SearchConfig = "english"
+
+type SearchResult struct {
+	Song    Song    `json:"song"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchHit struct {
+	ID      uint
+	Rank    float64
+	Snippet string
+}
+
+// SetupSearch creates the full-text index that SearchSongs relies on. On
+// PostgreSQL that is a generated tsvector column with a GIN index; on SQLite an
+// external-content FTS5 table (FTS4 when the driver is built without
+// sqlite_fts5) kept in sync by triggers. It is safe to call on every start.
+func SetupSearch(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return setupPostgresSearch(db)
+	case "sqlite":
+		return setupSQLiteSearch(db)
+	}
+	logger.Log.Warnf("Full-text search is not supported on %s", db.Dialector.Name())
+	return nil
+}
+
+func setupPostgresSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE songs ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + SearchConfig + `', coalesce(song_name, '')), 'A') ||
+				setweight(to_tsvector('` + SearchConfig + `', coalesce(text, '')), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_songs_search_vector ON songs USING GIN (search_vector)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			logger.Log.WithError(err).Error("Failed to set up PostgreSQL full-text search")
+			return err
+		}
+	}
+	logger.Log.Info("PostgreSQL full-text search ready")
+	setupPostgresFuzzy(db)
+	setupPostgresAutocomplete(db)
+	return nil
+}
+
+func setupSQLiteSearch(db *gorm.DB) error {
+	module, err := sqliteSearchModule(db)
+	if err != nil {
+		return err
+	}
+
+	if module == "" {
+		module = "fts5"
+		create := `CREATE VIRTUAL TABLE songs_fts USING fts5(
+			song_name, text, content='songs', content_rowid='id', tokenize='porter unicode61')`
+		if err = db.Exec(create).Error; err != nil {
+			if !strings.Contains(err.Error(), "no such module") {
+				logger.Log.WithError(err).Error("Failed to create FTS5 table")
+				return err
+			}
+			logger.Log.Info("SQLite driver has no FTS5, falling back to FTS4")
+			module = "fts4"
+			create = `CREATE VIRTUAL TABLE songs_fts USING fts4(
+				content="songs", song_name, text, tokenize=porter)`
+			if err = db.Exec(create).Error; err != nil {
+				logger.Log.WithError(err).Error("Failed to create FTS4 table")
+				return err
+			}
+		}
+	}
+
+	var triggers []string
+	if module == "fts5" {
+		triggers = []string{
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_ai AFTER INSERT ON songs BEGIN
+				INSERT INTO songs_fts(rowid, song_name, text) VALUES (new.id, new.song_name, new.text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_ad AFTER DELETE ON songs BEGIN
+				INSERT INTO songs_fts(songs_fts, rowid, song_name, text) VALUES ('delete', old.id, old.song_name, old.text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_au AFTER UPDATE ON songs BEGIN
+				INSERT INTO songs_fts(songs_fts, rowid, song_name, text) VALUES ('delete', old.id, old.song_name, old.text);
+				INSERT INTO songs_fts(rowid, song_name, text) VALUES (new.id, new.song_name, new.text);
+			END`,
+		}
+	} else {
+		triggers = []string{
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_ai AFTER INSERT ON songs BEGIN
+				INSERT INTO songs_fts(docid, song_name, text) VALUES (new.id, new.song_name, new.text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_bd BEFORE DELETE ON songs BEGIN
+				DELETE FROM songs_fts WHERE docid = old.id;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_bu BEFORE UPDATE ON songs BEGIN
+				DELETE FROM songs_fts WHERE docid = old.id;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS songs_fts_au AFTER UPDATE ON songs BEGIN
+				INSERT INTO songs_fts(docid, song_name, text) VALUES (new.id, new.song_name, new.text);
+			END`,
+		}
+	}
+	triggers = append(triggers, `INSERT INTO songs_fts(songs_fts) VALUES ('rebuild')`)
+
+	for _, stmt := range triggers {
+		if err := db.Exec(stmt).Error; err != nil {
+			logger.Log.WithError(err).Errorf("Failed to set up SQLite %s search", module)
+			return err
+		}
+	}
+	logger.Log.Infof("SQLite %s full-text search ready", module)
+	return nil
+}
+
+// SearchSongs runs a full-text lyric and title search (see ParseSearchQuery for
+// the syntax) and returns the matching songs, most relevant first, with a
+// highlighted lyric snippet.
+func SearchSongs(db *gorm.DB, q string, page, limit int) ([]SearchResult, error) {
+	node, err := ParseSearchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	var hits []searchHit
+	switch db.Dialector.Name() {
+	case "postgres":
+		hits, err = searchPostgres(db, node.toTSQuery(), limit, offset)
+	case "sqlite":
+		hits, err = searchSQLite(db, node, limit, offset)
+	default:
+		logger.Log.Warnf("Full-text search is not supported on %s", db.Dialector.Name())
+		return []SearchResult{}, nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("Full-text search failed")
+		return nil, err
+	}
+
+	results, err := loadSearchResults(db, hits)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to load songs for search results")
+		return nil, err
+	}
+
+	logger.Log.Infof("Search %q matched %d song(s) on this page", q, len(results))
+	return results, nil
+}
+
+func searchPostgres(db *gorm.DB, tsquery string, limit, offset int) ([]searchHit, error) {
+	logger.Log.Debugf("Search tsquery: %s", tsquery)
+
+	var hits []searchHit
+	err := db.Raw(`
+		SELECT id,
+		       ts_rank_cd(search_vector, query) AS rank,
+		       ts_headline('`+SearchConfig+`', text, query,
+		                   'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+		FROM songs, to_tsquery('`+SearchConfig+`', ?) AS query
+		WHERE search_vector @@ query AND deleted_at IS NULL
+		ORDER BY rank DESC, id
+		LIMIT ? OFFSET ?`, tsquery, limit, offset).Scan(&hits).Error
+	return hits, err
+}
+
+// sqliteSearchModule reports which FTS module backs songs_fts, or "" when the
+// table has not been created yet.
+func sqliteSearchModule(db *gorm.DB) (string, error) {
+	var ddl string
+	if err := db.Raw("SELECT sql FROM sqlite_master WHERE name = 'songs_fts'").Scan(&ddl).Error; err != nil {
+		return "", err
+	}
+	ddl = strings.ToLower(ddl)
+	switch {
+	case strings.Contains(ddl, "fts5"):
+		return "fts5", nil
+	case strings.Contains(ddl, "fts4"):
+		return "fts4", nil
+	}
+	return "", nil
+}
+
+func searchSQLite(db *gorm.DB, node *searchNode, limit, offset int) ([]searchHit, error) {
+	module, err := sqliteSearchModule(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []searchHit
+	if module == "fts5" {
+		match := node.toMatch(true)
+		logger.Log.Debugf("Search FTS5 match: %s", match)
+
+		// bm25 is lower for better matches; negate it so that rank grows with relevance.
+		err = db.Raw(`
+			SELECT rowid AS id,
+			       -bm25(songs_fts, 2.0, 1.0) AS rank,
+			       snippet(songs_fts, 1, '<mark>', '</mark>', '…', 12) AS snippet
+			FROM songs_fts
+			WHERE songs_fts MATCH ? AND rowid IN (SELECT id FROM songs WHERE deleted_at IS NULL)
+			ORDER BY rank DESC, rowid
+			LIMIT ? OFFSET ?`, match, limit, offset).Scan(&hits).Error
+		return hits, err
+	}
+
+	match := node.toMatch(false)
+	logger.Log.Debugf("Search FTS4 match: %s", match)
+
+	// FTS4 has no ranking function, so every match is scored here from
+	// matchinfo and the page is cut afterwards.
+	var rows []struct {
+		ID      uint
+		Info    []byte
+		Snippet string
+	}
+	err = db.Raw(`
+		SELECT docid AS id,
+		       matchinfo(songs_fts, 'pcx') AS info,
+		       snippet(songs_fts, '<mark>', '</mark>', '…', 1, 12) AS snippet
+		FROM songs_fts
+		WHERE songs_fts MATCH ? AND docid IN (SELECT id FROM songs WHERE deleted_at IS NULL)`, match).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		hits = append(hits, searchHit{ID: row.ID, Rank: rankMatchInfo(row.Info, []float64{2.0, 1.0}), Snippet: row.Snippet})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	if offset >= len(hits) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return hits[offset:end], nil
+}
+
+// rankMatchInfo scores an FTS4 matchinfo 'pcx' blob: for every phrase and
+// column, the share of all hits of that phrase that fall in this row,
+// multiplied by the column weight.
+func rankMatchInfo(info []byte, weights []float64) float64 {
+	if len(info) < 8 {
+		return 0
+	}
+	value := func(i int) float64 {
+		return float64(binary.NativeEndian.Uint32(info[i*4:]))
+	}
+	phrases, columns := int(value(0)), int(value(1))
+	if len(info) < (2+phrases*columns*3)*4 {
+		return 0
+	}
+
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns; c++ {
+			base := 2 + (p*columns+c)*3
+			hitsInRow, hitsInAll := value(base), value(base+1)
+			if hitsInRow == 0 || hitsInAll == 0 {
+				continue
+			}
+			weight := 1.0
+			if c < len(weights) {
+				weight = weights[c]
+			}
+			score += weight * hitsInRow / hitsInAll
+		}
+	}
+	return score
+}
+
+func loadSearchResults(db *gorm.DB, hits []searchHit) ([]SearchResult, error) {
+	results := make([]SearchResult, 0, len(hits))
+	if len(hits) == 0 {
+		return results, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	var songs []Song
+	if err := db.Preload("Group").Preload("Tags").Where("id IN ?", ids).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Song, len(songs))
+	for _, song := range songs {
+		byID[song.ID] = song
+	}
+
+	for _, hit := range hits {
+		song, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{Song: song, Rank: hit.Rank, Snippet: hit.Snippet})
+	}
+	return results, nil
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"fmt"
+	"gorm.io/gorm"
+	"slices"
+	"strings"
+)
+
+// SearchIndex is a lyric search engine that lives next to the database.
+// CreateSong, UpdateSong and DeleteSong keep it in sync, and GetSongs uses it
+// for the text filter instead of ILIKE when one is configured.
+type SearchIndex interface {
+	// Put indexes a song, replacing any earlier version of it.
+	Put(id uint, title, text string)
+	// Delete drops a song from the index.
+	Delete(id uint)
+	// Search returns the IDs of the songs matching every word of the query,
+	// most relevant first.
+	Search(query string) []uint
+	// IDs returns the IDs of the indexed songs.
+	IDs() []uint
+}
+
+var songIndex SearchIndex
+
+// SearchIndexMaxHits is how many of the songs the index matches, the most
+// relevant ones, a text filter ranked by relevance alone lists. Combined with
+// other filters or a sort, the text filter keeps every match.
+var SearchIndexMaxHits = 1000
+
+// textTruncatedKey is the gorm setting filterByIndex leaves on a query whose
+// matches it cut to SearchIndexMaxHits.
+const textTruncatedKey = "songs:text_truncated"
+
+// SetSearchIndex selects the index used for the text filter; nil restores the
+// plain database search.
+func SetSearchIndex(index SearchIndex) {
+	songIndex = index
+}
+
+// RebuildSearchIndex loads every song into the configured index. It is meant
+// to run once on startup, before the server accepts requests.
+func RebuildSearchIndex(db *gorm.DB) error {
+	if songIndex == nil {
+		return nil
+	}
+
+	var songs []Song
+	total := 0
+	err := db.Select("id", "song_name", "text").FindInBatches(&songs, 500, func(tx *gorm.DB, batch int) error {
+		for _, song := range songs {
+			songIndex.Put(song.ID, song.SongName, song.Text)
+		}
+		total += len(songs)
+		return nil
+	}).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to rebuild search index")
+		return err
+	}
+
+	logger.Log.Infof("Search index rebuilt with %d song(s)", total)
+	return nil
+}
+
+// ReloadSearch catches the search index and autocomplete up with writes made
+// outside the server, such as a songlib import: it loads every song into the
+// index again, drops the songs that no longer exist and marks the completions
+// stale.
+func ReloadSearch(db *gorm.DB) error {
+	InvalidateAutocomplete()
+	if songIndex == nil {
+		return nil
+	}
+
+	indexed := songIndex.IDs()
+	if err := RebuildSearchIndex(db); err != nil {
+		return err
+	}
+
+	dropped := 0
+	const batch = 500
+	for start := 0; start < len(indexed); start += batch {
+		chunk := indexed[start:min(start+batch, len(indexed))]
+		var live []uint
+		if err := db.Model(&Song{}).Where("id IN ?", chunk).Pluck("id", &live).Error; err != nil {
+			logger.Log.WithError(err).Error("Failed to reload search index")
+			return err
+		}
+		exists := make(map[uint]bool, len(live))
+		for _, id := range live {
+			exists[id] = true
+		}
+		for _, id := range chunk {
+			if !exists[id] {
+				songIndex.Delete(id)
+				dropped++
+			}
+		}
+	}
+
+	logger.Log.Infof("Search index reloaded, %d stale song(s) dropped", dropped)
+	return nil
+}
+
+// indexSong puts a song that db wrote into the search index once the write
+// has committed.
+func indexSong(db *gorm.DB, song Song) {
+	afterCommit(db, func() {
+		if songIndex != nil {
+			songIndex.Put(song.ID, song.SongName, song.Text)
+		}
+	})
+}
+
+// unindexSong drops a song that db deleted from the search index once the
+// delete has committed.
+func unindexSong(db *gorm.DB, id uint) {
+	afterCommit(db, func() {
+		if songIndex != nil {
+			songIndex.Delete(id)
+		}
+	})
+}
+
+// filterByIndex restricts the query to songs the index matches for text and,
+// if ranked is set, orders them by relevance. It runs after the other
+// conditions of the filter, which the matches are intersected with, so none
+// is lost to SearchIndexMaxHits; only a text filter ranked by relevance alone
+// is cut to the most relevant matches, and says so with textTruncatedKey.
+func filterByIndex(query *gorm.DB, text string, ranked bool) *gorm.DB {
+	ids := songIndex.Search(text)
+	logger.Log.Debugf("Filter: search index matched %d song(s) for '%s'", len(ids), text)
+
+	if _, filtered := query.Statement.Clauses["WHERE"]; filtered && len(ids) > 0 {
+		var candidates []uint
+		if err := query.Session(&gorm.Session{}).Pluck("id", &candidates).Error; err != nil {
+			query.AddError(err)
+			return query
+		}
+		matched := make(map[uint]bool, len(candidates))
+		for _, id := range candidates {
+			matched[id] = true
+		}
+		ids = slices.DeleteFunc(ids, func(id uint) bool { return !matched[id] })
+		logger.Log.Debugf("Filter: %d of the matches meet the other conditions", len(ids))
+	} else if ranked && len(ids) > SearchIndexMaxHits {
+		ids = ids[:SearchIndexMaxHits]
+		query = query.Set(textTruncatedKey, true)
+	}
+	if len(ids) == 0 {
+		return query.Where("1 = 0")
+	}
+
+	// The IDs come from the index and the database, not from the client, so
+	// inlining them is safe, and there may be more than the database takes
+	// as parameters.
+	var in strings.Builder
+	in.WriteString("id IN (")
+	for i, id := range ids {
+		if i > 0 {
+			in.WriteString(",")
+		}
+		fmt.Fprintf(&in, "%d", id)
+	}
+	in.WriteString(")")
+	query = query.Where(in.String())
+	if !ranked {
+		return query
+	}
+
+	var order strings.Builder
+	order.WriteString("CASE id")
+	for i, id := range ids {
+		fmt.Fprintf(&order, " WHEN %d THEN %d", id, i)
+	}
+	order.WriteString(" END")
+
+	return query.Order(order.String())
+}
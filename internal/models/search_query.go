@@ -0,0 +1,348 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SearchQueryError reports a malformed search query and where it went wrong.
+type SearchQueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SearchQueryError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d: %s", e.Pos, e.Msg)
+}
+
+// searchNode is a parsed lyric search query. Exactly one group of fields is
+// set: Words for a term or phrase, Children for and/or, Child for not.
+type searchNode struct {
+	Op       string // "term", "and", "or", "not"
+	Words    []string
+	Prefix   bool
+	Children []*searchNode
+	Child    *searchNode
+}
+
+type searchToken struct {
+	kind string // "word", "phrase", "(", ")", "-", "*", "AND", "OR", "NOT"
+	text string
+	pos  int
+}
+
+// ParseSearchQuery parses the lyric search syntax:
+//
+//	love song          both words (AND is implied)
+//	"black hole"       phrase
+//	superm*            prefix
+//	muse OR placebo    either word
+//	-live, NOT live    exclude a word
+//	(a OR b) c         grouping
+//
+// Negated terms must sit next to at least one positive term, since none of the
+// backends can answer "everything except".
+func ParseSearchQuery(q string) (*searchNode, error) {
+	tokens, err := tokenizeSearchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, &SearchQueryError{Pos: 0, Msg: "query is empty"}
+	}
+
+	p := &searchParser{tokens: tokens, end: len([]rune(q))}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		return nil, &SearchQueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	if err = checkNegations(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func tokenizeSearchQuery(q string) ([]searchToken, error) {
+	var tokens []searchToken
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '*':
+			tokens = append(tokens, searchToken{kind: string(r), text: string(r), pos: i})
+			i++
+		case r == '-' && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, searchToken{kind: "-", text: "-", pos: i})
+			i++
+		case r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				i++
+			}
+			if i == len(runes) {
+				return nil, &SearchQueryError{Pos: start, Msg: "unterminated phrase"}
+			}
+			tokens = append(tokens, searchToken{kind: "phrase", text: string(runes[start+1 : i]), pos: start})
+			i++
+		case isSearchWordRune(r):
+			start := i
+			for i < len(runes) && isSearchWordRune(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			kind := "word"
+			if word == "AND" || word == "OR" || word == "NOT" {
+				kind = word
+			}
+			tokens = append(tokens, searchToken{kind: kind, text: word, pos: start})
+		default:
+			// Other punctuation separates words, just like whitespace.
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isSearchWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
+
+// searchWords splits text into lower-cased words the way the tokenizer does.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+	end    int
+}
+
+func (p *searchParser) peek() *searchToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *searchParser) parseOr() (*searchNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*searchNode{first}
+	for t := p.peek(); t != nil && t.kind == "OR"; t = p.peek() {
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &searchNode{Op: "or", Children: children}, nil
+}
+
+func (p *searchParser) parseAnd() (*searchNode, error) {
+	var children []*searchNode
+	for {
+		t := p.peek()
+		if t == nil || t.kind == ")" || t.kind == "OR" {
+			break
+		}
+		if t.kind == "AND" {
+			if len(children) == 0 {
+				return nil, &SearchQueryError{Pos: t.pos, Msg: "AND needs a term on its left"}
+			}
+			p.pos++
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		pos := p.end
+		if t := p.peek(); t != nil {
+			pos = t.pos
+		}
+		return nil, &SearchQueryError{Pos: pos, Msg: "expected a term"}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &searchNode{Op: "and", Children: children}, nil
+}
+
+func (p *searchParser) parseUnary() (*searchNode, error) {
+	t := p.peek()
+	if t.kind == "-" || t.kind == "NOT" {
+		p.pos++
+		if p.peek() == nil {
+			return nil, &SearchQueryError{Pos: p.end, Msg: "expected a term after " + t.text}
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &searchNode{Op: "not", Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *searchParser) parsePrimary() (*searchNode, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing := p.peek()
+		if closing == nil || closing.kind != ")" {
+			return nil, &SearchQueryError{Pos: t.pos, Msg: "unclosed parenthesis"}
+		}
+		p.pos++
+		return node, nil
+	case "phrase":
+		words := searchWords(t.text)
+		if len(words) == 0 {
+			return nil, &SearchQueryError{Pos: t.pos, Msg: "empty phrase"}
+		}
+		return &searchNode{Op: "term", Words: words}, nil
+	case "word":
+		words := searchWords(t.text)
+		if len(words) == 0 {
+			return nil, &SearchQueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+		}
+		node := &searchNode{Op: "term", Words: words}
+		if next := p.peek(); next != nil && next.kind == "*" && next.pos == t.pos+len([]rune(t.text)) {
+			p.pos++
+			node.Prefix = true
+		}
+		return node, nil
+	default:
+		return nil, &SearchQueryError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+}
+
+// checkNegations rejects queries in which a negation is not AND-ed with a
+// positive term, such as "-live" or "a OR -b".
+func checkNegations(node *searchNode) error {
+	switch node.Op {
+	case "not":
+		return &SearchQueryError{Pos: 0, Msg: "a negated term must be combined with a term that is not negated"}
+	case "or":
+		for _, child := range node.Children {
+			if err := checkNegations(child); err != nil {
+				return err
+			}
+		}
+	case "and":
+		positive := false
+		for _, child := range node.Children {
+			if child.Op == "not" {
+				if err := checkNegations(&searchNode{Op: "and", Children: []*searchNode{child.Child}}); err != nil {
+					return err
+				}
+				continue
+			}
+			positive = true
+			if err := checkNegations(child); err != nil {
+				return err
+			}
+		}
+		if !positive {
+			return &SearchQueryError{Pos: 0, Msg: "a negated term must be combined with a term that is not negated"}
+		}
+	}
+	return nil
+}
+
+// toTSQuery renders the query for PostgreSQL's to_tsquery.
+func (n *searchNode) toTSQuery() string {
+	switch n.Op {
+	case "term":
+		parts := make([]string, len(n.Words))
+		for i, w := range n.Words {
+			parts[i] = "'" + strings.ReplaceAll(w, "'", "''") + "'"
+		}
+		if n.Prefix {
+			parts[len(parts)-1] += ":*"
+		}
+		if len(parts) == 1 {
+			return parts[0]
+		}
+		return "(" + strings.Join(parts, " <-> ") + ")"
+	case "not":
+		return "!" + n.Child.toTSQuery()
+	case "and", "or":
+		sep := " & "
+		if n.Op == "or" {
+			sep = " | "
+		}
+		parts := make([]string, len(n.Children))
+		for i, child := range n.Children {
+			parts[i] = child.toTSQuery()
+		}
+		return "(" + strings.Join(parts, sep) + ")"
+	}
+	return ""
+}
+
+// toMatch renders the query for SQLite's MATCH operator. FTS5 and FTS4 differ
+// only in where the prefix star goes. NOT is binary in both, so negated terms
+// are moved behind the positive ones.
+func (n *searchNode) toMatch(fts5 bool) string {
+	switch n.Op {
+	case "term":
+		words := make([]string, len(n.Words))
+		for i, w := range n.Words {
+			words[i] = strings.ReplaceAll(w, `"`, `""`)
+		}
+		phrase := strings.Join(words, " ")
+		switch {
+		case n.Prefix && fts5:
+			return `"` + phrase + `"*`
+		case n.Prefix:
+			return `"` + phrase + `*"`
+		}
+		return `"` + phrase + `"`
+	case "or":
+		parts := make([]string, len(n.Children))
+		for i, child := range n.Children {
+			parts[i] = child.toMatch(fts5)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")"
+	case "and":
+		var positive, negative []string
+		for _, child := range n.Children {
+			if child.Op == "not" {
+				negative = append(negative, child.Child.toMatch(fts5))
+			} else {
+				positive = append(positive, child.toMatch(fts5))
+			}
+		}
+		match := "(" + strings.Join(positive, " AND ") + ")"
+		for _, neg := range negative {
+			match += " NOT " + neg
+		}
+		return "(" + match + ")"
+	}
+	return ""
+}
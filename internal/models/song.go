@@ -2,6 +2,7 @@ package models
 
 import (
 	"SongLibrary/pkg/logger"
+	"errors"
 	"fmt"
 	"gorm.io/gorm"
 	"strings"
@@ -9,42 +10,147 @@ import (
 )
 
 type Song struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	GroupName   string    `gorm:"not null" json:"group_name"`
-	SongName    string    `gorm:"not null" json:"song_name"`
-	ReleaseDate time.Time `gorm:"not null" json:"release_date"`
-	Text        string    `gorm:"not null" json:"text"`
-	Link        string    `gorm:"not null" json:"link"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	GroupID     uint           `gorm:"not null;uniqueIndex:unique_active_song,priority:1,where:deleted_at IS NULL" json:"group_id"`
+	Group       *Group         `json:"-"`
+	GroupName   string         `gorm:"-" json:"group_name"`
+	SongName    string         `gorm:"not null;uniqueIndex:unique_active_song,priority:2" json:"song_name"`
+	ReleaseDate time.Time      `gorm:"not null" json:"release_date"`
+	Text        string         `gorm:"not null" json:"text"`
+	Link        string         `gorm:"not null" json:"link"`
+	Tags        []Tag          `gorm:"many2many:song_tags" json:"tags,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string" format:"date-time"`
+	// Version grows by one with every write; the song's ETag is derived
+	// from it.
+	Version uint `gorm:"not null;default:1" json:"version"`
+	// Status tells whether the song has been enriched; EnrichmentError says
+	// why it failed.
+	Status          string `gorm:"not null;default:ready;index" json:"status" enums:"ready,pending,failed"`
+	EnrichmentError string `gorm:"not null;default:''" json:"enrichment_error,omitempty"`
+	// EnrichedAt is when the providers last answered for the song. A
+	// refresh leaves the fields in ProtectedFields as they are.
+	EnrichedAt      *time.Time `json:"enriched_at,omitempty"`
+	ProtectedFields FieldList  `gorm:"type:text;not null;default:''" json:"protected_fields,omitempty" swaggertype:"array,string" enums:"release_date,text,link"`
+}
+
+// Song statuses.
+const (
+	SongReady   = "ready"   // enriched, or entered in full
+	SongPending = "pending" // stored before enrichment, which an EnrichmentJob does
+	SongFailed  = "failed"  // the enrichment job gave up
+)
+
+// ErrVersionMismatch is returned by writes that expected another version of
+// the song than the stored one.
+var ErrVersionMismatch = errors.New("song has been changed by someone else")
+
+// ErrSongExists is returned by writes that would give a group two songs of
+// the same name outside the trash.
+var ErrSongExists = errors.New("group already has a song with this name")
+
+// BeforeCreate starts new songs at version 1, ready unless said otherwise.
+func (s *Song) BeforeCreate(tx *gorm.DB) error {
+	if s.Version == 0 {
+		s.Version = 1
+	}
+	if s.Status == "" {
+		s.Status = SongReady
+	}
+	return nil
+}
+
+// AfterFind exposes the name of the preloaded group as GroupName.
+func (s *Song) AfterFind(tx *gorm.DB) error {
+	if s.Group != nil {
+		s.GroupName = s.Group.Name
+	}
+	return nil
 }
 
 type SongFilter struct {
-	ID          uint
-	GroupName   string
-	SongName    string
-	ReleaseDate time.Time
-	Text        string
-	Page        int
-	Limit       int
+	ID              uint
+	GroupName       string
+	SongName        string
+	Album           string
+	ReleaseDate     time.Time
+	ReleaseDateFrom time.Time // inclusive
+	ReleaseDateTo   time.Time // inclusive
+	CreatedAfter    time.Time
+	UpdatedAfter    time.Time
+	Year            int
+	Decade          int // first year of the decade, e.g. 1990
+	Text            string
+	Tag             string
+	Status          string
+	TagsAny         []string
+	TagsAll         []string
+	Query           *SongQuery // boolean filter expression, AND-ed with the fields above
+	Page            int
+	Limit           int
+	Sort            SongSort
+	Cursor          string
+	IncludeDeleted  bool // also match songs in the trash
 }
 
 type CreateSongInput struct {
-	Group string `json:"group" binding:"required" example:"Test Group"`
-	Song  string `json:"song" binding:"required" example:"Test Song"`
+	Group       string `json:"group" binding:"required" example:"Test Group"`
+	Song        string `json:"song" binding:"required" example:"Test Song"`
+	AlbumID     uint   `json:"album_id,omitempty" example:"1"`
+	DiscNumber  int    `json:"disc_number,omitempty" example:"1"`
+	TrackNumber int    `json:"track_number,omitempty" example:"3"`
 }
 
+// UpdateSongInput is a complete song for PUT, which replaces every field.
+// Text and Link must be given but may be empty, as PATCH allows.
 type UpdateSongInput struct {
-	GroupName   string `json:"group_name" example:"Test Group"`
-	SongName    string `json:"song_name" example:"Test Song"`
-	ReleaseDate string `json:"release_date" example:"2006-06-19"`
-	Text        string `json:"text" example:"Test lyrics"`
-	Link        string `json:"link" example:"https://www.example.com"`
+	GroupName   string  `json:"group_name" binding:"required" example:"Test Group"`
+	SongName    string  `json:"song_name" binding:"required" example:"Test Song"`
+	ReleaseDate string  `json:"release_date" binding:"required" example:"2006-06-19"`
+	Text        *string `json:"text" binding:"required" example:"Test lyrics"`
+	Link        *string `json:"link" binding:"required" example:"https://www.example.com"`
 }
 
 func GetSongs(db *gorm.DB, filter SongFilter) ([]Song, error) {
 	var songs []Song
+	// An explicit sort wins over relevance; without one the text filter's
+	// ranking (if any) comes first and the ID keeps the order stable.
+	query := filterSongs(db, filter, len(filter.Sort) == 0).Preload("Group").Preload("Tags")
+	if len(filter.Sort) > 0 {
+		query = filter.Sort.apply(query)
+	} else {
+		query = query.Order("id")
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 10
+		logger.Log.Debug("No limit provided, defaulting to 10")
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	offset := (filter.Page - 1) * filter.Limit
+	logger.Log.Debugf("Pagination: Page=%d, Limit=%d, Offset=%d", filter.Page, filter.Limit, offset)
+
+	err := query.Limit(filter.Limit).Offset(offset).Find(&songs).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch songs from database")
+	} else {
+		logger.Log.Infof("Fetched %d song(s) from database", len(songs))
+	}
+
+	return songs, err
+}
+
+// filterSongs builds the query for the filter's conditions, without paging.
+// When ranked is set, a text filter served by the search index also orders
+// the songs by relevance.
+func filterSongs(db *gorm.DB, filter SongFilter, ranked bool) *gorm.DB {
 	query := db.Model(&Song{})
+	if filter.IncludeDeleted {
+		query = query.Unscoped()
+	}
 
 	logger.Log.Debug("Building query for GetSongs")
 
@@ -53,55 +159,132 @@ func GetSongs(db *gorm.DB, filter SongFilter) ([]Song, error) {
 		logger.Log.Debugf("Filter: ID = %d", filter.ID)
 	}
 	if filter.GroupName != "" {
-		query = query.Where("group_name ILIKE ?", "%"+filter.GroupName+"%")
-		logger.Log.Debugf("Filter: GroupName ILIKE '%%%s%%'", filter.GroupName)
+		groupIDs := db.Model(&Group{}).Select("id").
+			Where("normalized_name LIKE ?", "%"+NormalizeGroupName(filter.GroupName)+"%")
+		query = query.Where("group_id IN (?)", groupIDs)
+		logger.Log.Debugf("Filter: Group name LIKE '%%%s%%'", NormalizeGroupName(filter.GroupName))
 	}
 	if filter.SongName != "" {
 		query = query.Where("song_name ILIKE ?", "%"+filter.SongName+"%")
 		logger.Log.Debugf("Filter: SongName ILIKE '%%%s%%'", filter.SongName)
 	}
+	if filter.Album != "" {
+		albumIDs := db.Model(&Album{}).Select("id").
+			Where("LOWER(title) LIKE ?", "%"+strings.ToLower(filter.Album)+"%")
+		songIDs := db.Model(&AlbumTrack{}).Select("song_id").Where("album_id IN (?)", albumIDs)
+		query = query.Where("id IN (?)", songIDs)
+		logger.Log.Debugf("Filter: Album title LIKE '%%%s%%'", filter.Album)
+	}
 	if !filter.ReleaseDate.IsZero() {
 		query = query.Where("release_date = ?", filter.ReleaseDate)
 		logger.Log.Debugf("Filter: ReleaseDate = %s", filter.ReleaseDate.Format("2006-01-02"))
 	}
-	if filter.Text != "" {
-		query = query.Where("text ILIKE ?", "%"+filter.Text+"%")
-		logger.Log.Debugf("Filter: Text ILIKE '%%%s%%'", filter.Text)
+	if !filter.ReleaseDateFrom.IsZero() {
+		query = query.Where("release_date >= ?", filter.ReleaseDateFrom)
+		logger.Log.Debugf("Filter: ReleaseDate >= %s", filter.ReleaseDateFrom.Format("2006-01-02"))
+	}
+	if !filter.ReleaseDateTo.IsZero() {
+		query = query.Where("release_date <= ?", filter.ReleaseDateTo)
+		logger.Log.Debugf("Filter: ReleaseDate <= %s", filter.ReleaseDateTo.Format("2006-01-02"))
+	}
+	if filter.Year != 0 {
+		query = whereReleasedBetween(query, filter.Year, filter.Year+1)
+		logger.Log.Debugf("Filter: released in %d", filter.Year)
+	}
+	if filter.Decade != 0 {
+		query = whereReleasedBetween(query, filter.Decade, filter.Decade+10)
+		logger.Log.Debugf("Filter: released in the %ds", filter.Decade)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at > ?", filter.CreatedAfter)
+		logger.Log.Debugf("Filter: CreatedAt > %s", filter.CreatedAfter.Format(time.RFC3339))
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		query = query.Where("updated_at > ?", filter.UpdatedAfter)
+		logger.Log.Debugf("Filter: UpdatedAt > %s", filter.UpdatedAfter.Format(time.RFC3339))
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+		logger.Log.Debugf("Filter: Status = %s", filter.Status)
 	}
 
-	if filter.Limit == 0 {
-		filter.Limit = 10
-		logger.Log.Debug("No limit provided, defaulting to 10")
+	if slug := NormalizeTagName(filter.Tag); slug != "" {
+		query = query.Where("id IN (?)", songIDsWithTags(db, []string{slug}, false))
+		logger.Log.Debugf("Filter: Tag = %s", slug)
 	}
-	if filter.Page <= 0 {
-		filter.Page = 1
+	if slugs := NormalizeTagNames(filter.TagsAny); len(slugs) > 0 {
+		query = query.Where("id IN (?)", songIDsWithTags(db, slugs, false))
+		logger.Log.Debugf("Filter: any of tags %v", slugs)
+	}
+	if slugs := NormalizeTagNames(filter.TagsAll); len(slugs) > 0 {
+		query = query.Where("id IN (?)", songIDsWithTags(db, slugs, true))
+		logger.Log.Debugf("Filter: all of tags %v", slugs)
+	}
+	if filter.Query != nil {
+		query = filter.Query.apply(db, query)
+		logger.Log.Debug("Filter: boolean filter expression")
 	}
-	offset := (filter.Page - 1) * filter.Limit
-	logger.Log.Debugf("Pagination: Page=%d, Limit=%d, Offset=%d", filter.Page, filter.Limit, offset)
 
-	err := query.Limit(filter.Limit).Offset(offset).Find(&songs).Error
+	// Last, so the index matches can be intersected with the conditions
+	// above. The index holds no trashed songs, so the database searches when
+	// they are included.
+	if filter.Text != "" && songIndex != nil && !filter.IncludeDeleted {
+		query = filterByIndex(query, filter.Text, ranked)
+	} else if filter.Text != "" {
+		query = query.Where("text ILIKE ?", "%"+filter.Text+"%")
+		logger.Log.Debugf("Filter: Text ILIKE '%%%s%%'", filter.Text)
+	}
+
+	return query
+}
+
+// whereReleasedBetween matches release dates from January 1st of the first
+// year up to, but not including, January 1st of the last one. Comparing with
+// a range rather than extracting the year works on every dialect and can use
+// an index on release_date.
+func whereReleasedBetween(query *gorm.DB, fromYear, toYear int) *gorm.DB {
+	from := time.Date(fromYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(toYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return query.Where("release_date >= ? AND release_date < ?", from, to)
+}
+
+// GetSong returns a song with its group and tags.
+func GetSong(db *gorm.DB, id uint) (Song, error) {
+	var song Song
+	err := db.Preload("Group").Preload("Tags").First(&song, id).Error
 	if err != nil {
-		logger.Log.WithError(err).Error("Failed to fetch songs from database")
-	} else {
-		logger.Log.Infof("Fetched %d song(s) from database", len(songs))
+		logger.Log.WithError(err).Debugf("Song ID=%d not found", id)
 	}
+	return song, err
+}
 
-	return songs, err
+// FindSongByName returns the song of a group with the given name, matching
+// the group like FindOrCreateGroup does and the name case-insensitively.
+func FindSongByName(db *gorm.DB, groupName, songName string) (Song, error) {
+	var song Song
+	err := db.Preload("Group").Preload("Tags").
+		Joins("JOIN groups ON groups.id = songs.group_id").
+		Where("groups.normalized_name = ? AND LOWER(songs.song_name) = LOWER(?)",
+			NormalizeGroupName(groupName), strings.TrimSpace(songName)).
+		Order("songs.id").First(&song).Error
+	return song, err
 }
 
-func GetSongVerses(db *gorm.DB, id uint, page, limit int) ([]string, error) {
+func GetSongVerses(db *gorm.DB, id uint, page, limit int, includeDeleted bool) (VerseList, error) {
 	logger.Log.Debugf("Fetching song with ID: %d for verses", id)
 
+	if includeDeleted {
+		db = db.Unscoped()
+	}
 	var song Song
 	err := db.First(&song, id).Error
 	if err != nil {
 		logger.Log.WithError(err).Errorf("Failed to fetch song with ID %d", id)
-		return nil, err
+		return VerseList{}, err
 	}
 
 	normalized := strings.ReplaceAll(song.Text, "\r\n", "\n")
 	verses := strings.Split(normalized, "\n\n")
-	fmt.Println(verses)
 	totalVerses := len(verses)
 	logger.Log.Debugf("Song ID %d has %d verses", id, totalVerses)
 
@@ -111,71 +294,219 @@ func GetSongVerses(db *gorm.DB, id uint, page, limit int) ([]string, error) {
 	if limit <= 0 {
 		limit = 3
 	}
+	list := VerseList{Items: []string{}, PageInfo: newPageInfo(int64(totalVerses), page, limit)}
 
 	start := (page - 1) * limit
 	end := start + limit
 
 	if start > totalVerses {
 		logger.Log.Infof("Pagination out of range: start=%d > total=%d", start, totalVerses)
-		return []string{}, nil
+		return list, nil
 	}
 	if end > totalVerses {
 		end = totalVerses
 	}
 
-	selectedVerses := verses[start:end]
+	list.Items = verses[start:end]
 	logger.Log.Infof("Returning verses %d to %d for song ID %d", start+1, end, id)
-	return selectedVerses, nil
+	return list, nil
 }
 
 func CreateSong(db *gorm.DB, song *Song) error {
 	logger.Log.Infof("Creating song: Group=%s, Song=%s", song.GroupName, song.SongName)
 
-	err := db.Create(&song).Error
+	group, err := FindOrCreateGroup(db, song.GroupName)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to resolve group for song")
+		return err
+	}
+	song.GroupID = group.ID
+	song.Group = &group
+	song.GroupName = group.Name
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Group").Create(song).Error; err != nil {
+			return songWriteError(tx, err)
+		}
+		return recordRevision(tx, nil, song, RevisionCreate, 0)
+	})
 	if err != nil {
 		logger.Log.WithError(err).Error("Failed to create song in database")
 	} else {
 		logger.Log.Infof("Song created successfully: ID=%d", song.ID)
+		indexSong(db, *song)
+		afterCommit(db, InvalidateAutocomplete)
 	}
 
 	return err
 }
 
-func UpdateSong(db *gorm.DB, updatedSong Song) error {
+// UpdateSong overwrites a song's fields with those of updatedSong and records
+// the new state as a revision. When updatedSong.Version is set, the stored
+// song must still be at that version, or ErrVersionMismatch is returned and
+// nothing changes. A song that is pending or whose enrichment failed is ready
+// once it has been written in full.
+func UpdateSong(db *gorm.DB, updatedSong *Song) error {
+	return updateSong(db, updatedSong, RevisionUpdate, 0, false)
+}
+
+// EditSong is UpdateSong for an edit made by hand: the fields in
+// ProtectManualEdits that it changes are also protected from refreshes.
+func EditSong(db *gorm.DB, updatedSong *Song) error {
+	return updateSong(db, updatedSong, RevisionUpdate, 0, true)
+}
+
+func updateSong(db *gorm.DB, updatedSong *Song, action string, revertedFrom int, protect bool) error {
 	logger.Log.Debugf("Attempting to update song with ID=%d", updatedSong.ID)
 
 	var existing Song
-	err := db.First(&existing, updatedSong.ID).Error
-	if err != nil {
-		logger.Log.WithError(err).Errorf("Song with ID=%d not found for update", updatedSong.ID)
-		return err
-	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Preload("Group").Preload("Tags").First(&existing, updatedSong.ID).Error
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Song with ID=%d not found for update", updatedSong.ID)
+			return err
+		}
+		if updatedSong.Version != 0 && updatedSong.Version != existing.Version {
+			return ErrVersionMismatch
+		}
+		before := existing
+		existing.Group = nil
+
+		group, err := FindOrCreateGroup(tx, updatedSong.GroupName)
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to resolve group for song ID=%d", updatedSong.ID)
+			return err
+		}
 
-	existing.GroupName = updatedSong.GroupName
-	existing.SongName = updatedSong.SongName
-	existing.ReleaseDate = updatedSong.ReleaseDate
-	existing.Text = updatedSong.Text
-	existing.Link = updatedSong.Link
+		existing.GroupID = group.ID
+		existing.GroupName = group.Name
+		existing.SongName = updatedSong.SongName
+		existing.ReleaseDate = updatedSong.ReleaseDate
+		existing.Text = updatedSong.Text
+		existing.Link = updatedSong.Link
 
-	err = db.Save(&existing).Error
+		if existing.Status != SongReady && !existing.ReleaseDate.IsZero() {
+			existing.Status, existing.EnrichmentError = SongReady, ""
+		}
+		if protect {
+			for _, field := range ProtectManualEdits {
+				if !sameField(&before, &existing, field) {
+					existing.ProtectedFields = existing.ProtectedFields.With(field)
+				}
+			}
+		}
+
+		if sameContent(&before, &existing) && existing.Status == before.Status {
+			return nil
+		}
+		if err := bumpVersion(tx, &existing, map[string]interface{}{
+			"group_id":         existing.GroupID,
+			"song_name":        existing.SongName,
+			"release_date":     existing.ReleaseDate,
+			"text":             existing.Text,
+			"link":             existing.Link,
+			"status":           existing.Status,
+			"enrichment_error": existing.EnrichmentError,
+			"protected_fields": existing.ProtectedFields,
+		}); err != nil {
+			return songWriteError(tx, err)
+		}
+		return recordRevision(tx, &before, &existing, action, revertedFrom)
+	})
 	if err != nil {
 		logger.Log.WithError(err).Errorf("Failed to update song ID=%d", updatedSong.ID)
 	} else {
 		logger.Log.Infof("Song updated successfully: ID=%d", updatedSong.ID)
+		*updatedSong = existing
+		indexSong(db, existing)
+		afterCommit(db, InvalidateAutocomplete)
 	}
 
 	return err
 }
 
-func DeleteSong(db *gorm.DB, id uint) error {
+// DeleteSong moves a song to the trash. Its tags and album tracks are kept
+// for a restore; playlist entries are removed, since a playlist cannot hold a
+// gap. PurgeTrash deletes trashed songs for good. A version other than zero
+// must match the stored song's, as for UpdateSong.
+func DeleteSong(db *gorm.DB, id, version uint) error {
 	logger.Log.Debugf("Attempting to delete song with ID=%d", id)
 
-	err := db.Delete(&Song{}, id).Error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var song Song
+		if err := tx.Preload("Group").First(&song, id).Error; err != nil {
+			return err
+		}
+		if version != 0 && version != song.Version {
+			return ErrVersionMismatch
+		}
+		if err := removeSongFromPlaylists(tx, id); err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, &song, map[string]interface{}{"deleted_at": time.Now()}); err != nil {
+			return err
+		}
+		return recordRevision(tx, &song, &song, RevisionDelete, 0)
+	})
 	if err != nil {
 		logger.Log.WithError(err).Errorf("Failed to delete song ID=%d", id)
 	} else {
-		logger.Log.Infof("Song deleted successfully: ID=%d", id)
+		logger.Log.Infof("Song moved to trash: ID=%d", id)
+		unindexSong(db, id)
+		afterCommit(db, InvalidateAutocomplete)
+	}
+
+	return err
+}
+
+// bumpVersion writes the changes to a song read earlier in the transaction
+// and moves it to the next version, provided nobody wrote it in between.
+func bumpVersion(tx *gorm.DB, song *Song, changes map[string]interface{}) error {
+	now := time.Now()
+	changes["version"] = song.Version + 1
+	changes["updated_at"] = now
+
+	result := tx.Unscoped().Model(&Song{}).
+		Where("id = ? AND version = ?", song.ID, song.Version).
+		Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionMismatch
 	}
+	song.Version++
+	song.UpdatedAt = now
+	return nil
+}
 
+// songWriteError reports a write that broke unique_active_song as
+// ErrSongExists.
+func songWriteError(db *gorm.DB, err error) error {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok &&
+		errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return ErrSongExists
+	}
 	return err
 }
+
+// sameContent reports whether two states of a song have the same fields as
+// far as revisions are concerned.
+func sameContent(a, b *Song) bool {
+	return a.GroupName == b.GroupName && a.SongName == b.SongName &&
+		a.ReleaseDate.Equal(b.ReleaseDate) && a.Text == b.Text && a.Link == b.Link
+}
+
+// ParseDate reads a release date as 2006-01-02, 2006.01.02 or RFC 3339.
+func ParseDate(dateStr string) (time.Time, error) {
+	formats := []string{"2006.01.02", "2006-01-02", time.RFC3339}
+	var err error
+	for _, layout := range formats {
+		var t time.Time
+		t, err = time.Parse(layout, dateStr)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date format: %s", dateStr)
+}
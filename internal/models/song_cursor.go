@@ -0,0 +1,101 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SongPage is one page of songs in cursor mode. NextCursor is empty on the
+// last page.
+type SongPage struct {
+	Items      []Song `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// songCursor is the position after the last song of a page: the sort spec
+// and that song's values for it. It is handed to clients base64-encoded and
+// should be treated by them as opaque.
+type songCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func encodeSongCursor(sort SongSort, last *Song) string {
+	data, _ := json.Marshal(songCursor{Sort: sort.String(), Values: sort.values(last)})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSongCursor(token string) (SongSort, []interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	var cursor songCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	sort, err := ParseSongSort(cursor.Sort)
+	if err != nil || len(cursor.Values) != len(sort) {
+		return nil, nil, ErrInvalidCursor
+	}
+	values := make([]interface{}, len(sort))
+	for i, raw := range cursor.Values {
+		value, ok := sort.decodeValue(i, raw)
+		if !ok {
+			return nil, nil, ErrInvalidCursor
+		}
+		values[i] = value
+	}
+	return sort, values, nil
+}
+
+// GetSongsPage returns the songs matching the filter that come after
+// filter.Cursor in filter.Sort order. An empty cursor starts from the
+// beginning; later pages take their sort from the cursor itself.
+func GetSongsPage(db *gorm.DB, filter SongFilter) (SongPage, error) {
+	sort := filter.Sort
+	if len(sort) == 0 {
+		sort, _ = ParseSongSort("")
+	}
+
+	query := filterSongs(db, filter, false).Preload("Group").Preload("Tags")
+	if filter.Cursor != "" {
+		cursorSort, values, err := decodeSongCursor(filter.Cursor)
+		if err != nil {
+			logger.Log.WithError(err).Debugf("Cannot decode cursor '%s'", filter.Cursor)
+			return SongPage{}, err
+		}
+		sort = cursorSort
+		query = sort.after(query, values)
+	}
+	query = sort.apply(query)
+
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	logger.Log.Debugf("Cursor pagination: Sort=%s, Limit=%d, Cursor=%s", sort, filter.Limit, filter.Cursor)
+
+	// One extra row tells whether another page follows.
+	var songs []Song
+	if err := query.Limit(filter.Limit + 1).Find(&songs).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch songs from database")
+		return SongPage{}, err
+	}
+
+	page := SongPage{Items: songs}
+	if len(songs) > filter.Limit {
+		page.Items = songs[:filter.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeSongCursor(sort, &page.Items[filter.Limit-1])
+	}
+
+	logger.Log.Infof("Fetched %d song(s) from database, has more: %t", len(page.Items), page.HasMore)
+	return page, nil
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// songSortField is a sortable song field: the SQL expression to order by and
+// how to read the field's value off a loaded song, which keyset pagination
+// needs to resume after it.
+type songSortField struct {
+	expr  string
+	value func(s *Song) interface{}
+}
+
+// songSortFields is the whitelist of fields a client may sort songs by. Only
+// these expressions ever reach the ORDER BY clause.
+var songSortFields = map[string]songSortField{
+	"id":           {"id", func(s *Song) interface{} { return s.ID }},
+	"group_id":     {"group_id", func(s *Song) interface{} { return s.GroupID }},
+	"group_name":   {"(SELECT name FROM groups WHERE groups.id = songs.group_id)", func(s *Song) interface{} { return s.GroupName }},
+	"song_name":    {"song_name", func(s *Song) interface{} { return s.SongName }},
+	"release_date": {"release_date", func(s *Song) interface{} { return s.ReleaseDate }},
+	"text":         {"text", func(s *Song) interface{} { return s.Text }},
+	"link":         {"link", func(s *Song) interface{} { return s.Link }},
+	"created_at":   {"created_at", func(s *Song) interface{} { return s.CreatedAt }},
+	"updated_at":   {"updated_at", func(s *Song) interface{} { return s.UpdatedAt }},
+}
+
+// SortField is one field of a sort spec.
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+// SongSort is a parsed sort spec such as "release_date,-song_name". It always
+// ends with the song ID, so the order is total and pages are stable.
+type SongSort []SortField
+
+// ParseSongSort parses a comma-separated list of song fields, each optionally
+// prefixed with "-" for descending order ("+" or no prefix is ascending). An
+// empty spec sorts by ID.
+func ParseSongSort(spec string) (SongSort, error) {
+	var sort SongSort
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Name: part}
+		switch part[0] {
+		case '-':
+			field = SortField{Name: part[1:], Desc: true}
+		case '+':
+			field = SortField{Name: part[1:]}
+		}
+
+		if _, ok := songSortFields[field.Name]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, field.Name)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("%w: field %q given twice", ErrInvalidSort, field.Name)
+		}
+		seen[field.Name] = true
+		sort = append(sort, field)
+	}
+
+	if !seen["id"] {
+		sort = append(sort, SortField{Name: "id"})
+	}
+	return sort, nil
+}
+
+// String formats the spec back into the form ParseSongSort accepts.
+func (s SongSort) String() string {
+	parts := make([]string, len(s))
+	for i, field := range s {
+		parts[i] = field.Name
+		if field.Desc {
+			parts[i] = "-" + field.Name
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// apply adds the spec's ORDER BY to the query.
+func (s SongSort) apply(query *gorm.DB) *gorm.DB {
+	for _, field := range s {
+		order := songSortFields[field.Name].expr
+		if field.Desc {
+			order += " DESC"
+		}
+		query = query.Order(order)
+	}
+	return query
+}
+
+// values reads the spec's fields off a song, in order.
+func (s SongSort) values(song *Song) []interface{} {
+	values := make([]interface{}, len(s))
+	for i, field := range s {
+		values[i] = songSortFields[field.Name].value(song)
+	}
+	return values
+}
+
+// after restricts the query to songs that sort after the given field values:
+// (a > x) OR (a = x AND b > y) OR ..., with "<" for descending fields.
+func (s SongSort) after(query *gorm.DB, values []interface{}) *gorm.DB {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	for i, field := range s {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, songSortFields[s[j].Name].expr+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if field.Desc {
+			op = " < ?"
+		}
+		parts = append(parts, songSortFields[field.Name].expr+op)
+		args = append(args, values[i])
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return query.Where(strings.Join(conditions, " OR "), args...)
+}
+
+// decodeValue converts a sort value decoded from JSON back into the type the
+// field has on Song.
+func (s SongSort) decodeValue(i int, raw interface{}) (interface{}, bool) {
+	switch songSortFields[s[i].Name].value(&Song{}).(type) {
+	case uint:
+		n, ok := raw.(float64)
+		if !ok || n < 0 {
+			return nil, false
+		}
+		return uint(n), true
+	case time.Time:
+		str, ok := raw.(string)
+		if !ok {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, str)
+		return t, err == nil
+	case string:
+		str, ok := raw.(string)
+		return str, ok
+	}
+	return nil, false
+}
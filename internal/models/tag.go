@@ -0,0 +1,215 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	TagKindGenre  = "genre"
+	TagKindMood   = "mood"
+	TagKindCustom = "custom"
+)
+
+var (
+	ErrInvalidTagName = errors.New("tag name must contain a letter or digit")
+	ErrInvalidTagKind = errors.New("tag kind must be genre, mood or custom")
+)
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"not null;uniqueIndex:unique_tag" json:"slug"`
+	Kind      string    `gorm:"not null;default:custom" json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagsInput struct {
+	Tags []string `json:"tags" binding:"required,min=1" example:"Alternative Rock,space"`
+	Kind string   `json:"kind" example:"genre"`
+}
+
+// NormalizeTagName turns a tag name into its slug: lower case, with every run
+// of spaces and punctuation replaced by a single hyphen. "Hip Hop", "hip-hop"
+// and "HIP_HOP " share the slug "hip-hop" and are therefore one tag.
+func NormalizeTagName(name string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+		} else {
+			pendingHyphen = true
+		}
+	}
+	return b.String()
+}
+
+// NormalizeTagNames normalizes a list of tag names, dropping empty and
+// repeated slugs.
+func NormalizeTagNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	slugs := make([]string, 0, len(names))
+	for _, name := range names {
+		slug := NormalizeTagName(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+	}
+	return slugs
+}
+
+func validTagKind(kind string) bool {
+	return kind == TagKindGenre || kind == TagKindMood || kind == TagKindCustom
+}
+
+func GetTags(db *gorm.DB, kind string) ([]Tag, error) {
+	var tags []Tag
+	query := db.Model(&Tag{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	err := query.Order("slug").Find(&tags).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch tags from database")
+	} else {
+		logger.Log.Infof("Fetched %d tag(s) from database", len(tags))
+	}
+
+	return tags, err
+}
+
+// FindOrCreateTag returns the tag with the same slug as name, creating it with
+// the given kind when it does not exist. An existing tag keeps its kind.
+func FindOrCreateTag(db *gorm.DB, name, kind string) (Tag, error) {
+	slug := NormalizeTagName(name)
+	if slug == "" {
+		return Tag{}, ErrInvalidTagName
+	}
+	if kind == "" {
+		kind = TagKindCustom
+	}
+	if !validTagKind(kind) {
+		return Tag{}, ErrInvalidTagKind
+	}
+
+	var tag Tag
+	err := db.Where("slug = ?", slug).First(&tag).Error
+	if err == nil {
+		return tag, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Tag{}, err
+	}
+
+	tag = Tag{Name: strings.Join(strings.Fields(name), " "), Slug: slug, Kind: kind}
+	if err = db.Create(&tag).Error; err != nil {
+		// Another request may have created the same tag concurrently.
+		if findErr := db.Where("slug = ?", slug).First(&tag).Error; findErr == nil {
+			return tag, nil
+		}
+		return Tag{}, err
+	}
+
+	logger.Log.Infof("Tag created: ID=%d, Slug=%s, Kind=%s", tag.ID, tag.Slug, tag.Kind)
+	return tag, nil
+}
+
+// AttachTags tags a song, creating missing tags. It returns all tags of the
+// song afterwards. The tags are part of the song as GetSong returns it, so a
+// song that gets new ones moves to its next version.
+func AttachTags(db *gorm.DB, songID uint, names []string, kind string) ([]Tag, error) {
+	logger.Log.Debugf("Attaching tags %v to song ID=%d", names, songID)
+
+	var song Song
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&song, songID).Error; err != nil {
+			return err
+		}
+
+		tags := make([]Tag, 0, len(names))
+		for _, name := range names {
+			tag, err := FindOrCreateTag(tx, name, kind)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+
+		before := tx.Model(&song).Association("Tags").Count()
+		if err := tx.Model(&song).Omit("Tags.*").Association("Tags").Append(tags); err != nil {
+			return err
+		}
+		if err := tx.Model(&song).Order("slug").Association("Tags").Find(&song.Tags); err != nil {
+			return err
+		}
+		if int64(len(song.Tags)) == before {
+			return nil
+		}
+		return bumpVersion(tx, &song, map[string]interface{}{})
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to attach tags to song ID=%d", songID)
+		return nil, err
+	}
+
+	logger.Log.Infof("Song ID=%d now has %d tag(s)", songID, len(song.Tags))
+	return song.Tags, nil
+}
+
+// DetachTag removes a tag, given by any spelling of its name, from a song,
+// which moves to its next version if it had the tag.
+func DetachTag(db *gorm.DB, songID uint, name string) error {
+	logger.Log.Debugf("Detaching tag %q from song ID=%d", name, songID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var song Song
+		if err := tx.First(&song, songID).Error; err != nil {
+			return err
+		}
+		var tag Tag
+		if err := tx.Where("slug = ?", NormalizeTagName(name)).First(&tag).Error; err != nil {
+			return err
+		}
+		before := tx.Model(&song).Association("Tags").Count()
+		if err := tx.Model(&song).Association("Tags").Delete(&tag); err != nil {
+			return err
+		}
+		if tx.Model(&song).Association("Tags").Count() == before {
+			return nil
+		}
+		return bumpVersion(tx, &song, map[string]interface{}{})
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to detach tag %q from song ID=%d", name, songID)
+	} else {
+		logger.Log.Infof("Tag %q detached from song ID=%d", name, songID)
+	}
+
+	return err
+}
+
+// songIDsWithTags is a subquery of the IDs of songs tagged with at least one
+// of the slugs, or with all of them when matchAll is set.
+func songIDsWithTags(db *gorm.DB, slugs []string, matchAll bool) *gorm.DB {
+	query := db.Table("song_tags").
+		Select("song_tags.song_id").
+		Joins("JOIN tags ON tags.id = song_tags.tag_id").
+		Where("tags.slug IN ?", slugs)
+	if matchAll {
+		query = query.Group("song_tags.song_id").Having("COUNT(DISTINCT tags.slug) = ?", len(slugs))
+	}
+	return query
+}
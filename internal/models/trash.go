@@ -0,0 +1,132 @@
+package models
+
+import (
+	"SongLibrary/pkg/logger"
+	"database/sql"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// TrashRetention is how long a song stays in the trash before PurgeTrash
+// removes it when no other age is given.
+var TrashRetention = 30 * 24 * time.Hour
+
+// ListTrash returns a page of trashed songs, most recently deleted first,
+// with the total number of songs in the trash.
+func ListTrash(db *gorm.DB, page, limit int) (SongList, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	var list SongList
+	err := db.Transaction(func(tx *gorm.DB) error {
+		trash := func() *gorm.DB {
+			return tx.Unscoped().Model(&Song{}).Where("deleted_at IS NOT NULL")
+		}
+
+		var total int64
+		if err := trash().Count(&total).Error; err != nil {
+			return err
+		}
+
+		var songs []Song
+		err := trash().Preload("Group").Preload("Tags").
+			Order("deleted_at DESC").Order("id").
+			Limit(limit).Offset((page - 1) * limit).
+			Find(&songs).Error
+		if err != nil {
+			return err
+		}
+
+		list = SongList{Items: songs, PageInfo: newPageInfo(total, page, limit)}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to list trashed songs")
+		return SongList{}, err
+	}
+
+	logger.Log.Infof("Fetched %d trashed song(s) of %d", len(list.Items), list.Total)
+	return list, nil
+}
+
+// RestoreSong takes a song out of the trash, with the tags and album tracks
+// it had. Songs that are not in the trash are reported as not found, and
+// ErrSongExists is returned when its group has got a song of the same name
+// meanwhile.
+func RestoreSong(db *gorm.DB, id uint) (Song, error) {
+	logger.Log.Debugf("Attempting to restore song with ID=%d", id)
+
+	var song Song
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&song, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Log.Infof("Song ID=%d is not in the trash", id)
+		}
+		if err != nil {
+			return err
+		}
+		if err = bumpVersion(tx, &song, map[string]interface{}{"deleted_at": nil}); err != nil {
+			return songWriteError(tx, err)
+		}
+
+		if err = tx.Preload("Group").Preload("Tags").First(&song, id).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, &song, &song, RevisionRestore, 0)
+	})
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to restore song ID=%d", id)
+		return Song{}, err
+	}
+
+	logger.Log.Infof("Song restored from trash: ID=%d", id)
+	indexSong(db, song)
+	afterCommit(db, InvalidateAutocomplete)
+	return song, nil
+}
+
+// PurgeTrash permanently deletes the songs that were moved to the trash
+// before the given time, along with their tags, album tracks, revisions and
+// enrichment jobs, and returns how many were deleted.
+func PurgeTrash(db *gorm.DB, before time.Time) (int64, error) {
+	logger.Log.Debugf("Purging songs trashed before %s", before.Format(time.RFC3339))
+
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Unscoped().Model(&Song{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		if err := tx.Where("song_id IN ?", ids).Delete(&AlbumTrack{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM song_tags WHERE song_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("song_id IN ?", ids).Delete(&SongRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("song_id IN ?", ids).Delete(&EnrichmentJob{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&Song{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to purge trash")
+		return 0, err
+	}
+
+	logger.Log.Infof("Purged %d song(s) from trash", purged)
+	return purged, nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type afterCommitKey struct{}
+
+// Transaction runs fn in a transaction, like db.Transaction, and once it has
+// committed updates the search index and autocomplete for the song writes
+// made in it. Song writes nested in a plain db.Transaction cannot tell when
+// the outer transaction commits, so they would update them right away, and
+// a rollback would leave songs in the index that do not exist; callers that
+// group song writes in a transaction of their own use this instead.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.Statement.Context.Value(afterCommitKey{}).(*[]func()); ok {
+		// Nested: the outermost Transaction runs the pending updates.
+		return db.Transaction(fn)
+	}
+
+	var pending []func()
+	ctx := context.WithValue(db.Statement.Context, afterCommitKey{}, &pending)
+	if err := db.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+	for _, update := range pending {
+		update()
+	}
+	return nil
+}
+
+// afterCommit runs update once the Transaction db belongs to has committed,
+// or right away outside of one. Song writes call it after their own
+// transaction, with the session they were given.
+func afterCommit(db *gorm.DB, update func()) {
+	if pending, ok := db.Statement.Context.Value(afterCommitKey{}).(*[]func()); ok {
+		*pending = append(*pending, update)
+		return
+	}
+	update()
+}
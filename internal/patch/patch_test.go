@@ -0,0 +1,71 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestMerge(t *testing.T) {
+	// Examples from RFC 7396, appendix A.
+	cases := []struct{ target, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, decode(t, tc.result), Merge(decode(t, tc.target), decode(t, tc.patch)), tc.patch)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ops := func(s string) []Operation {
+		var o []Operation
+		require.NoError(t, json.Unmarshal([]byte(s), &o))
+		return o
+	}
+
+	doc, err := Apply(decode(t, `{"foo":["bar","baz"],"n":1}`), ops(`[
+		{"op":"test","path":"/n","value":1},
+		{"op":"add","path":"/foo/1","value":"qux"},
+		{"op":"add","path":"/foo/-","value":"end"},
+		{"op":"remove","path":"/foo/0"},
+		{"op":"replace","path":"/n","value":null},
+		{"op":"copy","from":"/foo","path":"/copy"},
+		{"op":"move","from":"/copy/0","path":"/a~1b"}
+	]`))
+	require.NoError(t, err)
+	assert.Equal(t, decode(t, `{"foo":["qux","baz","end"],"n":null,"copy":["baz","end"],"a/b":"qux"}`), doc)
+
+	_, err = Apply(decode(t, `{"n":1}`), ops(`[{"op":"test","path":"/n","value":2}]`))
+	assert.ErrorIs(t, err, ErrTestFailed)
+
+	_, err = Apply(decode(t, `{"n":1}`), ops(`[{"op":"replace","path":"/missing","value":2}]`))
+	var patchErr *Error
+	require.ErrorAs(t, err, &patchErr)
+	assert.Equal(t, 0, patchErr.Index)
+
+	for _, bad := range []string{
+		`[{"op":"add","path":"/n"}]`,
+		`[{"op":"add","path":"n","value":1}]`,
+		`[{"op":"remove","path":"/foo/5"}]`,
+		`[{"op":"move","from":"/foo","path":"/foo/0"}]`,
+		`[{"op":"frobnicate","path":"/n"}]`,
+	} {
+		_, err = Apply(decode(t, `{"n":1,"foo":[1]}`), ops(bad))
+		assert.Error(t, err, bad)
+	}
+}
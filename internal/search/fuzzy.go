@@ -0,0 +1,92 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Transliteration maps lower-case Cyrillic letters to Latin, following the
+// common passport-style romanization of Russian.
+var Transliteration = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu",
+	'я': "ia",
+}
+
+// Transliterate lower-cases s and spells its Cyrillic letters in Latin, so
+// that "Кино" and "Kino" compare equal.
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := Transliteration[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Distance is the Levenshtein distance between a and b, counting a swap of
+// two adjacent letters ("Radiohaed") as one edit rather than two.
+func Distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// Three rows of the dynamic programming table are enough: the
+	// transposition looks two rows back.
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(t)]
+}
+
+// Similarity scores how alike a name is to a query, from 0 to 1. Both are
+// transliterated and compared whole and word by word, so a query close to one
+// word of a longer name still scores high.
+func Similarity(query, name string) float64 {
+	query = strings.Join(fuzzyWords(query), " ")
+	words := fuzzyWords(name)
+	if query == "" || len(words) == 0 {
+		return 0
+	}
+
+	best := similarity(query, strings.Join(words, " "))
+	if len(words) > 1 {
+		for _, word := range words {
+			best = max(best, similarity(query, word))
+		}
+	}
+	return best
+}
+
+func similarity(a, b string) float64 {
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(Distance(a, b))/float64(longest)
+}
+
+func fuzzyWords(s string) []string {
+	return strings.FieldsFunc(Transliterate(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,26 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance("muse", "muse"))
+	assert.Equal(t, 3, Distance("kitten", "sitting"))
+	assert.Equal(t, 1, Distance("radiohaed", "radiohead"))
+	assert.Equal(t, 4, Distance("", "blur"))
+	assert.Equal(t, 1, Distance("кино", "кину"))
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, "kino", Transliterate("Кино"))
+	assert.Equal(t, "zhuki", Transliterate("Жуки"))
+
+	assert.Equal(t, 1.0, Similarity("Kino", "Кино"))
+	assert.InDelta(t, 0.89, Similarity("Radiohaed", "Radiohead"), 0.01)
+	assert.Equal(t, 1.0, Similarity("hysteria", "Hysteria (Live)"))
+	assert.Less(t, Similarity("Muse", "Radiohead"), 0.3)
+	assert.Zero(t, Similarity("", "Muse"))
+}
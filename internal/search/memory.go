@@ -0,0 +1,179 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 parameters: k1 controls term frequency saturation, b the length
+// normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Hit is a matching document and its BM25 score.
+type Hit struct {
+	ID    uint
+	Score float64
+}
+
+// MemoryIndex is an in-process inverted index over song titles and lyrics,
+// scored with BM25. It is safe for concurrent use.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[uint]int // term -> document -> term frequency
+	docTerms map[uint][]string       // document -> its distinct terms, for removal
+	docLen   map[uint]int
+	totalLen int
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		postings: make(map[string]map[uint]int),
+		docTerms: make(map[uint][]string),
+		docLen:   make(map[uint]int),
+	}
+}
+
+// Put indexes a document, replacing any earlier version with the same ID.
+func (idx *MemoryIndex) Put(id uint, title, text string) {
+	terms := Tokenize(title + " " + text)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+
+	freq := make(map[string]int, len(terms))
+	for _, term := range terms {
+		freq[term]++
+	}
+	distinct := make([]string, 0, len(freq))
+	for term, n := range freq {
+		docs := idx.postings[term]
+		if docs == nil {
+			docs = make(map[uint]int)
+			idx.postings[term] = docs
+		}
+		docs[id] = n
+		distinct = append(distinct, term)
+	}
+
+	idx.docTerms[id] = distinct
+	idx.docLen[id] = len(terms)
+	idx.totalLen += len(terms)
+}
+
+// Delete drops a document from the index.
+func (idx *MemoryIndex) Delete(id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+// IDs returns the IDs of the indexed documents, in no particular order.
+func (idx *MemoryIndex) IDs() []uint {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	ids := make([]uint, 0, len(idx.docTerms))
+	for id := range idx.docTerms {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (idx *MemoryIndex) remove(id uint) {
+	terms, ok := idx.docTerms[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLen -= idx.docLen[id]
+	delete(idx.docTerms, id)
+	delete(idx.docLen, id)
+}
+
+// Search returns the IDs of documents that contain every term of the query,
+// best match first.
+func (idx *MemoryIndex) Search(query string) []uint {
+	hits := idx.SearchScored(query)
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+// SearchScored is Search with the BM25 score of every hit.
+func (idx *MemoryIndex) SearchScored(query string) []Hit {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Walk the rarest term's postings and check the others against them.
+	unique := make(map[string]bool, len(terms))
+	var lists []map[uint]int
+	for _, term := range terms {
+		if unique[term] {
+			continue
+		}
+		unique[term] = true
+		docs, ok := idx.postings[term]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, docs)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	n := float64(len(idx.docLen))
+	avgLen := float64(idx.totalLen) / n
+
+	var hits []Hit
+	for id := range lists[0] {
+		score := 0.0
+		matched := true
+		for _, docs := range lists {
+			tf, ok := docs[id]
+			if !ok {
+				matched = false
+				break
+			}
+			df := float64(len(docs))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			f := float64(tf)
+			norm := 1 - bm25B + bm25B*float64(idx.docLen[id])/avgLen
+			score += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+		if matched {
+			hits = append(hits, Hit{ID: id, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+// Len returns the number of indexed documents.
+func (idx *MemoryIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docLen)
+}
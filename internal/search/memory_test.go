@@ -0,0 +1,72 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStemEnglish(t *testing.T) {
+	cases := map[string]string{
+		"caresses":    "caress",
+		"ponies":      "poni",
+		"running":     "run",
+		"hopping":     "hop",
+		"relational":  "relat",
+		"conditional": "condit",
+		"happiness":   "happi",
+		"replacement": "replac",
+		"generalize":  "gener",
+		"falling":     "fall",
+		"sky":         "sky",
+	}
+	for word, stem := range cases {
+		assert.Equal(t, stem, stemEnglish(word), word)
+	}
+}
+
+func TestStemRussian(t *testing.T) {
+	cases := map[string]string{
+		"любовь":       "любов",
+		"любви":        "любв",
+		"песни":        "песн",
+		"песня":        "песн",
+		"бежала":       "бежа",
+		"красивые":     "красив",
+		"красивая":     "красив",
+		"осторожность": "осторожн",
+		"ёлка":         "елк",
+		"мы":           "мы",
+	}
+	for word, stem := range cases {
+		assert.Equal(t, stem, stemRussian(word), word)
+	}
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	idx := NewMemoryIndex()
+	idx.Put(1, "Falling Stars", "We were running through the night")
+	idx.Put(2, "Night Drive", "Night after night, driving through the night")
+	idx.Put(3, "Песня", "Красивые песни о любви")
+
+	// Stems match across word forms, and every query word must match.
+	assert.ElementsMatch(t, []uint{1, 2}, idx.Search("nights"))
+	assert.Equal(t, []uint{1}, idx.Search("run night"))
+	assert.Equal(t, []uint{3}, idx.Search("красивая песня"))
+	assert.Empty(t, idx.Search("night love"))
+
+	// The song that repeats the word ranks first.
+	hits := idx.SearchScored("night")
+	require.Len(t, hits, 2)
+	assert.Equal(t, uint(2), hits[0].ID)
+	assert.Greater(t, hits[0].Score, hits[1].Score)
+
+	idx.Put(2, "Morning", "Sunrise")
+	assert.Equal(t, []uint{1}, idx.Search("night"))
+	assert.Empty(t, idx.Search("driving"))
+
+	idx.Delete(1)
+	assert.Empty(t, idx.Search("night"))
+	assert.Equal(t, 2, idx.Len())
+}
@@ -0,0 +1,211 @@
+package search
+
+import "strings"
+
+// stemEnglish reduces an English word to its stem with the Porter algorithm
+// (M.F. Porter, "An algorithm for suffix stripping", 1980).
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	w := &porterWord{b: []byte(word)}
+	w.step1ab()
+	w.step1c()
+	w.step2()
+	w.step3()
+	w.step4()
+	w.step5()
+	return string(w.b)
+}
+
+type porterWord struct {
+	b []byte
+	j int // end of the stem while a suffix is being examined
+}
+
+func (w *porterWord) isConsonant(i int) bool {
+	switch w.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !w.isConsonant(i-1)
+	}
+	return true
+}
+
+// measure counts the VC sequences in b[0..j].
+func (w *porterWord) measure() int {
+	n, i := 0, 0
+	for ; i <= w.j && w.isConsonant(i); i++ {
+	}
+	for i <= w.j {
+		for ; i <= w.j && !w.isConsonant(i); i++ {
+		}
+		if i > w.j {
+			break
+		}
+		n++
+		for ; i <= w.j && w.isConsonant(i); i++ {
+		}
+	}
+	return n
+}
+
+func (w *porterWord) vowelInStem() bool {
+	for i := 0; i <= w.j; i++ {
+		if !w.isConsonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *porterWord) doubleConsonant(i int) bool {
+	return i >= 1 && w.b[i] == w.b[i-1] && w.isConsonant(i)
+}
+
+// cvc reports a consonant-vowel-consonant ending at i whose last consonant is
+// not w, x or y, as in "hop" or "fil".
+func (w *porterWord) cvc(i int) bool {
+	if i < 2 || !w.isConsonant(i) || w.isConsonant(i-1) || !w.isConsonant(i-2) {
+		return false
+	}
+	switch w.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (w *porterWord) ends(suffix string) bool {
+	if !strings.HasSuffix(string(w.b), suffix) {
+		return false
+	}
+	w.j = len(w.b) - len(suffix) - 1
+	return true
+}
+
+func (w *porterWord) setTo(s string) {
+	w.b = append(w.b[:w.j+1], s...)
+}
+
+func (w *porterWord) replaceIfMeasured(s string) {
+	if w.measure() > 0 {
+		w.setTo(s)
+	}
+}
+
+func (w *porterWord) step1ab() {
+	if w.b[len(w.b)-1] == 's' {
+		switch {
+		case w.ends("sses"):
+			w.b = w.b[:len(w.b)-2]
+		case w.ends("ies"):
+			w.setTo("i")
+		case len(w.b) >= 2 && w.b[len(w.b)-2] != 's':
+			w.b = w.b[:len(w.b)-1]
+		}
+	}
+
+	if w.ends("eed") {
+		if w.measure() > 0 {
+			w.b = w.b[:len(w.b)-1]
+		}
+		return
+	}
+	if (w.ends("ed") || w.ends("ing")) && w.vowelInStem() {
+		w.b = w.b[:w.j+1]
+		switch {
+		case w.ends("at"):
+			w.setTo("ate")
+		case w.ends("bl"):
+			w.setTo("ble")
+		case w.ends("iz"):
+			w.setTo("ize")
+		case w.doubleConsonant(len(w.b) - 1):
+			switch w.b[len(w.b)-1] {
+			case 'l', 's', 'z':
+			default:
+				w.b = w.b[:len(w.b)-1]
+			}
+		default:
+			w.j = len(w.b) - 1
+			if w.measure() == 1 && w.cvc(len(w.b)-1) {
+				w.b = append(w.b, 'e')
+			}
+		}
+	}
+}
+
+func (w *porterWord) step1c() {
+	if w.ends("y") && w.vowelInStem() {
+		w.b[len(w.b)-1] = 'i'
+	}
+}
+
+var porterStep2 = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+func (w *porterWord) step2() {
+	for _, rule := range porterStep2 {
+		if w.ends(rule[0]) {
+			w.replaceIfMeasured(rule[1])
+			return
+		}
+	}
+}
+
+var porterStep3 = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func (w *porterWord) step3() {
+	for _, rule := range porterStep3 {
+		if w.ends(rule[0]) {
+			w.replaceIfMeasured(rule[1])
+			return
+		}
+	}
+}
+
+var porterStep4 = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func (w *porterWord) step4() {
+	for _, suffix := range porterStep4 {
+		if !w.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (w.j < 0 || (w.b[w.j] != 's' && w.b[w.j] != 't')) {
+			return
+		}
+		if w.measure() > 1 {
+			w.b = w.b[:w.j+1]
+		}
+		return
+	}
+}
+
+func (w *porterWord) step5() {
+	w.j = len(w.b) - 1
+	if w.b[w.j] == 'e' {
+		w.j--
+		m := w.measure()
+		if m > 1 || (m == 1 && !w.cvc(w.j)) {
+			w.b = w.b[:len(w.b)-1]
+		}
+	}
+	w.j = len(w.b) - 1
+	if w.b[w.j] == 'l' && w.doubleConsonant(w.j) && w.measure() > 1 {
+		w.b = w.b[:len(w.b)-1]
+	}
+}
@@ -0,0 +1,149 @@
+package search
+
+import "strings"
+
+// stemRussian reduces a Russian word to its stem with the Snowball Russian
+// stemming algorithm. The word must already be lower case.
+func stemRussian(word string) string {
+	runes := []rune(strings.ReplaceAll(word, "ё", "е"))
+
+	rv := len(runes)
+	for i, r := range runes {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	if rv >= len(runes) {
+		return string(runes)
+	}
+	r2 := russianR2(runes)
+
+	stem, tail := runes[:rv], runes[rv:]
+
+	// Step 1.
+	if t, ok := removeRussianEnding(tail, russianGerund1, russianGerund2); ok {
+		tail = t
+	} else {
+		if t, ok := removeRussianEnding(tail, nil, russianReflexive); ok {
+			tail = t
+		}
+		if t, ok := removeRussianEnding(tail, nil, russianAdjective); ok {
+			tail = t
+			if t, ok := removeRussianEnding(tail, russianParticiple1, russianParticiple2); ok {
+				tail = t
+			}
+		} else if t, ok := removeRussianEnding(tail, russianVerb1, russianVerb2); ok {
+			tail = t
+		} else if t, ok := removeRussianEnding(tail, nil, russianNoun); ok {
+			tail = t
+		}
+	}
+
+	// Step 2.
+	if strings.HasSuffix(string(tail), "и") {
+		tail = tail[:len(tail)-1]
+	}
+
+	// Step 3: derivational endings are only removed inside R2.
+	for _, suffix := range []string{"ость", "ост"} {
+		if strings.HasSuffix(string(tail), suffix) && rv+len(tail)-len([]rune(suffix)) >= r2 {
+			tail = tail[:len(tail)-len([]rune(suffix))]
+			break
+		}
+	}
+
+	// Step 4.
+	switch s := string(tail); {
+	case strings.HasSuffix(s, "нн"):
+		tail = tail[:len(tail)-1]
+	case strings.HasSuffix(s, "ейше") || strings.HasSuffix(s, "ейш"):
+		if strings.HasSuffix(s, "ейше") {
+			tail = tail[:len(tail)-4]
+		} else {
+			tail = tail[:len(tail)-3]
+		}
+		if strings.HasSuffix(string(tail), "нн") {
+			tail = tail[:len(tail)-1]
+		}
+	case strings.HasSuffix(s, "ь"):
+		tail = tail[:len(tail)-1]
+	}
+
+	return string(stem) + string(tail)
+}
+
+func isRussianVowel(r rune) bool {
+	return strings.ContainsRune("аеиоуыэюя", r)
+}
+
+// russianR2 returns the start of region R2: R1 is the region after the first
+// non-vowel following a vowel, and R2 is the same region taken within R1.
+func russianR2(runes []rune) int {
+	region := func(from int) int {
+		for i := from + 1; i < len(runes); i++ {
+			if !isRussianVowel(runes[i]) && isRussianVowel(runes[i-1]) {
+				return i + 1
+			}
+		}
+		return len(runes)
+	}
+	r1 := region(0)
+	if r1 >= len(runes) {
+		return len(runes)
+	}
+	return region(r1)
+}
+
+// removeRussianEnding strips the longest matching ending. Endings of the first
+// group only count when they follow "а" or "я", which is kept.
+func removeRussianEnding(tail []rune, group1, group2 []string) ([]rune, bool) {
+	s := string(tail)
+	best, keep := 0, 0
+	for _, ending := range group1 {
+		n := len([]rune(ending))
+		if n+1 <= len(tail) && n > best && strings.HasSuffix(s, ending) {
+			if prev := tail[len(tail)-n-1]; prev == 'а' || prev == 'я' {
+				best, keep = n, len(tail)-n
+			}
+		}
+	}
+	for _, ending := range group2 {
+		n := len([]rune(ending))
+		if n <= len(tail) && n > best && strings.HasSuffix(s, ending) {
+			best, keep = n, len(tail)-n
+		}
+	}
+	if best == 0 {
+		return tail, false
+	}
+	return tail[:keep], true
+}
+
+var (
+	russianGerund1 = []string{"в", "вши", "вшись"}
+	russianGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+
+	russianAdjective = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+
+	russianParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	russianParticiple2 = []string{"ивш", "ывш", "ующ"}
+
+	russianReflexive = []string{"ся", "сь"}
+
+	russianVerb1 = []string{
+		"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно",
+	}
+	russianVerb2 = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	}
+
+	russianNoun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	}
+)
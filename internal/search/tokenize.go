@@ -0,0 +1,56 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lower-case words and stems each of them: words
+// written in Cyrillic with the Russian stemmer, words in Latin script with the
+// English one. Other words are kept as they are.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
+
+// Stem stems a single lower-case word in the language its script suggests.
+func Stem(word string) string {
+	switch script(word) {
+	case unicode.Cyrillic:
+		return stemRussian(word)
+	case unicode.Latin:
+		return stemEnglish(word)
+	}
+	return word
+}
+
+// script returns the script of the word's letters, or nil if it mixes scripts
+// or has no letters at all.
+func script(word string) *unicode.RangeTable {
+	var found *unicode.RangeTable
+	for _, r := range word {
+		var current *unicode.RangeTable
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			current = unicode.Cyrillic
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			current = unicode.Latin
+		case unicode.IsLetter(r):
+			return nil
+		default:
+			continue
+		}
+		if found != nil && found != current {
+			return nil
+		}
+		found = current
+	}
+	return found
+}
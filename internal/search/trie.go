@@ -0,0 +1,92 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// Completion is a value that starts with the requested prefix and how many
+// items carry it.
+type Completion struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Trie is a prefix tree of values keyed by a normalized form of each value.
+// It is not safe for concurrent writes; build it first, then only read it.
+type Trie struct {
+	root trieNode
+	size int
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	value    string
+	count    int
+}
+
+func NewTrie() *Trie {
+	return &Trie{}
+}
+
+// Add records count more items with the given key. The value is what
+// completions show; the first value added for a key is kept.
+func (t *Trie) Add(key, value string, count int) {
+	node := &t.root
+	for _, r := range key {
+		if node.children == nil {
+			node.children = make(map[rune]*trieNode)
+		}
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		node = child
+	}
+	if node.count == 0 {
+		node.value = value
+		t.size++
+	}
+	node.count += count
+}
+
+// Complete returns up to limit values whose key starts with prefix, the most
+// frequent first and alphabetically among equals.
+func (t *Trie) Complete(prefix string, limit int) []Completion {
+	node := &t.root
+	for _, r := range prefix {
+		node = node.children[r]
+		if node == nil {
+			return []Completion{}
+		}
+	}
+
+	completions := []Completion{}
+	var walk func(n *trieNode)
+	walk = func(n *trieNode) {
+		if n.count > 0 {
+			completions = append(completions, Completion{Value: n.value, Count: n.count})
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	sort.Slice(completions, func(i, j int) bool {
+		if completions[i].Count != completions[j].Count {
+			return completions[i].Count > completions[j].Count
+		}
+		return strings.ToLower(completions[i].Value) < strings.ToLower(completions[j].Value)
+	})
+	if len(completions) > limit {
+		completions = completions[:limit]
+	}
+	return completions
+}
+
+// Len returns the number of distinct keys.
+func (t *Trie) Len() int {
+	return t.size
+}
@@ -0,0 +1,22 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrieComplete(t *testing.T) {
+	trie := NewTrie()
+	trie.Add("muse", "Muse", 3)
+	trie.Add("muzak", "Muzak", 1)
+	trie.Add("mudhoney", "Mudhoney", 3)
+	trie.Add("muse", "MUSE", 1)
+	trie.Add("кино", "Кино", 2)
+
+	assert.Equal(t, 4, trie.Len())
+	assert.Equal(t, []Completion{{"Muse", 4}, {"Mudhoney", 3}, {"Muzak", 1}}, trie.Complete("mu", 10))
+	assert.Equal(t, []Completion{{"Muse", 4}}, trie.Complete("mu", 1))
+	assert.Equal(t, []Completion{{"Кино", 2}}, trie.Complete("ки", 10))
+	assert.Empty(t, trie.Complete("x", 10))
+}
@@ -0,0 +1,16 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS group_name TEXT;
+
+UPDATE songs
+SET group_name = groups.name
+FROM groups
+WHERE groups.id = songs.group_id;
+
+ALTER TABLE songs ALTER COLUMN group_name SET NOT NULL;
+
+DROP INDEX IF EXISTS unique_song;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_song ON songs (group_name, song_name);
+
+ALTER TABLE songs DROP COLUMN IF EXISTS group_id;
+
+DROP INDEX IF EXISTS unique_group;
+DROP TABLE IF EXISTS groups;
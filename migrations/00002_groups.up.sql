@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS groups
+(
+    id              SERIAL PRIMARY KEY,
+    name            TEXT NOT NULL,
+    normalized_name TEXT NOT NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_group ON groups (normalized_name);
+
+-- One group per normalized name; the earliest song decides how the name is spelled.
+INSERT INTO groups (name, normalized_name)
+SELECT DISTINCT ON (lower(regexp_replace(trim(group_name), '\s+', ' ', 'g')))
+       regexp_replace(trim(group_name), '\s+', ' ', 'g'),
+       lower(regexp_replace(trim(group_name), '\s+', ' ', 'g'))
+FROM songs
+ORDER BY lower(regexp_replace(trim(group_name), '\s+', ' ', 'g')), id
+ON CONFLICT (normalized_name) DO NOTHING;
+
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES groups (id);
+
+UPDATE songs
+SET group_id = groups.id
+FROM groups
+WHERE groups.normalized_name = lower(regexp_replace(trim(songs.group_name), '\s+', ' ', 'g'));
+
+-- Songs that were only distinct because of the group spelling are now
+-- duplicates. They are not dropped: the migration stops until they are merged.
+DO
+$$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('%L of group ID=%s', song_name, group_id), ', ')
+    INTO duplicates
+    FROM (SELECT group_id, song_name
+          FROM songs
+          GROUP BY group_id, song_name
+          HAVING COUNT(*) > 1) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'songs differing only in the spelling of their group must be merged first: %', duplicates;
+    END IF;
+END
+$$;
+
+ALTER TABLE songs ALTER COLUMN group_id SET NOT NULL;
+
+DROP INDEX IF EXISTS unique_song;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_song ON songs (group_id, song_name);
+
+ALTER TABLE songs DROP COLUMN IF EXISTS group_name;
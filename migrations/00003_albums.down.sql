@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS album_tracks_song_id;
+DROP INDEX IF EXISTS unique_album_track;
+DROP TABLE IF EXISTS album_tracks;
+DROP INDEX IF EXISTS unique_album;
+DROP TABLE IF EXISTS albums;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS albums
+(
+    id           SERIAL PRIMARY KEY,
+    group_id     INTEGER NOT NULL REFERENCES groups (id),
+    title        TEXT NOT NULL,
+    release_date DATE,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_album ON albums (group_id, title);
+
+CREATE TABLE IF NOT EXISTS album_tracks
+(
+    album_id     INTEGER NOT NULL REFERENCES albums (id) ON DELETE CASCADE,
+    song_id      INTEGER NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    disc_number  INTEGER NOT NULL DEFAULT 1 CHECK (disc_number > 0),
+    track_number INTEGER NOT NULL CHECK (track_number > 0),
+    PRIMARY KEY (album_id, song_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_album_track ON album_tracks (album_id, disc_number, track_number);
+CREATE INDEX IF NOT EXISTS album_tracks_song_id ON album_tracks (song_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_song_tags_tag_id;
+DROP TABLE IF EXISTS song_tags;
+DROP INDEX IF EXISTS unique_tag;
+DROP TABLE IF EXISTS tags;
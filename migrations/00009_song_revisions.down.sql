@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS unique_song_revision;
+DROP TABLE IF EXISTS song_revisions;
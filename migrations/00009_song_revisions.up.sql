@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS song_revisions
+(
+    id            SERIAL PRIMARY KEY,
+    song_id       INTEGER NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    revision      INTEGER NOT NULL,
+    action        TEXT    NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore', 'revert')),
+    actor         TEXT    NOT NULL DEFAULT '',
+    reverted_from INTEGER,
+    group_name    TEXT    NOT NULL,
+    song_name     TEXT    NOT NULL,
+    release_date  DATE    NOT NULL,
+    text          TEXT    NOT NULL,
+    link          TEXT    NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_song_revision ON song_revisions (song_id, revision);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_enrichment_cache_expires_at;
+DROP TABLE IF EXISTS enrichment_cache;
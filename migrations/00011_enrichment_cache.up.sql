@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS enrichment_cache
+(
+    cache_key    TEXT PRIMARY KEY,
+    release_date TIMESTAMP,
+    text         TEXT      NOT NULL,
+    link         TEXT      NOT NULL,
+    not_found    BOOLEAN   NOT NULL,
+    expires_at   TIMESTAMP NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_cache_expires_at ON enrichment_cache (expires_at);
@@ -0,0 +1,11 @@
+ALTER TABLE song_revisions DROP CONSTRAINT IF EXISTS song_revisions_action_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'revert')) NOT VALID;
+
+DROP INDEX IF EXISTS idx_enrichment_jobs_state_run_at;
+DROP INDEX IF EXISTS idx_enrichment_jobs_song_id;
+DROP TABLE IF EXISTS enrichment_jobs;
+
+DROP INDEX IF EXISTS idx_songs_status;
+ALTER TABLE songs DROP COLUMN IF EXISTS enrichment_error;
+ALTER TABLE songs DROP COLUMN IF EXISTS status;
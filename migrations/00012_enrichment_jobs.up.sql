@@ -0,0 +1,25 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'ready';
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enrichment_error TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_songs_status ON songs (status);
+
+CREATE TABLE IF NOT EXISTS enrichment_jobs
+(
+    id           SERIAL PRIMARY KEY,
+    song_id      INTEGER   NOT NULL,
+    state        TEXT      NOT NULL,
+    attempts     INTEGER   NOT NULL,
+    max_attempts INTEGER   NOT NULL,
+    last_error   TEXT      NOT NULL,
+    run_at       TIMESTAMP NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at  TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_song_id ON enrichment_jobs (song_id);
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_state_run_at ON enrichment_jobs (state, run_at);
+
+ALTER TABLE song_revisions DROP CONSTRAINT IF EXISTS song_revisions_action_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'revert', 'enrich'));
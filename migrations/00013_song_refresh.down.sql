@@ -0,0 +1,2 @@
+ALTER TABLE songs DROP COLUMN IF EXISTS protected_fields;
+ALTER TABLE songs DROP COLUMN IF EXISTS enriched_at;
@@ -0,0 +1,2 @@
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enriched_at TIMESTAMP;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS protected_fields TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_groups_name_translit_trgm;
+DROP INDEX IF EXISTS idx_songs_song_name_translit_trgm;
@@ -0,0 +1,6 @@
+-- Fuzzy suggestions also compare transliterated names, as the expression
+-- models.transliterateSQL builds; these indexes must use exactly that expression.
+CREATE INDEX IF NOT EXISTS idx_songs_song_name_translit_trgm
+    ON songs USING GIN ((translate(replace(replace(replace(replace(replace(replace(replace(replace(lower(song_name), 'ж', 'zh'), 'х', 'kh'), 'ц', 'ts'), 'ч', 'ch'), 'ш', 'sh'), 'щ', 'shch'), 'ю', 'iu'), 'я', 'ia'), 'абвгдезийклмнопрстуфыэёъь', 'abvgdeziiklmnoprstufyee')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_groups_name_translit_trgm
+    ON groups USING GIN ((translate(replace(replace(replace(replace(replace(replace(replace(replace(lower(name), 'ж', 'zh'), 'х', 'kh'), 'ц', 'ts'), 'ч', 'ch'), 'ш', 'sh'), 'щ', 'shch'), 'ю', 'iu'), 'я', 'ia'), 'абвгдезийклмнопрстуфыэёъь', 'abvgdeziiklmnoprstufyee')) gin_trgm_ops);
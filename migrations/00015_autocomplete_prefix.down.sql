@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_songs_song_name_prefix;
+DROP INDEX IF EXISTS idx_groups_normalized_name_prefix;
@@ -0,0 +1,5 @@
+-- Autocompletion on PostgreSQL looks names up by prefix on these indexes; the
+-- song name expression matches models.normalizedSongNameSQL.
+CREATE INDEX IF NOT EXISTS idx_groups_normalized_name_prefix ON groups (normalized_name text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_songs_song_name_prefix
+    ON songs ((lower(regexp_replace(btrim(song_name), '\s+', ' ', 'g'))) text_pattern_ops);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS unique_active_song;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_song ON songs (group_id, song_name);
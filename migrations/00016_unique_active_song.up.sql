@@ -0,0 +1,3 @@
+-- Trashed songs no longer block adding a song of the same name again.
+DROP INDEX IF EXISTS unique_song;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_active_song ON songs (group_id, song_name) WHERE deleted_at IS NULL;